@@ -0,0 +1,84 @@
+package alert
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+// maxAttempts is the number of times a delivery is attempted before failing.
+const maxAttempts = 10
+
+// enqueue adds an alert_delivery row for each Sender, all due immediately.
+func enqueue(db meddler.DB, a *core.Alert, senders []Sender) error {
+	for _, s := range senders {
+		d := &core.AlertDelivery{AlertId: a.Id, Notifier: s.Name(), NextAttempt: a.Created}
+		err := meddler.Insert(db, "alert_delivery", d)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deliver attempts every due delivery in the alert_delivery outbox, returning
+// the number delivered. Failed attempts are retried with exponential backoff
+// until maxAttempts is reached. Deliveries for notifiers that are no longer
+// configured are left in the outbox.
+func Deliver(db *sql.DB, senders []Sender, now time.Time) (int, error) {
+	delivered := 0
+	for _, s := range senders {
+		var pending []*core.AlertDelivery
+		err := meddler.QueryAll(db, &pending, "SELECT * FROM alert_delivery WHERE notifier = $1 AND "+
+			"delivered IS NULL AND NOT failed AND next_attempt <= $2 ORDER BY id LIMIT 100", s.Name(), now)
+		if err != nil {
+			return delivered, err
+		}
+
+		for _, d := range pending {
+			view := new(core.AlertView)
+			err = meddler.QueryRow(db, view, "SELECT * FROM v_alert WHERE id = $1", d.AlertId)
+			if err != nil {
+				return delivered, err
+			}
+
+			d.Attempts++
+			err = s.Send(d.Id, view)
+			if err == nil {
+				d.Delivered = now
+				d.LastError = ""
+				delivered++
+			} else {
+				log.Printf("alert: %s delivery %d attempt %d failed: %v", s.Name(), d.Id, d.Attempts, err)
+				d.LastError = err.Error()
+				if len(d.LastError) > 1000 {
+					d.LastError = d.LastError[:1000]
+				}
+				d.Failed = d.Attempts >= maxAttempts
+				d.NextAttempt = now.Add(Backoff(d.Attempts))
+			}
+
+			err = meddler.Update(db, "alert_delivery", d)
+			if err != nil {
+				return delivered, err
+			}
+		}
+	}
+	return delivered, nil
+}
+
+// Backoff returns the delay before retrying after the passed number of
+// attempts, doubling from a minute to at most an hour.
+func Backoff(attempts int) time.Duration {
+	if attempts > 6 {
+		return time.Hour
+	}
+	d := time.Minute << uint(attempts-1)
+	if d > time.Hour {
+		return time.Hour
+	}
+	return d
+}
@@ -0,0 +1,85 @@
+package alert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/gateway"
+	"github.com/russross/meddler"
+)
+
+// testSender records deliveries, failing while fail is set.
+type testSender struct {
+	fail bool
+	sent []int64
+}
+
+func (s *testSender) Name() string {
+	return "test"
+}
+
+func (s *testSender) Send(deliveryId int64, a *core.AlertView) error {
+	if s.fail {
+		return errors.New("unavailable")
+	}
+	s.sent = append(s.sent, a.Id)
+	return nil
+}
+
+func TestDeliverRetriesFromOutbox(t *testing.T) {
+	c := core.NewTestConfig(t)
+	ctx, err := core.NewContext(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	gateway.TestSimpleFeedPublishesDoneMessage(t, &ff, 15*time.Second)
+
+	rule := &core.AlertRule{RuleName: "test", Metric: string(Cushion), Operator: Above, Threshold: -1, Enabled: true}
+	if err := meddler.Insert(ctx.DB, "alert_rule", rule); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.DB.Exec("DELETE FROM alert_rule WHERE id = $1", rule.Id)
+
+	sender := &testSender{fail: true}
+	senders := []Sender{sender}
+	now := time.Now().UTC()
+	alerts, err := EvaluateAll(ctx.DB, senders, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) == 0 {
+		t.Fatal("expected an alert")
+	}
+	defer ctx.DB.Exec("DELETE FROM alert_delivery WHERE notifier = $1", sender.Name())
+
+	delivered, err := Deliver(ctx.DB, senders, now)
+	if err != nil || delivered != 0 {
+		t.Fatalf("failing sender delivered %d (%v)", delivered, err)
+	}
+
+	// the retry is not yet due
+	sender.fail = false
+	delivered, err = Deliver(ctx.DB, senders, now)
+	if err != nil || delivered != 0 {
+		t.Fatalf("delivered %d before backoff elapsed (%v)", delivered, err)
+	}
+
+	delivered, err = Deliver(ctx.DB, senders, now.Add(Backoff(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != len(alerts) {
+		t.Fatalf("delivered %d of %d alerts", delivered, len(alerts))
+	}
+
+	// delivered alerts are not sent again
+	delivered, err = Deliver(ctx.DB, senders, now.Add(time.Hour))
+	if err != nil || delivered != 0 {
+		t.Fatalf("redelivered %d (%v)", delivered, err)
+	}
+}
@@ -0,0 +1,26 @@
+/*
+Package alert raises alerts when account metrics breach user-defined rules.
+
+Rules are stored in the alert_rule table and compare a metric of the BASE
+account values (such as Cushion or ExcessLiquidity) against a threshold using
+"<" or ">". Monetary metrics are in the account's base currency. The
+NetLiquidationDayChange metric is the percentage change in NetLiquidation from
+the latest snapshot at least a day older, so a rule of "< -5" fires on a 5%
+daily drop.
+
+An Engine evaluates every enabled rule against the latest snapshot of each
+account whenever the account feed completes. To avoid flapping, a firing rule
+only resolves once the metric has moved past the threshold by the rule's
+hysteresis. Each change between firing and resolved is appended to the alert
+table and published as an NtAlert notification carrying the alert ID.
+
+Each alert is also queued in the alert_delivery outbox for every configured
+Sender (signed HTTP webhooks and SMTP email are provided) within the same
+transaction, then delivered and retried with exponential backoff. Webhook
+receivers can verify the SignatureHeader and use the DeliveryHeader to discard
+the duplicates that retries can cause.
+
+Only the cluster leader (as determined by a DistLock) evaluates rules, so
+alerts are raised and delivered once regardless of how many nodes are running.
+*/
+package alert
@@ -0,0 +1,57 @@
+package alert
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+const lockManagerKey int64 = 7391826454918273645
+
+// retryInterval is how often the leader re-evaluates rules and retries any
+// outstanding deliveries, in addition to whenever an account feed completes.
+const retryInterval = 30 * time.Second
+
+// Engine evaluates the alert rules whenever an account feed completes and
+// delivers the resulting alerts using its Senders, if this node is the cluster
+// leader for alerting.
+type Engine struct {
+	leader  *core.Leader
+	db      *sql.DB
+	n       core.Notifier
+	senders []Sender
+}
+
+func NewEngine(db *sql.DB, n core.Notifier, distLock core.DistLock, senders []Sender) (*Engine, error) {
+	e := &Engine{
+		db:      db,
+		n:       n,
+		senders: senders,
+	}
+	e.leader = core.NewLeader(distLock, lockManagerKey, retryInterval, n, e.process)
+	return e, nil // never returns error, but declared for consistency
+}
+
+// Close terminates the Engine. Close can be called multiple times safely, and
+// it will block until the Engine has been closed.
+func (e *Engine) Close() {
+	e.leader.Close()
+}
+
+// process evaluates the rules and attempts any due deliveries.
+func (e *Engine) process() {
+	alerts, err := EvaluateAll(e.db, e.senders, time.Now().UTC())
+	if err != nil {
+		log.Printf("alert: evaluation failed: %v", err)
+	}
+	for _, a := range alerts {
+		e.n.Publish(core.NtAlert, a.Id)
+	}
+
+	_, err = Deliver(e.db, e.senders, time.Now().UTC())
+	if err != nil {
+		log.Printf("alert: delivery failed: %v", err)
+	}
+}
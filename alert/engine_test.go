@@ -0,0 +1,69 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/gateway"
+	"github.com/russross/meddler"
+)
+
+func TestEngineRaisesAlertOncePerSnapshot(t *testing.T) {
+	c := core.NewTestConfig(t)
+	ctx, err := core.NewContext(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	// cushion is never below -1, so this rule fires on the first evaluation
+	rule := &core.AlertRule{RuleName: "test", Metric: string(Cushion), Operator: Above, Threshold: -1, Enabled: true}
+	if err := meddler.Insert(ctx.DB, "alert_rule", rule); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.DB.Exec("DELETE FROM alert_rule WHERE id = $1", rule.Id)
+
+	engine, err := NewEngine(ctx.DB, ctx.N, ctx.DL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+
+	notifications := make(chan *core.Notification)
+	ctx.N.Subscribe(notifications)
+	defer ctx.N.Unsubscribe(notifications)
+
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	gateway.TestSimpleFeedPublishesDoneMessage(t, &ff, 15*time.Second)
+
+	for {
+		select {
+		case event := <-notifications:
+			if event.Type != core.NtAlert {
+				continue
+			}
+			a := new(core.Alert)
+			if err := meddler.Load(ctx.DB, "alert", a, event.Id); err != nil {
+				t.Fatal(err)
+			}
+			if a.AlertRuleId != rule.Id || !a.Firing {
+				continue
+			}
+
+			// the rule has been evaluated, so the snapshots are not again
+			alerts, err := EvaluateAll(ctx.DB, nil, time.Now().UTC())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, a := range alerts {
+				if a.AlertRuleId == rule.Id {
+					t.Fatal("rule re-evaluated against the same snapshot")
+				}
+			}
+			return
+		case <-time.After(15 * time.Second):
+			t.Fatal("timeout waiting for alert")
+		}
+	}
+}
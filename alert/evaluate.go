@@ -0,0 +1,172 @@
+package alert
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+// EvaluateAll evaluates every enabled rule against the latest snapshot of each
+// account, returning the alerts raised. Snapshots a rule has already been
+// evaluated against are skipped, so calling EvaluateAll repeatedly is safe.
+// Each alert is queued for delivery by every Sender.
+func EvaluateAll(db *sql.DB, senders []Sender, now time.Time) ([]*core.Alert, error) {
+	alerts := []*core.Alert{}
+
+	var rules []*core.AlertRule
+	err := meddler.QueryAll(db, &rules, "SELECT * FROM alert_rule WHERE enabled ORDER BY id")
+	if err != nil || len(rules) == 0 {
+		return alerts, err
+	}
+
+	currencies, err := core.NewCurrencies(db)
+	if err != nil {
+		return alerts, err
+	}
+
+	var snaps []*core.AccountSnapshot
+	err = meddler.QueryAll(db, &snaps, "SELECT * FROM account_snapshot s WHERE created = "+
+		"(SELECT max(created) FROM account_snapshot WHERE account_id = s.account_id) ORDER BY account_id")
+	if err != nil {
+		return alerts, err
+	}
+
+	for _, snap := range snaps {
+		metrics, err := loadMetrics(db, currencies, snap)
+		if err != nil {
+			return alerts, err
+		}
+
+		raised, err := evaluateAccount(db, senders, rules, snap, metrics, now)
+		if err != nil {
+			return alerts, err
+		}
+		alerts = append(alerts, raised...)
+	}
+	return alerts, nil
+}
+
+// evaluateAccount evaluates the rules applicable to the snapshot's account in
+// a single transaction.
+func evaluateAccount(db *sql.DB, senders []Sender, rules []*core.AlertRule, snap *core.AccountSnapshot, metrics map[Metric]float64, now time.Time) ([]*core.Alert, error) {
+	alerts := []*core.Alert{}
+	tx, err := db.Begin()
+	if err != nil {
+		return alerts, err
+	}
+	defer tx.Rollback()
+
+	for _, rule := range rules {
+		if rule.AccountId != 0 && rule.AccountId != snap.AccountId {
+			continue
+		}
+		value, ok := metrics[Metric(rule.Metric)]
+		if !ok {
+			continue
+		}
+
+		state := new(core.AlertState)
+		existing := true
+		err = meddler.QueryRow(tx, state, "SELECT * FROM alert_state WHERE alert_rule_id = $1 AND account_id = $2",
+			rule.Id, snap.AccountId)
+		if err == sql.ErrNoRows {
+			existing = false
+			state = &core.AlertState{AlertRuleId: rule.Id, AccountId: snap.AccountId}
+		} else if err != nil {
+			return alerts, err
+		}
+		if state.AccountSnapshotId == snap.Id {
+			continue
+		}
+
+		firing := Evaluate(*rule, state.Firing, value)
+		if firing != state.Firing {
+			alert := &core.Alert{
+				Created:           now,
+				AlertRuleId:       rule.Id,
+				AccountSnapshotId: snap.Id,
+				Firing:            firing,
+				Value:             value,
+			}
+			err = meddler.Insert(tx, "alert", alert)
+			if err == nil {
+				err = enqueue(tx, alert, senders)
+			}
+			if err != nil {
+				return alerts, err
+			}
+			alerts = append(alerts, alert)
+		}
+
+		state.Firing = firing
+		state.AccountSnapshotId = snap.Id
+		if existing {
+			_, err = tx.Exec("UPDATE alert_state SET firing = $1, account_snapshot_id = $2 WHERE "+
+				"alert_rule_id = $3 AND account_id = $4", state.Firing, state.AccountSnapshotId, rule.Id, snap.AccountId)
+		} else {
+			err = meddler.Insert(tx, "alert_state", state)
+		}
+		if err != nil {
+			return alerts, err
+		}
+	}
+
+	return alerts, tx.Commit()
+}
+
+// loadMetrics returns the metrics of the snapshot's BASE account values.
+// Metrics that cannot be calculated (such as NetLiquidationDayChange without a
+// snapshot from a day earlier) are absent.
+func loadMetrics(db *sql.DB, currencies core.Currencies, snap *core.AccountSnapshot) (map[Metric]float64, error) {
+	metrics := make(map[Metric]float64)
+
+	amt := new(core.AccountAmount)
+	err := meddler.QueryRow(db, amt, "SELECT * FROM account_amount WHERE account_snapshot_id = $1 AND base", snap.Id)
+	if err == sql.ErrNoRows {
+		return metrics, nil
+	}
+	if err != nil {
+		return metrics, err
+	}
+
+	metrics[Cushion] = amt.Cushion
+	monetaries := map[Metric]core.Monetary{
+		ExcessLiquidity:          amt.ExcessLiquidity,
+		LookAheadExcessLiquidity: amt.LookAheadExcessLiquidity,
+		NetLiquidation:           amt.NetLiquidation,
+	}
+	for metric, m := range monetaries {
+		if m.Iso4217Code == 0 {
+			continue
+		}
+		metrics[metric], err = m.Float(currencies)
+		if err != nil {
+			return metrics, err
+		}
+	}
+
+	prior := new(core.AccountAmount)
+	err = meddler.QueryRow(db, prior, "SELECT account_amount.* FROM account_amount, account_snapshot "+
+		"WHERE account_snapshot.id = account_snapshot_id AND account_id = $1 AND base AND created <= $2 "+
+		"ORDER BY created DESC LIMIT 1", snap.AccountId, snap.Created.Add(-24*time.Hour))
+	if err == sql.ErrNoRows {
+		return metrics, nil
+	}
+	if err != nil {
+		return metrics, err
+	}
+
+	current, ok := metrics[NetLiquidation]
+	if ok && prior.NetLiquidation.Iso4217Code == amt.NetLiquidation.Iso4217Code {
+		previous, err := prior.NetLiquidation.Float(currencies)
+		if err != nil {
+			return metrics, err
+		}
+		if previous > 0 {
+			metrics[NetLiquidationDayChange] = (current - previous) / previous * 100
+		}
+	}
+	return metrics, nil
+}
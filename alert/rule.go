@@ -0,0 +1,72 @@
+package alert
+
+import (
+	"fmt"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+// Metric names an account value that rules can be defined against.
+type Metric string
+
+const (
+	Cushion                  Metric = "Cushion"
+	ExcessLiquidity          Metric = "ExcessLiquidity"
+	LookAheadExcessLiquidity Metric = "LookAheadExcessLiquidity"
+	NetLiquidation           Metric = "NetLiquidation"
+	NetLiquidationDayChange  Metric = "NetLiquidationDayChange"
+)
+
+// Metrics returns all metrics rules can be defined against.
+func Metrics() []Metric {
+	return []Metric{Cushion, ExcessLiquidity, LookAheadExcessLiquidity, NetLiquidation, NetLiquidationDayChange}
+}
+
+const (
+	Below = "<"
+	Above = ">"
+)
+
+// Validate returns an error describing the first invalid field of the rule.
+func Validate(r core.AlertRule) error {
+	if r.RuleName == "" {
+		return fmt.Errorf("RuleName is required")
+	}
+
+	known := false
+	for _, m := range Metrics() {
+		known = known || Metric(r.Metric) == m
+	}
+	if !known {
+		return fmt.Errorf("Metric '%s' is not one of %v", r.Metric, Metrics())
+	}
+
+	if r.Operator != Below && r.Operator != Above {
+		return fmt.Errorf("Operator '%s' is not '%s' or '%s'", r.Operator, Below, Above)
+	}
+
+	if r.Hysteresis < 0 {
+		return fmt.Errorf("Hysteresis must not be negative")
+	}
+	return nil
+}
+
+// Evaluate returns whether the rule is firing for the passed value, given
+// whether it was previously firing. A rule starts firing as soon as the value
+// breaches the threshold, but stops only once the value is on the other side
+// of the threshold by at least the hysteresis.
+func Evaluate(r core.AlertRule, firing bool, value float64) bool {
+	threshold := r.Threshold
+	if firing {
+		if r.Operator == Below {
+			threshold += r.Hysteresis
+		} else {
+			threshold -= r.Hysteresis
+		}
+	}
+
+	if r.Operator == Below {
+		return value < threshold
+	}
+	return value > threshold
+}
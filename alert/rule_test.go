@@ -0,0 +1,67 @@
+package alert
+
+import (
+	"testing"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+func TestEvaluateBelowWithHysteresis(t *testing.T) {
+	r := core.AlertRule{Operator: Below, Threshold: 0.1, Hysteresis: 0.05}
+
+	steps := []struct {
+		value  float64
+		firing bool
+	}{
+		{0.2, false},
+		{0.1, false},  // threshold itself is not a breach
+		{0.09, true},  // breach
+		{0.12, true},  // recovered, but within hysteresis
+		{0.149, true}, // still within hysteresis
+		{0.16, false}, // resolved
+		{0.11, false}, // above threshold again, so remains resolved
+	}
+
+	firing := false
+	for i, s := range steps {
+		firing = Evaluate(r, firing, s.value)
+		if firing != s.firing {
+			t.Fatalf("step %d value %f firing %t (expected %t)", i, s.value, firing, s.firing)
+		}
+	}
+}
+
+func TestEvaluateAbove(t *testing.T) {
+	r := core.AlertRule{Operator: Above, Threshold: 100, Hysteresis: 10}
+	if Evaluate(r, false, 100) {
+		t.Fatal("threshold itself should not fire")
+	}
+	if !Evaluate(r, false, 101) {
+		t.Fatal("value above threshold should fire")
+	}
+	if !Evaluate(r, true, 95) {
+		t.Fatal("value within hysteresis should keep firing")
+	}
+	if Evaluate(r, true, 90) {
+		t.Fatal("value past hysteresis should resolve")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	r := core.AlertRule{RuleName: "low cushion", Metric: "Cushion", Operator: Below, Threshold: 0.1}
+	if err := Validate(r); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []core.AlertRule{
+		{Metric: "Cushion", Operator: Below},
+		{RuleName: "x", Metric: "Cash", Operator: Below},
+		{RuleName: "x", Metric: "Cushion", Operator: "<="},
+		{RuleName: "x", Metric: "Cushion", Operator: Below, Hysteresis: -1},
+	}
+	for i, r := range invalid {
+		if Validate(r) == nil {
+			t.Fatalf("rule %d should be invalid", i)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package alert
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+// Sender delivers alerts to an external system. Name must uniquely and stably
+// identify the Sender, as it keys the alert_delivery outbox.
+type Sender interface {
+	Name() string
+	Send(deliveryId int64, a *core.AlertView) error
+}
+
+// Senders returns the Senders configured by the passed Config.
+func Senders(c core.Config) []Sender {
+	s := []Sender{}
+	for _, url := range c.AlertHooks {
+		s = append(s, &WebhookSender{URL: url, Key: []byte(c.AlertHookKey)})
+	}
+	if c.SmtpAddr != "" {
+		s = append(s, &SmtpSender{Addr: c.SmtpAddr, User: c.SmtpUser, Pass: c.SmtpPass, From: c.SmtpFrom, To: c.SmtpTo})
+	}
+	return s
+}
+
+// SignatureHeader carries the hex HMAC-SHA256 of a webhook body, prefixed with
+// "sha256=". DeliveryHeader carries the delivery ID, which is unchanged across
+// retries so receivers can discard duplicates.
+const (
+	SignatureHeader = "X-Ibconnect-Signature"
+	DeliveryHeader  = "X-Ibconnect-Delivery"
+)
+
+// WebhookSender POSTs each alert as JSON to a URL, signing the body with Key.
+// Any status other than 2xx is considered a failure.
+type WebhookSender struct {
+	URL    string
+	Key    []byte
+	Client *http.Client
+}
+
+func (w *WebhookSender) Name() string {
+	return "webhook " + w.URL
+}
+
+func (w *WebhookSender) Send(deliveryId int64, a *core.AlertView) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, fmt.Sprintf("%d", deliveryId))
+	req.Header.Set(SignatureHeader, Sign(w.Key, body))
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value for the body.
+func Sign(key []byte, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SmtpSender emails each alert. PLAIN authentication is used if User is set.
+type SmtpSender struct {
+	Addr string
+	User string
+	Pass string
+	From string
+	To   []string
+}
+
+func (s *SmtpSender) Name() string {
+	return "smtp " + s.Addr
+}
+
+func (s *SmtpSender) Send(deliveryId int64, a *core.AlertView) error {
+	var auth smtp.Auth
+	if s.User != "" {
+		host := strings.Split(s.Addr, ":")[0]
+		auth = smtp.PlainAuth("", s.User, s.Pass, host)
+	}
+	return smtp.SendMail(s.Addr, auth, s.From, s.To, s.message(deliveryId, a))
+}
+
+// message formats the alert as an email.
+func (s *SmtpSender) message(deliveryId int64, a *core.AlertView) []byte {
+	state := "resolved"
+	if a.Firing {
+		state = "FIRING"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: [%s] %s %s\r\n", state, a.AccountCode, a.RuleName)
+	fmt.Fprintf(&b, "Date: %s\r\n", a.Created.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <alert-%d-delivery-%d@ibconnect>\r\n", a.Id, deliveryId)
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "Account:   %s\r\n", a.AccountCode)
+	fmt.Fprintf(&b, "Rule:      %s (%s %s %g)\r\n", a.RuleName, a.Metric, a.Operator, a.Threshold)
+	fmt.Fprintf(&b, "Value:     %g\r\n", a.Value)
+	fmt.Fprintf(&b, "State:     %s\r\n", state)
+	fmt.Fprintf(&b, "Time:      %s\r\n", a.Created.Format(time.RFC3339))
+	return b.Bytes()
+}
@@ -0,0 +1,137 @@
+package alert
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+var testAlert = &core.AlertView{
+	Id:          7,
+	Created:     time.Date(2014, 4, 22, 4, 22, 5, 0, time.UTC),
+	AccountCode: "DU12345",
+	RuleName:    "Low cushion",
+	Metric:      "Cushion",
+	Operator:    Below,
+	Threshold:   0.1,
+	Firing:      true,
+	Value:       0.08,
+}
+
+func TestWebhookSenderSigns(t *testing.T) {
+	key := []byte("secret")
+	received := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign(key, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- r
+	}))
+	defer server.Close()
+
+	s := &WebhookSender{URL: server.URL, Key: key}
+	if err := s.Send(42, testAlert); err != nil {
+		t.Fatal(err)
+	}
+	r := <-received
+	if r.Header.Get(DeliveryHeader) != "42" {
+		t.Fatalf("unexpected delivery header %s", r.Header.Get(DeliveryHeader))
+	}
+
+	s.Key = []byte("wrong")
+	if err := s.Send(43, testAlert); err == nil {
+		t.Fatal("rejected webhook should return an error")
+	}
+}
+
+func TestSign(t *testing.T) {
+	// RFC 4231 test case 2
+	expected := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if actual := Sign([]byte("Jefe"), []byte("what do ya want for nothing?")); actual != expected {
+		t.Fatalf("signature was %s (expected %s)", actual, expected)
+	}
+}
+
+// smtpStandIn accepts a single message on a local port, returning the address
+// and a channel that receives the message data.
+func smtpStandIn(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make(chan string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var msg []string
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg = append(msg, l)
+				}
+				data <- strings.Join(msg, "")
+				reply("250 ok")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), data
+}
+
+func TestSmtpSenderSends(t *testing.T) {
+	addr, data := smtpStandIn(t)
+	s := &SmtpSender{Addr: addr, From: "ibc@example.com", To: []string{"risk@example.com"}}
+	if err := s.Send(42, testAlert); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-data:
+		if !strings.Contains(msg, "Subject: [FIRING] DU12345 Low cushion") {
+			t.Fatalf("unexpected message %s", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if Backoff(1) != time.Minute || Backoff(2) != 2*time.Minute || Backoff(7) != time.Hour || Backoff(60) != time.Hour {
+		t.Fatal("unexpected backoff")
+	}
+}
@@ -0,0 +1,148 @@
+package archive
+
+import (
+	"fmt"
+	"time"
+)
+
+// Format is the file format of an archive.
+type Format string
+
+const (
+	NDJSON  Format = "ndjson"
+	Parquet Format = "parquet"
+)
+
+// NewFormat returns the Format of the passed name.
+func NewFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case NDJSON, Parquet:
+		return f, nil
+	}
+	return "", fmt.Errorf("archive: format '%s' is not ndjson or parquet", name)
+}
+
+// Archive file names (without extension), in the order they are written.
+const (
+	AccountsFile  = "accounts"
+	ContractsFile = "contracts"
+	SnapshotsFile = "snapshots"
+	AmountsFile   = "amounts"
+	FxRatesFile   = "fx_rates"
+	ValuesFile    = "values"
+	PositionsFile = "positions"
+	ManifestFile  = "manifest.json"
+)
+
+// Options select the snapshots to export. Only snapshots created between From
+// and To (inclusive) with an ID greater than After are exported.
+type Options struct {
+	From  time.Time
+	To    time.Time
+	After int64
+}
+
+// Manifest describes an export. HighWaterMark is the largest snapshot ID
+// exported (or After if none were), and should be passed as After to the next
+// incremental export. Counts holds the number of records in each file.
+type Manifest struct {
+	Format        Format
+	Created       time.Time
+	From          time.Time
+	To            time.Time
+	After         int64
+	HighWaterMark int64
+	Counts        map[string]int64
+}
+
+type Account struct {
+	AccountCode string `meddler:"account_code"`
+}
+
+// Snapshot is an account snapshot. ValidUntil is the latest time it was found
+// unchanged when exported (being Created if never). BaseCurrency is NIL if it
+// was unknown.
+type Snapshot struct {
+	Id           int64     `meddler:"id"`
+	AccountCode  string    `meddler:"account_code"`
+	Created      time.Time `meddler:"created,utctime"`
+	ValidUntil   time.Time `meddler:"valid_until,utctime"`
+	BaseCurrency string    `meddler:"base_currency"`
+}
+
+type Contract struct {
+	Id           int64     `meddler:"id"`
+	Created      time.Time `meddler:"created,utctime"`
+	IbContractId int64     `meddler:"ib_contract_id"`
+	Currency     string    `meddler:"currency"`
+	Symbol       string    `meddler:"symbol"`
+	LocalSymbol  string    `meddler:"local_symbol"`
+	SecurityType string    `meddler:"security_type"`
+	Exchange     string    `meddler:"exchange"`
+}
+
+// Amount holds the balances of a snapshot in one currency (or IB's BASE rollup
+// if Base is true). Monetary values are in major units of the Currency, with
+// values IB did not report being zero.
+type Amount struct {
+	SnapshotId               int64
+	Currency                 string
+	Base                     bool
+	AccountType              string
+	Cushion                  float64
+	LookAheadNextChange      int16
+	AccruedCash              float64
+	AvailableFunds           float64
+	BuyingPower              float64
+	EquityWithLoanValue      float64
+	ExcessLiquidity          float64
+	FullAvailableFunds       float64
+	FullExcessLiquidity      float64
+	FullInitMarginReq        float64
+	FullMaintMarginReq       float64
+	GrossPositionValue       float64
+	InitMarginReq            float64
+	LookAheadAvailableFunds  float64
+	LookAheadExcessLiquidity float64
+	LookAheadInitMarginReq   float64
+	LookAheadMaintMarginReq  float64
+	MaintMarginReq           float64
+	NetLiquidation           float64
+	TotalCashBalance         float64
+	TotalCashValue           float64
+}
+
+type FxRate struct {
+	SnapshotId int64   `meddler:"account_snapshot_id"`
+	Currency   string  `meddler:"currency"`
+	Rate       float64 `meddler:"rate"`
+}
+
+type Value struct {
+	SnapshotId int64  `meddler:"account_snapshot_id"`
+	Key        string `meddler:"key_name"`
+	Currency   string `meddler:"currency"`
+	Segment    string `meddler:"segment"`
+	Value      string `meddler:"value"`
+}
+
+type Position struct {
+	SnapshotId    int64   `meddler:"account_snapshot_id"`
+	ContractId    int64   `meddler:"contract_id"`
+	Position      int64   `meddler:"pos"`
+	MarketPrice   float64 `meddler:"market_price"`
+	MarketValue   float64 `meddler:"market_value"`
+	AverageCost   float64 `meddler:"average_cost"`
+	UnrealizedPNL float64 `meddler:"unrealized_pnl"`
+	RealizedPNL   float64 `meddler:"realized_pnl"`
+}
+
+// snapshotRecord is a record belonging to a snapshot.
+type snapshotRecord interface {
+	snapshot() int64
+}
+
+func (a *Amount) snapshot() int64   { return a.SnapshotId }
+func (r *FxRate) snapshot() int64   { return r.SnapshotId }
+func (v *Value) snapshot() int64    { return v.SnapshotId }
+func (p *Position) snapshot() int64 { return p.SnapshotId }
@@ -0,0 +1,21 @@
+/*
+Package archive exports account snapshot history to files for loading into
+other systems, such as a data warehouse, and imports NDJSON archives back into
+a database.
+
+An archive is a directory holding one file per table (accounts, contracts,
+snapshots, amounts, fx_rates, values and positions) in NDJSON or Parquet
+format, plus a manifest.json describing the export. Records are denormalised
+so they are meaningful without the originating database: accounts, currencies,
+symbols, exchanges and security types are identified by name rather than by
+surrogate key. Snapshots and contracts keep their IDs so that amounts, rates,
+values and positions can reference them.
+
+Exports are incremental when given the high-water mark of a previous export,
+being the largest snapshot ID it included. Every contract referenced by an
+exported position is included, so each archive is self-contained.
+
+Imports resolve names back to surrogate keys (creating records as needed) and
+skip snapshots the account already has at the same time, so are idempotent.
+*/
+package archive
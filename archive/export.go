@@ -0,0 +1,265 @@
+package archive
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/parquet"
+	"github.com/russross/meddler"
+)
+
+// selected restricts account_snapshot to the exported snapshots, given the
+// After, From and To options as $1, $2 and $3.
+const selected = "account_snapshot.id > $1 AND account_snapshot.created >= $2 AND account_snapshot.created <= $3"
+
+// snapshotIds selects the IDs of the exported snapshots.
+const snapshotIds = "SELECT id FROM account_snapshot WHERE " + selected
+
+// encoder writes the records of a single archive file.
+type encoder interface {
+	Write(record interface{}) error
+	Close() error
+}
+
+type ndjsonEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Write(record interface{}) error {
+	return e.enc.Encode(record)
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return e.w.Flush()
+}
+
+func newEncoder(w io.Writer, f Format, prototype interface{}) (encoder, error) {
+	if f == Parquet {
+		return parquet.NewWriter(w, prototype)
+	}
+	bw := bufio.NewWriter(w)
+	return &ndjsonEncoder{bw, json.NewEncoder(bw)}, nil
+}
+
+// Export writes the snapshots selected by the options, along with the accounts
+// and contracts they reference, to files in dir. Every file is written from a
+// single repeatable read transaction, so the files are mutually consistent.
+// The returned Manifest is also written to the directory.
+func Export(db *sql.DB, dir string, f Format, o Options) (*Manifest, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY")
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{Format: f, Created: time.Now().UTC(), From: o.From, To: o.To, After: o.After,
+		Counts: make(map[string]int64)}
+	args := []interface{}{o.After, o.From, o.To}
+	err = tx.QueryRow("SELECT COALESCE(MAX(id), $1) FROM account_snapshot WHERE "+selected, args...).Scan(&m.HighWaterMark)
+	if err != nil {
+		return nil, err
+	}
+
+	currencies, err := core.NewCurrencies(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var types []*core.AccountType
+	err = meddler.QueryAll(tx, &types, "SELECT * FROM account_type")
+	if err != nil {
+		return nil, err
+	}
+	typeDescs := make(map[int64]string)
+	for _, t := range types {
+		typeDescs[t.Id] = t.TypeDescription
+	}
+
+	e := &exporter{tx: tx, dir: dir, format: f, args: args, m: m}
+
+	account := new(Account)
+	e.write(AccountsFile, account, account,
+		"SELECT account_code FROM account WHERE id IN (SELECT account_id FROM account_snapshot WHERE "+selected+") "+
+			"ORDER BY account_code", nil)
+
+	contract := new(Contract)
+	e.write(ContractsFile, contract, contract,
+		"SELECT contract.id, contract.created, ib_contract_id, alphabetic_code AS currency, s.symbol, "+
+			"ls.symbol AS local_symbol, security_type, exchange "+
+			"FROM contract, iso_4217, symbol AS s, symbol AS ls, security_type, exchange "+
+			"WHERE iso_4217.iso_4217_code = contract.iso_4217_code AND s.id = contract.symbol_id AND "+
+			"ls.id = contract.local_symbol_id AND security_type.id = contract.security_type_id AND "+
+			"exchange.id = contract.primary_exchange_id AND contract.id IN "+
+			"(SELECT contract_id FROM account_position WHERE account_snapshot_id IN ("+snapshotIds+")) "+
+			"ORDER BY contract.id", nil)
+
+	snapshot := new(Snapshot)
+	e.write(SnapshotsFile, snapshot, snapshot,
+		"SELECT account_snapshot.id, account_code, created, COALESCE(valid_until, created) AS valid_until, "+
+			"alphabetic_code AS base_currency "+
+			"FROM account_snapshot, account, iso_4217 "+
+			"WHERE account.id = account_snapshot.account_id AND iso_4217.iso_4217_code = base_iso_4217_code AND "+
+			selected+" ORDER BY account_snapshot.id", nil)
+
+	amount := new(core.AccountAmount)
+	e.write(AmountsFile, amount, &Amount{},
+		"SELECT * FROM account_amount WHERE account_snapshot_id IN ("+snapshotIds+") "+
+			"ORDER BY account_snapshot_id, base DESC, iso_4217_code",
+		func() (interface{}, error) {
+			return newAmount(amount, currencies, typeDescs)
+		})
+
+	rate := new(FxRate)
+	e.write(FxRatesFile, rate, rate,
+		"SELECT account_snapshot_id, alphabetic_code AS currency, rate FROM v_fx_rate "+
+			"WHERE account_snapshot_id IN ("+snapshotIds+") ORDER BY account_snapshot_id, alphabetic_code", nil)
+
+	value := new(Value)
+	e.write(ValuesFile, value, value,
+		"SELECT account_snapshot_id, key_name, currency, segment, value FROM v_account_value "+
+			"WHERE account_snapshot_id IN ("+snapshotIds+") ORDER BY account_snapshot_id, key_name, segment, currency", nil)
+
+	position := new(Position)
+	e.write(PositionsFile, position, position,
+		"SELECT account_snapshot_id, contract_id, pos, market_price, market_value, average_cost, unrealized_pnl, "+
+			"realized_pnl FROM account_position WHERE account_snapshot_id IN ("+snapshotIds+") "+
+			"ORDER BY account_snapshot_id, contract_id", nil)
+
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return m, ioutil.WriteFile(filepath.Join(dir, ManifestFile), b, 0644)
+}
+
+// exporter writes archive files, retaining the first error encountered.
+type exporter struct {
+	tx     *sql.Tx
+	dir    string
+	format Format
+	args   []interface{}
+	m      *Manifest
+	err    error
+}
+
+// write streams each row of the query into dst, writing the record returned by
+// convert (or dst itself if convert is nil) to the named file. The prototype
+// is the type of the records written.
+func (e *exporter) write(name string, dst interface{}, prototype interface{}, query string,
+	convert func() (interface{}, error)) {
+	if e.err != nil {
+		return
+	}
+
+	file, err := os.Create(filepath.Join(e.dir, name+"."+string(e.format)))
+	if err != nil {
+		e.err = err
+		return
+	}
+	defer file.Close()
+
+	enc, err := newEncoder(file, e.format, prototype)
+	if err != nil {
+		e.err = err
+		return
+	}
+
+	rows, err := e.tx.Query(query, e.args...)
+	if err != nil {
+		e.err = err
+		return
+	}
+	defer rows.Close()
+
+	count := int64(0)
+	for {
+		err = meddler.Scan(rows, dst)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			e.err = err
+			return
+		}
+
+		record := dst
+		if convert != nil {
+			record, err = convert()
+			if err != nil {
+				e.err = err
+				return
+			}
+		}
+		if err = enc.Write(record); err != nil {
+			e.err = err
+			return
+		}
+		count++
+	}
+
+	if err = enc.Close(); err != nil {
+		e.err = err
+		return
+	}
+	e.err = file.Close()
+	e.m.Counts[name] = count
+}
+
+// newAmount converts a stored AccountAmount to its archived form.
+func newAmount(a *core.AccountAmount, c core.Currencies, types map[int64]string) (*Amount, error) {
+	amt := &Amount{
+		SnapshotId:          a.AccountSnapshotId,
+		Currency:            c[a.Iso4217Code].AlphabeticCode,
+		Base:                a.Base,
+		AccountType:         types[a.AccountType],
+		Cushion:             a.Cushion,
+		LookAheadNextChange: a.LookAheadNextChange,
+	}
+
+	fields := map[*float64]core.Monetary{
+		&amt.AccruedCash:              a.AccruedCash,
+		&amt.AvailableFunds:           a.AvailableFunds,
+		&amt.BuyingPower:              a.BuyingPower,
+		&amt.EquityWithLoanValue:      a.EquityWithLoanValue,
+		&amt.ExcessLiquidity:          a.ExcessLiquidity,
+		&amt.FullAvailableFunds:       a.FullAvailableFunds,
+		&amt.FullExcessLiquidity:      a.FullExcessLiquidity,
+		&amt.FullInitMarginReq:        a.FullInitMarginReq,
+		&amt.FullMaintMarginReq:       a.FullMaintMarginReq,
+		&amt.GrossPositionValue:       a.GrossPositionValue,
+		&amt.InitMarginReq:            a.InitMarginReq,
+		&amt.LookAheadAvailableFunds:  a.LookAheadAvailableFunds,
+		&amt.LookAheadExcessLiquidity: a.LookAheadExcessLiquidity,
+		&amt.LookAheadInitMarginReq:   a.LookAheadInitMarginReq,
+		&amt.LookAheadMaintMarginReq:  a.LookAheadMaintMarginReq,
+		&amt.MaintMarginReq:           a.MaintMarginReq,
+		&amt.NetLiquidation:           a.NetLiquidation,
+		&amt.TotalCashBalance:         a.TotalCashBalance,
+		&amt.TotalCashValue:           a.TotalCashValue,
+	}
+	for field, m := range fields {
+		v, err := m.Float(c)
+		if err != nil {
+			return nil, err
+		}
+		*field = v
+	}
+	return amt, nil
+}
@@ -0,0 +1,126 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/gateway"
+)
+
+func TestNewFormat(t *testing.T) {
+	for _, name := range []string{"ndjson", "parquet"} {
+		if f, err := NewFormat(name); err != nil || string(f) != name {
+			t.Fatalf("%s returned %s %v", name, f, err)
+		}
+	}
+	if _, err := NewFormat("csv"); err == nil {
+		t.Fatal("expected error for csv")
+	}
+}
+
+func TestNewAmount(t *testing.T) {
+	c := core.Currencies{
+		0:  core.Iso4217{Iso4217Code: 0, AlphabeticCode: "NIL"},
+		36: core.Iso4217{Iso4217Code: 36, MinorUnit: 2, AlphabeticCode: "AUD"},
+	}
+	a := &core.AccountAmount{AccountSnapshotId: 7, Iso4217Code: 36, Base: true, AccountType: 3, Cushion: 0.5,
+		NetLiquidation: core.Monetary{Iso4217Code: 36, Amount: 6269}, TotalCashValue: core.Monetary{Iso4217Code: 36, Amount: -150}}
+
+	amt, err := newAmount(a, c, map[int64]string{3: "INDIVIDUAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amt.SnapshotId != 7 || amt.Currency != "AUD" || !amt.Base || amt.AccountType != "INDIVIDUAL" || amt.Cushion != 0.5 {
+		t.Fatalf("unexpected amount %+v", amt)
+	}
+	if amt.NetLiquidation != 62.69 || amt.TotalCashValue != -1.5 || amt.AccruedCash != 0 {
+		t.Fatalf("unexpected monetary values %+v", amt)
+	}
+}
+
+// readLines returns the number of lines in the archive file.
+func readLines(t *testing.T, name string) int {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	lines := 0
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		lines++
+	}
+	return lines
+}
+
+func TestExport(t *testing.T) {
+	c := core.NewTestConfig(t)
+	ctx, err := core.NewContext(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	gateway.TestSimpleFeedPublishesDoneMessage(t, &ff, 15*time.Second)
+
+	latest := int64(0)
+	if err := ctx.DB.QueryRow("SELECT MAX(id) FROM account_snapshot").Scan(&latest); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "ibconnect-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	o := Options{To: time.Now().UTC(), After: latest - 1}
+	m, err := Export(ctx.DB, dir, NDJSON, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.HighWaterMark != latest || m.Counts[SnapshotsFile] != 1 || m.Counts[AccountsFile] != 1 || m.Counts[AmountsFile] == 0 {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+
+	for name, count := range m.Counts {
+		if lines := readLines(t, filepath.Join(dir, name+".ndjson")); int64(lines) != count {
+			t.Fatalf("%s has %d lines but manifest counts %d", name, lines, count)
+		}
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, SnapshotsFile+".ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := Snapshot{}
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Id != latest || snapshot.AccountCode == "" || snapshot.BaseCurrency == "" {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+
+	// incremental export from the high-water mark has nothing new
+	o.After = m.HighWaterMark
+	m, err = Export(ctx.DB, dir, Parquet, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.HighWaterMark != latest || m.Counts[SnapshotsFile] != 0 || m.Counts[PositionsFile] != 0 {
+		t.Fatalf("unexpected incremental manifest %+v", m)
+	}
+	b, err = ioutil.ReadFile(filepath.Join(dir, SnapshotsFile+".parquet"))
+	if err != nil || len(b) < 8 || string(b[:4]) != "PAR1" {
+		t.Fatalf("invalid Parquet file (%v)", err)
+	}
+}
@@ -0,0 +1,360 @@
+package archive
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+// Result reports the snapshots processed by Import.
+type Result struct {
+	Imported int64
+	Skipped  int64
+}
+
+// Import loads an NDJSON archive from dir. Accounts, currencies, account types,
+// value keys and contracts (with their symbols, security types and exchanges)
+// are resolved by name through the same get-or-create logic as the account
+// feed, so archives from other databases can be merged. Each snapshot is
+// imported in its own transaction. A snapshot is skipped if the account already
+// has one created at the same time, so importing an archive again (or archives
+// that overlap) is harmless.
+func Import(db *sql.DB, dir string) (*Result, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		m := Manifest{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("archive: %s: %v", ManifestFile, err)
+		}
+		if m.Format != NDJSON {
+			return nil, fmt.Errorf("archive: only ndjson archives can be imported, not %s", m.Format)
+		}
+	}
+
+	currencies, err := core.NewCurrencies(db)
+	if err != nil {
+		return nil, err
+	}
+	im := &importer{db: db, currencies: currencies, contracts: make(map[int64]Contract),
+		resolved: make(map[int64]int64)}
+
+	contracts, err := openStream(dir, ContractsFile, Contract{})
+	if err != nil {
+		return nil, err
+	}
+	defer contracts.Close()
+	for contracts.next != nil {
+		c := contracts.next.(*Contract)
+		im.contracts[c.Id] = *c
+		if err := contracts.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	snapshots, err := openStream(dir, SnapshotsFile, Snapshot{})
+	if err != nil {
+		return nil, err
+	}
+	defer snapshots.Close()
+
+	var streams []*stream
+	for _, f := range []struct {
+		name      string
+		prototype interface{}
+	}{{AmountsFile, Amount{}}, {FxRatesFile, FxRate{}}, {ValuesFile, Value{}}, {PositionsFile, Position{}}} {
+		s, err := openStream(dir, f.name, f.prototype)
+		if err != nil {
+			return nil, err
+		}
+		defer s.Close()
+		streams = append(streams, s)
+	}
+
+	result := &Result{}
+	for snapshots.next != nil {
+		s := snapshots.next.(*Snapshot)
+		records := make(map[string][]interface{})
+		for _, stream := range streams {
+			records[stream.name], err = stream.take(s.Id)
+			if err != nil {
+				return result, err
+			}
+		}
+
+		imported, err := im.snapshot(s, records)
+		if err != nil {
+			return result, fmt.Errorf("archive: snapshot %d: %v", s.Id, err)
+		}
+		if imported {
+			result.Imported++
+		} else {
+			result.Skipped++
+		}
+
+		if err := snapshots.advance(); err != nil {
+			return result, err
+		}
+	}
+
+	for _, stream := range streams {
+		if stream.next != nil {
+			return result, fmt.Errorf("archive: %s references snapshot %d which is not in %s", stream.name,
+				stream.next.(snapshotRecord).snapshot(), SnapshotsFile)
+		}
+	}
+	return result, nil
+}
+
+// stream reads the records of an NDJSON archive file in order, holding the next
+// record (or nil at the end of the file).
+type stream struct {
+	name string
+	file *os.File
+	dec  *json.Decoder
+	typ  reflect.Type
+	next interface{}
+}
+
+func openStream(dir string, name string, prototype interface{}) (*stream, error) {
+	f, err := os.Open(filepath.Join(dir, name+"."+string(NDJSON)))
+	if err != nil {
+		return nil, err
+	}
+	s := &stream{name: name, file: f, dec: json.NewDecoder(bufio.NewReader(f)), typ: reflect.TypeOf(prototype)}
+	return s, s.advance()
+}
+
+func (s *stream) advance() error {
+	s.next = nil
+	if !s.dec.More() {
+		return nil
+	}
+	record := reflect.New(s.typ).Interface()
+	if err := s.dec.Decode(record); err != nil {
+		return fmt.Errorf("archive: %s: %v", s.name, err)
+	}
+	s.next = record
+	return nil
+}
+
+// take returns the records of the snapshot. Records must be ordered by
+// snapshot ID, as they are in the snapshots file.
+func (s *stream) take(snapshotId int64) ([]interface{}, error) {
+	var records []interface{}
+	for s.next != nil {
+		id := s.next.(snapshotRecord).snapshot()
+		if id > snapshotId {
+			break
+		}
+		if id < snapshotId {
+			return nil, fmt.Errorf("archive: %s snapshot %d is out of order or not in %s", s.name, id, SnapshotsFile)
+		}
+		records = append(records, s.next)
+		if err := s.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+func (s *stream) Close() error {
+	return s.file.Close()
+}
+
+// importer stores archived snapshots, caching the database ID of each
+// archived contract once resolved.
+type importer struct {
+	db         *sql.DB
+	currencies core.Currencies
+	contracts  map[int64]Contract
+	resolved   map[int64]int64
+}
+
+// snapshot stores the snapshot and its records in a single transaction,
+// returning false if the account already has a snapshot at the same time
+// (whose ValidUntil is extended if the archive's is later).
+func (im *importer) snapshot(s *Snapshot, records map[string][]interface{}) (bool, error) {
+	tx, err := im.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	account, err := core.GetAccount(tx, s.AccountCode)
+	if err != nil {
+		return false, err
+	}
+
+	existing := new(core.AccountSnapshot)
+	err = meddler.QueryRow(tx, existing, "SELECT * FROM account_snapshot WHERE account_id = $1 AND created = $2",
+		account.Id, s.Created.UTC())
+	if err == nil {
+		// the archive may have found the snapshot unchanged for longer
+		if s.ValidUntil.After(existing.Observed()) {
+			_, err = tx.Exec("UPDATE account_snapshot SET valid_until = $1 WHERE id = $2", s.ValidUntil.UTC(), existing.Id)
+			if err != nil {
+				return false, err
+			}
+		}
+		return false, tx.Commit()
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+
+	base, err := im.currency(s.BaseCurrency)
+	if err != nil {
+		return false, err
+	}
+	snap := &core.AccountSnapshot{AccountId: account.Id, Created: s.Created.UTC(), BaseIso4217Code: base.Iso4217Code}
+	if s.ValidUntil.After(s.Created) {
+		snap.ValidUntil = s.ValidUntil.UTC()
+	}
+	err = meddler.Insert(tx, "account_snapshot", snap)
+	if err != nil {
+		return false, err
+	}
+
+	for _, r := range records[AmountsFile] {
+		amt, err := im.amount(tx, snap, r.(*Amount))
+		if err != nil {
+			return false, err
+		}
+		if err := meddler.Insert(tx, "account_amount", amt); err != nil {
+			return false, err
+		}
+	}
+
+	for _, r := range records[FxRatesFile] {
+		rate := r.(*FxRate)
+		iso, err := im.currency(rate.Currency)
+		if err != nil {
+			return false, err
+		}
+		err = meddler.Insert(tx, "fx_rate", &core.FxRate{AccountSnapshotId: snap.Id, Iso4217Code: iso.Iso4217Code,
+			Rate: rate.Rate})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for _, r := range records[ValuesFile] {
+		v := r.(*Value)
+		key, err := core.GetAccountValueKey(tx, v.Key)
+		if err != nil {
+			return false, err
+		}
+		err = meddler.Insert(tx, "account_value", &core.AccountValue{AccountSnapshotId: snap.Id,
+			AccountValueKeyId: key.Id, Currency: v.Currency, Segment: v.Segment, Value: v.Value})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for _, r := range records[PositionsFile] {
+		p := r.(*Position)
+		contractId, err := im.contract(tx, p.ContractId)
+		if err != nil {
+			return false, err
+		}
+		err = meddler.Insert(tx, "account_position", &core.AccountPosition{AccountSnapshotId: snap.Id,
+			SnapshotCreated: snap.Created, ContractId: contractId, Position: p.Position, MarketPrice: p.MarketPrice,
+			MarketValue: p.MarketValue, AverageCost: p.AverageCost, UnrealizedPNL: p.UnrealizedPNL,
+			RealizedPNL: p.RealizedPNL})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+func (im *importer) currency(alphabeticCode string) (core.Iso4217, error) {
+	iso, ok := im.currencies.Find(alphabeticCode)
+	if !ok {
+		return iso, fmt.Errorf("unknown currency '%s'", alphabeticCode)
+	}
+	return iso, nil
+}
+
+// contract returns the database ID of the archived contract.
+func (im *importer) contract(tx *sql.Tx, archivedId int64) (int64, error) {
+	if id, ok := im.resolved[archivedId]; ok {
+		return id, nil
+	}
+
+	c, ok := im.contracts[archivedId]
+	if !ok {
+		return 0, fmt.Errorf("contract %d is not in %s", archivedId, ContractsFile)
+	}
+
+	resolved, err := core.ResolveContract(tx, core.ContractDetails{
+		IbContractId:    c.IbContractId,
+		Currency:        c.Currency,
+		Symbol:          c.Symbol,
+		LocalSymbol:     c.LocalSymbol,
+		SecurityType:    c.SecurityType,
+		PrimaryExchange: c.Exchange,
+	}, c.Created.UTC())
+	if err != nil {
+		return 0, err
+	}
+	im.resolved[archivedId] = resolved.Id
+	return resolved.Id, nil
+}
+
+// amount converts an archived Amount to an AccountAmount of the snapshot.
+func (im *importer) amount(tx *sql.Tx, snap *core.AccountSnapshot, a *Amount) (*core.AccountAmount, error) {
+	iso, err := im.currency(a.Currency)
+	if err != nil {
+		return nil, err
+	}
+	accountType, err := core.GetAccountType(tx, a.AccountType)
+	if err != nil {
+		return nil, err
+	}
+
+	m := func(v float64) core.Monetary {
+		return core.Monetary{Iso4217Code: iso.Iso4217Code, Amount: int64(math.Round(v * math.Pow10(int(iso.MinorUnit))))}
+	}
+	return &core.AccountAmount{
+		AccountSnapshotId:        snap.Id,
+		SnapshotCreated:          snap.Created,
+		Iso4217Code:              iso.Iso4217Code,
+		Base:                     a.Base,
+		AccountType:              accountType.Id,
+		Cushion:                  a.Cushion,
+		LookAheadNextChange:      a.LookAheadNextChange,
+		AccruedCash:              m(a.AccruedCash),
+		AvailableFunds:           m(a.AvailableFunds),
+		BuyingPower:              m(a.BuyingPower),
+		EquityWithLoanValue:      m(a.EquityWithLoanValue),
+		ExcessLiquidity:          m(a.ExcessLiquidity),
+		FullAvailableFunds:       m(a.FullAvailableFunds),
+		FullExcessLiquidity:      m(a.FullExcessLiquidity),
+		FullInitMarginReq:        m(a.FullInitMarginReq),
+		FullMaintMarginReq:       m(a.FullMaintMarginReq),
+		GrossPositionValue:       m(a.GrossPositionValue),
+		InitMarginReq:            m(a.InitMarginReq),
+		LookAheadAvailableFunds:  m(a.LookAheadAvailableFunds),
+		LookAheadExcessLiquidity: m(a.LookAheadExcessLiquidity),
+		LookAheadInitMarginReq:   m(a.LookAheadInitMarginReq),
+		LookAheadMaintMarginReq:  m(a.LookAheadMaintMarginReq),
+		MaintMarginReq:           m(a.MaintMarginReq),
+		NetLiquidation:           m(a.NetLiquidation),
+		TotalCashBalance:         m(a.TotalCashBalance),
+		TotalCashValue:           m(a.TotalCashValue),
+	}, nil
+}
@@ -0,0 +1,174 @@
+package archive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/gateway"
+)
+
+func writeFile(t *testing.T, dir string, name string, lines ...string) {
+	err := ioutil.WriteFile(filepath.Join(dir, name+".ndjson"), []byte(strings.Join(lines, "\n")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamTake(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ibconnect-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile(t, dir, FxRatesFile,
+		`{"SnapshotId":3,"Currency":"USD","Rate":1}`,
+		`{"SnapshotId":3,"Currency":"AUD","Rate":0.7}`,
+		`{"SnapshotId":5,"Currency":"USD","Rate":1}`,
+		`{"SnapshotId":4,"Currency":"USD","Rate":1}`)
+
+	s, err := openStream(dir, FxRatesFile, FxRate{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	records, err := s.take(2)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("snapshot 2 returned %d records %v", len(records), err)
+	}
+	records, err = s.take(3)
+	if err != nil || len(records) != 2 || records[1].(*FxRate).Currency != "AUD" {
+		t.Fatalf("snapshot 3 returned %v %v", records, err)
+	}
+	if _, err = s.take(5); err == nil {
+		t.Fatal("expected error for snapshot 4 after snapshot 5")
+	}
+}
+
+func TestImportRejectsParquet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ibconnect-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := json.Marshal(Manifest{Format: Parquet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ManifestFile), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Import(nil, dir); err == nil || !strings.Contains(err.Error(), "parquet") {
+		t.Fatalf("expected parquet to be rejected, got %v", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	c := core.NewTestConfig(t)
+	ctx, err := core.NewContext(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	gateway.TestSimpleFeedPublishesDoneMessage(t, &ff, 15*time.Second)
+
+	latest := int64(0)
+	if err := ctx.DB.QueryRow("SELECT MAX(id) FROM account_snapshot").Scan(&latest); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "ibconnect-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m, err := Export(ctx.DB, dir, NDJSON, Options{To: time.Now().UTC(), After: latest - 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Counts[SnapshotsFile] != 1 {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+
+	// importing the unchanged archive finds the snapshot already stored
+	r, err := Import(ctx.DB, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Imported != 0 || r.Skipped != 1 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+
+	// move the snapshot back in time so it is imported as a new one
+	b, err := ioutil.ReadFile(filepath.Join(dir, SnapshotsFile+".ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := Snapshot{}
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	snapshot.Created = snapshot.Created.Add(-time.Second)
+	snapshot.ValidUntil = snapshot.ValidUntil.Add(-time.Second)
+	b, err = json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, SnapshotsFile, string(b))
+
+	r, err = Import(ctx.DB, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := int64(0)
+	if err := ctx.DB.QueryRow("SELECT MAX(id) FROM account_snapshot").Scan(&imported); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, table := range []string{"account_amount", "fx_rate", "account_value", "account_position"} {
+			if _, err := ctx.DB.Exec("DELETE FROM "+table+" WHERE account_snapshot_id = $1", imported); err != nil {
+				t.Error(err)
+			}
+		}
+		if _, err := ctx.DB.Exec("DELETE FROM account_snapshot WHERE id = $1", imported); err != nil {
+			t.Error(err)
+		}
+	}()
+	if r.Imported != 1 || r.Skipped != 0 || imported == latest {
+		t.Fatalf("unexpected result %+v", r)
+	}
+
+	for _, table := range []string{"account_amount", "fx_rate", "account_value", "account_position"} {
+		original, copied := 0, 0
+		q := "SELECT COUNT(*) FROM " + table + " WHERE account_snapshot_id = $1"
+		if err := ctx.DB.QueryRow(q, latest).Scan(&original); err != nil {
+			t.Fatal(err)
+		}
+		if err := ctx.DB.QueryRow(q, imported).Scan(&copied); err != nil {
+			t.Fatal(err)
+		}
+		if original != copied {
+			t.Fatalf("%s has %d rows for the original snapshot but %d imported", table, original, copied)
+		}
+	}
+
+	// a second import of the same archive skips the snapshot
+	r, err = Import(ctx.DB, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Imported != 0 || r.Skipped != 1 {
+		t.Fatalf("unexpected repeated result %+v", r)
+	}
+}
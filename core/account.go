@@ -12,10 +12,23 @@ type Account struct {
 	AccountCode string `meddler:"account_code"`
 }
 
+// AccountSnapshot is the state of an account when Created. ValidUntil is the
+// latest time the state was observed unchanged, or zero if it was only
+// observed when Created.
 type AccountSnapshot struct {
-	Id        int64     `meddler:"id,pk"`
-	AccountId int64     `meddler:"account_id"`
-	Created   time.Time `meddler:"created,utctime"`
+	Id              int64     `meddler:"id,pk"`
+	AccountId       int64     `meddler:"account_id"`
+	Created         time.Time `meddler:"created,utctime"`
+	BaseIso4217Code int16     `meddler:"base_iso_4217_code"`
+	ValidUntil      time.Time `meddler:"valid_until,utctimez"`
+}
+
+// Observed returns the latest time the snapshot was known to be current.
+func (s AccountSnapshot) Observed() time.Time {
+	if s.ValidUntil.IsZero() {
+		return s.Created
+	}
+	return s.ValidUntil
 }
 
 type AccountSnapshotLatest struct {
@@ -23,35 +36,42 @@ type AccountSnapshotLatest struct {
 	Latest      time.Time `meddler:"latest,utctime"`
 }
 
+// AccountAmount holds a snapshot's balances in one currency. SnapshotCreated
+// is the snapshot's Created, which partitions the table by month.
 type AccountAmount struct {
-	Id                       int64    `meddler:"id,pk"`
-	AccountSnapshotId        int64    `meddler:"account_snapshot_id"`
-	AccountType              int64    `meddler:"account_type_id"`
-	Cushion                  float64  `meddler:"cushion"`
-	LookAheadNextChange      int16    `meddler:"look_ahead_next_change"`
-	AccruedCash              Monetary `meddler:"accrued_cash,monetary"`
-	AvailableFunds           Monetary `meddler:"available_funds,monetary"`
-	BuyingPower              Monetary `meddler:"buying_power,monetary"`
-	EquityWithLoanValue      Monetary `meddler:"excess_liquidity,monetary"`
-	ExcessLiquidity          Monetary `meddler:"equity_with_loan_value,monetary"`
-	FullAvailableFunds       Monetary `meddler:"full_available_funds,monetary"`
-	FullExcessLiquidity      Monetary `meddler:"full_excess_liquidity,monetary"`
-	FullInitMarginReq        Monetary `meddler:"full_init_margin_req,monetary"`
-	FullMaintMarginReq       Monetary `meddler:"full_maint_margin_req,monetary"`
-	GrossPositionValue       Monetary `meddler:"gross_position_value,monetary"`
-	InitMarginReq            Monetary `meddler:"init_margin_req,monetary"`
-	LookAheadAvailableFunds  Monetary `meddler:"look_ahead_available_funds,monetary"`
-	LookAheadExcessLiquidity Monetary `meddler:"look_ahead_excess_liquidity,monetary"`
-	LookAheadInitMarginReq   Monetary `meddler:"look_ahead_init_margin_req,monetary"`
-	LookAheadMaintMarginReq  Monetary `meddler:"look_ahead_maint_margin_req,monetary"`
-	MaintMarginReq           Monetary `meddler:"maint_margin_req,monetary"`
-	NetLiquidation           Monetary `meddler:"net_liquidation,monetary"`
-	TotalCashBalance         Monetary `meddler:"total_cash_balance,monetary"`
-	TotalCashValue           Monetary `meddler:"total_cash_value,monetary"`
+	Id                       int64     `meddler:"id,pk"`
+	AccountSnapshotId        int64     `meddler:"account_snapshot_id"`
+	SnapshotCreated          time.Time `meddler:"snapshot_created,utctime"`
+	Iso4217Code              int16     `meddler:"iso_4217_code"`
+	Base                     bool      `meddler:"base"`
+	AccountType              int64     `meddler:"account_type_id"`
+	Cushion                  float64   `meddler:"cushion"`
+	LookAheadNextChange      int16     `meddler:"look_ahead_next_change"`
+	AccruedCash              Monetary  `meddler:"accrued_cash,monetary"`
+	AvailableFunds           Monetary  `meddler:"available_funds,monetary"`
+	BuyingPower              Monetary  `meddler:"buying_power,monetary"`
+	EquityWithLoanValue      Monetary  `meddler:"excess_liquidity,monetary"`
+	ExcessLiquidity          Monetary  `meddler:"equity_with_loan_value,monetary"`
+	FullAvailableFunds       Monetary  `meddler:"full_available_funds,monetary"`
+	FullExcessLiquidity      Monetary  `meddler:"full_excess_liquidity,monetary"`
+	FullInitMarginReq        Monetary  `meddler:"full_init_margin_req,monetary"`
+	FullMaintMarginReq       Monetary  `meddler:"full_maint_margin_req,monetary"`
+	GrossPositionValue       Monetary  `meddler:"gross_position_value,monetary"`
+	InitMarginReq            Monetary  `meddler:"init_margin_req,monetary"`
+	LookAheadAvailableFunds  Monetary  `meddler:"look_ahead_available_funds,monetary"`
+	LookAheadExcessLiquidity Monetary  `meddler:"look_ahead_excess_liquidity,monetary"`
+	LookAheadInitMarginReq   Monetary  `meddler:"look_ahead_init_margin_req,monetary"`
+	LookAheadMaintMarginReq  Monetary  `meddler:"look_ahead_maint_margin_req,monetary"`
+	MaintMarginReq           Monetary  `meddler:"maint_margin_req,monetary"`
+	NetLiquidation           Monetary  `meddler:"net_liquidation,monetary"`
+	TotalCashBalance         Monetary  `meddler:"total_cash_balance,monetary"`
+	TotalCashValue           Monetary  `meddler:"total_cash_value,monetary"`
 }
 
 type AccountAmountView struct {
 	AccountSnapshotId        int64   `meddler:"account_snapshot_id,pk" json:"-"`
+	Currency                 string  `meddler:"currency"`
+	Base                     bool    `meddler:"base"`
 	AccountType              string  `meddler:"account_type"`
 	Cushion                  float64 `meddler:"cushion"`
 	LookAheadNextChange      int16   `meddler:"look_ahead_next_change"`
@@ -76,6 +96,68 @@ type AccountAmountView struct {
 	TotalCashValue           string  `meddler:"total_cash_value"`
 }
 
+type AccountValueKey struct {
+	Id      int64  `meddler:"id,pk"`
+	KeyName string `meddler:"key_name"`
+}
+
+type AccountValue struct {
+	Id                int64  `meddler:"id,pk"`
+	AccountSnapshotId int64  `meddler:"account_snapshot_id"`
+	AccountValueKeyId int64  `meddler:"account_value_key_id"`
+	Currency          string `meddler:"currency"`
+	Segment           string `meddler:"segment"`
+	Value             string `meddler:"value"`
+}
+
+type AccountValueView struct {
+	AccountSnapshotId int64  `meddler:"account_snapshot_id,pk" json:"-"`
+	Key               string `meddler:"key_name"`
+	Currency          string `meddler:"currency"`
+	Segment           string `meddler:"segment"`
+	Value             string `meddler:"value"`
+}
+
+type FxRate struct {
+	Id                int64   `meddler:"id,pk"`
+	AccountSnapshotId int64   `meddler:"account_snapshot_id"`
+	Iso4217Code       int16   `meddler:"iso_4217_code"`
+	Rate              float64 `meddler:"rate"`
+}
+
+type CashFlow struct {
+	Id          int64     `meddler:"id,pk"`
+	AccountId   int64     `meddler:"account_id"`
+	Occurred    time.Time `meddler:"occurred,utctime"`
+	Amount      Monetary  `meddler:"amount,monetary"`
+	Description string    `meddler:"description"`
+}
+
+type CashFlowView struct {
+	Id          int64     `meddler:"id,pk"`
+	AccountCode string    `meddler:"account_code" json:"-"`
+	Occurred    time.Time `meddler:"occurred,utctime"`
+	Amount      string    `meddler:"amount"`
+	Description string    `meddler:"description"`
+}
+
+type AccountGroup struct {
+	Id        int64  `meddler:"id,pk"`
+	GroupName string `meddler:"group_name"`
+}
+
+type AccountGroupMember struct {
+	AccountGroupId int64 `meddler:"account_group_id"`
+	AccountId      int64 `meddler:"account_id"`
+}
+
+type ConcentrationThreshold struct {
+	Id             int64   `meddler:"id,pk"`
+	AccountId      int64   `meddler:"account_id,zeroisnull"`
+	AccountGroupId int64   `meddler:"account_group_id,zeroisnull"`
+	Threshold      float64 `meddler:"threshold"`
+}
+
 type SecurityType struct {
 	Id           int64  `meddler:"id,pk"`
 	SecurityType string `meddler:"security_type"`
@@ -102,16 +184,19 @@ type Contract struct {
 	PrimaryExchangeId int64     `meddler:"primary_exchange_id"`
 }
 
+// AccountPosition is a snapshot's position in a contract. SnapshotCreated is
+// the snapshot's Created, which partitions the table by month.
 type AccountPosition struct {
-	Id                int64   `meddler:"id,pk"`
-	AccountSnapshotId int64   `meddler:"account_snapshot_id"`
-	ContractId        int64   `meddler:"contract_id"`
-	Position          int64   `meddler:"pos"`
-	MarketPrice       float64 `meddler:"market_price"`
-	MarketValue       float64 `meddler:"market_value"`
-	AverageCost       float64 `meddler:"average_cost"`
-	UnrealizedPNL     float64 `meddler:"unrealized_pnl"`
-	RealizedPNL       float64 `meddler:"realized_pnl"`
+	Id                int64     `meddler:"id,pk"`
+	AccountSnapshotId int64     `meddler:"account_snapshot_id"`
+	SnapshotCreated   time.Time `meddler:"snapshot_created,utctime"`
+	ContractId        int64     `meddler:"contract_id"`
+	Position          int64     `meddler:"pos"`
+	MarketPrice       float64   `meddler:"market_price"`
+	MarketValue       float64   `meddler:"market_value"`
+	AverageCost       float64   `meddler:"average_cost"`
+	UnrealizedPNL     float64   `meddler:"unrealized_pnl"`
+	RealizedPNL       float64   `meddler:"realized_pnl"`
 }
 
 type AccountPositionView struct {
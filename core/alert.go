@@ -0,0 +1,65 @@
+package core
+
+import "time"
+
+type AlertRule struct {
+	Id         int64   `meddler:"id,pk"`
+	AccountId  int64   `meddler:"account_id,zeroisnull" json:"-"`
+	RuleName   string  `meddler:"rule_name"`
+	Metric     string  `meddler:"metric"`
+	Operator   string  `meddler:"operator"`
+	Threshold  float64 `meddler:"threshold"`
+	Hysteresis float64 `meddler:"hysteresis"`
+	Enabled    bool    `meddler:"enabled"`
+}
+
+type AlertRuleView struct {
+	Id          int64   `meddler:"id,pk"`
+	AccountCode string  `meddler:"account_code"`
+	RuleName    string  `meddler:"rule_name"`
+	Metric      string  `meddler:"metric"`
+	Operator    string  `meddler:"operator"`
+	Threshold   float64 `meddler:"threshold"`
+	Hysteresis  float64 `meddler:"hysteresis"`
+	Enabled     bool    `meddler:"enabled"`
+}
+
+type AlertState struct {
+	AlertRuleId       int64 `meddler:"alert_rule_id"`
+	AccountId         int64 `meddler:"account_id"`
+	AccountSnapshotId int64 `meddler:"account_snapshot_id"`
+	Firing            bool  `meddler:"firing"`
+}
+
+type Alert struct {
+	Id                int64     `meddler:"id,pk"`
+	Created           time.Time `meddler:"created,utctime"`
+	AlertRuleId       int64     `meddler:"alert_rule_id"`
+	AccountSnapshotId int64     `meddler:"account_snapshot_id"`
+	Firing            bool      `meddler:"firing"`
+	Value             float64   `meddler:"value"`
+}
+
+type AlertView struct {
+	Id          int64     `meddler:"id,pk"`
+	Created     time.Time `meddler:"created,utctime"`
+	AccountCode string    `meddler:"account_code"`
+	AlertRuleId int64     `meddler:"alert_rule_id"`
+	RuleName    string    `meddler:"rule_name"`
+	Metric      string    `meddler:"metric"`
+	Operator    string    `meddler:"operator"`
+	Threshold   float64   `meddler:"threshold"`
+	Firing      bool      `meddler:"firing"`
+	Value       float64   `meddler:"value"`
+}
+
+type AlertDelivery struct {
+	Id          int64     `meddler:"id,pk"`
+	AlertId     int64     `meddler:"alert_id"`
+	Notifier    string    `meddler:"notifier"`
+	Attempts    int       `meddler:"attempts"`
+	NextAttempt time.Time `meddler:"next_attempt,utctime"`
+	Delivered   time.Time `meddler:"delivered,utctimez"`
+	Failed      bool      `meddler:"failed"`
+	LastError   string    `meddler:"last_error"`
+}
@@ -0,0 +1,27 @@
+package core
+
+import "time"
+
+type ApiKey struct {
+	Id           int64     `meddler:"id,pk"`
+	Created      time.Time `meddler:"created,utctime"`
+	KeyName      string    `meddler:"key_name"`
+	KeyHash      string    `meddler:"key_hash" json:"-"`
+	Admin        bool      `meddler:"admin"`
+	CertSubject  string    `meddler:"cert_subject,zeroisnull" json:",omitempty"`
+	Pseudonymise bool      `meddler:"pseudonymise"`
+}
+
+type ApiKeyScope struct {
+	Id             int64 `meddler:"id,pk"`
+	ApiKeyId       int64 `meddler:"api_key_id"`
+	AccountId      int64 `meddler:"account_id,zeroisnull"`
+	AccountGroupId int64 `meddler:"account_group_id,zeroisnull"`
+}
+
+type ApiKeyScopeView struct {
+	Id          int64  `meddler:"id,pk"`
+	ApiKeyId    int64  `meddler:"api_key_id"`
+	AccountCode string `meddler:"account_code,zeroisnull"`
+	GroupName   string `meddler:"group_name,zeroisnull"`
+}
@@ -0,0 +1,17 @@
+package core
+
+import "time"
+
+type AuditLog struct {
+	Id            int64     `meddler:"id,pk"`
+	Created       time.Time `meddler:"created,utctime"`
+	CorrelationId string    `meddler:"correlation_id"`
+	Principal     string    `meddler:"principal"`
+	ApiKeyId      int64     `meddler:"api_key_id,zeroisnull"`
+	RemoteAddr    string    `meddler:"remote_addr"`
+	Method        string    `meddler:"method"`
+	Route         string    `meddler:"route"`
+	Path          string    `meddler:"path"`
+	Status        int       `meddler:"status"`
+	AccountCodes  string    `meddler:"account_codes"`
+}
@@ -11,13 +11,28 @@ import (
 
 // Config represents the applicable configuration variables.
 type Config struct {
-	ErrInfo        bool
-	IbGws          []string
-	IbClientId     int
-	DbUrl          string
-	Port           int
-	Host           string
-	AccountRefresh *cronexpr.Expression
+	ErrInfo         bool
+	IbGws           []string
+	IbClientId      int
+	DbUrl           string
+	Port            int
+	Host            string
+	AccountRefresh  *cronexpr.Expression
+	AccountCompress bool
+	AlertHooks      []string
+	AlertHookKey    string
+	SmtpAddr        string
+	SmtpUser        string
+	SmtpPass        string
+	SmtpFrom        string
+	SmtpTo          []string
+	AdminKey        string
+	TlsCert         string
+	TlsKey          string
+	TlsClientCa     string
+	PseudonymKey    string
+	PseudonymRoutes []string
+	Retention       RetentionPolicy
 }
 
 // Address returns the HTTP bind address.
@@ -52,8 +67,8 @@ func NewConfig() (Config, error) {
 		c.DbUrl = "postgres://ibc_dev@localhost/ibc_dev?sslmode=disable"
 	}
 
-	if !strings.HasPrefix(c.DbUrl, "postgres://") {
-		return c, fmt.Errorf("DB_URL '%s' did not being with postgres://", c.DbUrl)
+	if _, err := NewStorage(c.DbUrl); err != nil {
+		return c, fmt.Errorf("DB_URL: %v", err)
 	}
 
 	portString := os.Getenv("PORT")
@@ -79,6 +94,53 @@ func NewConfig() (Config, error) {
 	if err != nil {
 		return c, err
 	}
+	c.AccountCompress = os.Getenv("ACCT_COMPRESS") != "false"
+
+	c.AlertHooks = split(os.Getenv("ALERT_HOOK"))
+	c.AlertHookKey = os.Getenv("ALERT_KEY")
+	if len(c.AlertHooks) > 0 && c.AlertHookKey == "" {
+		return c, fmt.Errorf("ALERT_KEY is required to sign ALERT_HOOK requests")
+	}
+
+	c.SmtpAddr = os.Getenv("SMTP_ADDR")
+	c.SmtpUser = os.Getenv("SMTP_USER")
+	c.SmtpPass = os.Getenv("SMTP_PASS")
+	c.SmtpFrom = os.Getenv("SMTP_FROM")
+	c.SmtpTo = split(os.Getenv("SMTP_TO"))
+	if c.SmtpAddr != "" && (c.SmtpFrom == "" || len(c.SmtpTo) == 0) {
+		return c, fmt.Errorf("SMTP_FROM and SMTP_TO are required with SMTP_ADDR '%s'", c.SmtpAddr)
+	}
+
+	c.AdminKey = os.Getenv("ADMIN_KEY")
+
+	c.TlsCert = os.Getenv("TLS_CERT")
+	c.TlsKey = os.Getenv("TLS_KEY")
+	c.TlsClientCa = os.Getenv("TLS_CLIENT_CA")
+	if (c.TlsCert == "") != (c.TlsKey == "") {
+		return c, fmt.Errorf("TLS_CERT and TLS_KEY must be set together")
+	}
+	if c.TlsClientCa != "" && c.TlsCert == "" {
+		return c, fmt.Errorf("TLS_CERT is required with TLS_CLIENT_CA '%s'", c.TlsClientCa)
+	}
+
+	c.PseudonymKey = os.Getenv("PSEUDONYM_KEY")
+	c.PseudonymRoutes = split(os.Getenv("PSEUDONYM_ROUTES"))
+	if len(c.PseudonymRoutes) > 0 && c.PseudonymKey == "" {
+		return c, fmt.Errorf("PSEUDONYM_KEY is required with PSEUDONYM_ROUTES")
+	}
+
+	c.Retention, err = NewRetentionPolicy(os.Getenv("RETENTION"))
+	if err != nil {
+		return c, fmt.Errorf("RETENTION: %v", err)
+	}
 
 	return c, nil
 }
+
+// split returns the comma-separated values, or nil if s is empty.
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
@@ -8,14 +8,20 @@ import (
 // Context initializes key application dependencies and makes them available.
 type Context struct {
 	closed sync.Once
-	N      *Notifier
-	DL     *DistLock
+	S      Storage
+	N      Notifier
+	DL     DistLock
 	DB     *sql.DB
 }
 
 // NewContext prepares the application context using the passed Config.
 func NewContext(c Config) (*Context, error) {
-	n, err := NewNotifier(c.DbUrl)
+	s, err := NewStorage(c.DbUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := s.NewNotifier()
 	if err != nil {
 		return nil, err
 	}
@@ -25,17 +31,18 @@ func NewContext(c Config) (*Context, error) {
 		return nil, err
 	}
 
-	dl, err := NewDistLock(c.DbUrl)
+	dl, err := s.NewDistLock()
 	if err != nil {
 		return nil, err
 	}
 
-	db, err := InitMeddler(c.DbUrl)
+	db, err := s.Open()
 	if err != nil {
 		return nil, err
 	}
 
 	return &Context{
+		S:  s,
 		N:  n,
 		DL: dl,
 		DB: db}, nil
@@ -7,28 +7,48 @@ import (
 	"time"
 )
 
-// distlock provides a simple distributed lock manager that is backed by
+// DistLock is a lock manager granting each lock to one requester at a time
+// across every node sharing the Storage. Locks are identified by an int64,
+// conventionally a random constant owned by one component.
+type DistLock interface {
+	// Request attempts to acquire a lock for the passed id. The caller can
+	// abandon the lock request (or acquired lock) by closing the abandon
+	// channel. True is sent on the reply channel if the lock is acquired, and
+	// the reply channel is closed if the lock is abandoned or the lock
+	// manager closes.
+	Request(id int64, abandon <-chan struct{}) <-chan bool
+
+	// Close terminates the lock manager and all locks, blocking until it has
+	// closed. It is safe to call multiple times.
+	Close()
+}
+
+// PgDistLock provides a simple distributed lock manager that is backed by
 // Postgres session-level advisory locks. Such locks are held until explicitly
 // released or the Postgres connection ends. Corner cases like the loss of
 // connectivity between this node and the database resulting in loss of the
 // lock (as the database considers the session has ended) are currently
 // unhandled, although the API contract enables this to be easily added in the
 // future without any existing client changes.
-type DistLock struct {
+type PgDistLock struct {
 	exit       chan bool
 	terminated chan struct{}
 	db         *sql.DB
 }
 
-// NewDistLock returns a distributed lock manager.
-func NewDistLock(dbUrl string) (*DistLock, error) {
+// NewPgDistLock returns a distributed lock manager.
+func NewPgDistLock(dbUrl string) (*PgDistLock, error) {
 	db, err := sql.Open("postgres", dbUrl)
 
 	if err != nil {
 		return nil, err
 	}
 
-	n := &DistLock{
+	// advisory locks belong to a session, so every lock and unlock must use
+	// the same connection for an abandoned lock to actually be released
+	db.SetMaxOpenConns(1)
+
+	n := &PgDistLock{
 		exit:       make(chan bool),
 		terminated: make(chan struct{}),
 		db:         db,
@@ -39,7 +59,7 @@ func NewDistLock(dbUrl string) (*DistLock, error) {
 	return n, nil
 }
 
-func (d *DistLock) initLockManager() error {
+func (d *PgDistLock) initLockManager() error {
 	go func() {
 		for {
 			select {
@@ -64,7 +84,7 @@ func (d *DistLock) initLockManager() error {
 // manager closing or already being closed). Future connection monitoring
 // features will also tie into the reply channel being closed on unexpected loss
 // of the lock.
-func (d *DistLock) Request(id int64, abandon <-chan struct{}) <-chan bool {
+func (d *PgDistLock) Request(id int64, abandon <-chan struct{}) <-chan bool {
 	reply := make(chan bool)
 	acquired := false
 
@@ -123,7 +143,7 @@ func (d *DistLock) Request(id int64, abandon <-chan struct{}) <-chan bool {
 // Close terminates the lock manager and all locks. It will cause all reply
 // channels to close. Close can be called multiple times safely, and it will
 // block until the lock manager has been closed.
-func (d *DistLock) Close() {
+func (d *PgDistLock) Close() {
 	select {
 	case <-d.terminated:
 		return
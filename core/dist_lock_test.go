@@ -6,7 +6,76 @@ import (
 )
 
 func TestNormalLockCycle(t *testing.T) {
-	distLock := getLockManager(t)
+	testNormalLockCycle(t, getLockManager(t))
+}
+
+func TestLocalNormalLockCycle(t *testing.T) {
+	testNormalLockCycle(t, NewLocalDistLock())
+}
+
+func TestCompetingLockNotGranted(t *testing.T) {
+	// need 2 lock managers as one connection can acquire the same lock twice
+	testCompetingLockNotGranted(t, getLockManager(t), getLockManager(t))
+}
+
+func TestLocalCompetingLockNotGranted(t *testing.T) {
+	// a local lock is only granted to one request at a time
+	distLock := NewLocalDistLock()
+	testCompetingLockNotGranted(t, distLock, distLock)
+}
+
+func TestLockManagerClosureCancelsLocks(t *testing.T) {
+	testLockManagerClosureCancelsLocks(t, getLockManager(t))
+}
+
+func TestLocalLockManagerClosureCancelsLocks(t *testing.T) {
+	testLockManagerClosureCancelsLocks(t, NewLocalDistLock())
+}
+
+func TestClosedLockManagerGivesClosedReplyForNewRequests(t *testing.T) {
+	testClosedLockManagerGivesClosedReply(t, getLockManager(t))
+}
+
+func TestLocalClosedLockManagerGivesClosedReplyForNewRequests(t *testing.T) {
+	testClosedLockManagerGivesClosedReply(t, NewLocalDistLock())
+}
+
+func TestLocalAbandonedRequestNeverGranted(t *testing.T) {
+	distLock := NewLocalDistLock()
+	defer distLock.Close()
+
+	lock := int64(2349875)
+	abandon1 := make(chan struct{})
+	reply1 := distLock.Request(lock, abandon1)
+	expectLock(t, reply1)
+
+	abandon2 := make(chan struct{})
+	reply2 := distLock.Request(lock, abandon2)
+	close(abandon2)
+	expectRelease(t, reply2)
+
+	// the abandoned request must not have taken the lock once released
+	close(abandon1)
+	expectRelease(t, reply1)
+	abandon3 := make(chan struct{})
+	reply3 := distLock.Request(lock, abandon3)
+	expectLock(t, reply3)
+	close(abandon3)
+	expectRelease(t, reply3)
+}
+
+func getLockManager(t *testing.T) DistLock {
+	config := NewTestConfig(t)
+
+	distLock, err := NewPgDistLock(config.DbUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return distLock
+}
+
+func testNormalLockCycle(t *testing.T, distLock DistLock) {
 	defer distLock.Close()
 
 	lock := int64(2349875)
@@ -19,14 +88,10 @@ func TestNormalLockCycle(t *testing.T) {
 	expectRelease(t, reply)
 }
 
-func TestCompetingLockNotGranted(t *testing.T) {
+func testCompetingLockNotGranted(t *testing.T, distLock1 DistLock, distLock2 DistLock) {
 	lock := int64(2349875)
 
-	// need 2 lock managers as one connection can acquire the same lock twice
-	distLock1 := getLockManager(t)
 	defer distLock1.Close()
-
-	distLock2 := getLockManager(t)
 	defer distLock2.Close()
 
 	abandon1 := make(chan struct{})
@@ -45,8 +110,7 @@ func TestCompetingLockNotGranted(t *testing.T) {
 	expectRelease(t, reply2)
 }
 
-func TestLockManagerClosureCancelsLocks(t *testing.T) {
-	distLock := getLockManager(t)
+func testLockManagerClosureCancelsLocks(t *testing.T, distLock DistLock) {
 	defer distLock.Close()
 
 	lock := int64(2349875)
@@ -60,8 +124,7 @@ func TestLockManagerClosureCancelsLocks(t *testing.T) {
 	expectRelease(t, reply)
 }
 
-func TestClosedLockManagerGivesClosedReplyForNewRequests(t *testing.T) {
-	distLock := getLockManager(t)
+func testClosedLockManagerGivesClosedReply(t *testing.T, distLock DistLock) {
 	distLock.Close()
 
 	lock := int64(2349875)
@@ -71,17 +134,6 @@ func TestClosedLockManagerGivesClosedReplyForNewRequests(t *testing.T) {
 	expectClosedReplyChannel(t, reply)
 }
 
-func getLockManager(t *testing.T) *DistLock {
-	config := NewTestConfig(t)
-
-	distLock, err := NewDistLock(config.DbUrl)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	return distLock
-}
-
 func expectLock(t *testing.T, reply <-chan bool) {
 	select {
 	case acquired, ok := <-reply:
@@ -1,9 +1,13 @@
 /*
 Package core provides types and values shared between IB Connect packages.
 
-A given IB Connect cluster shares a common Postgres database. That Postgres
-database is used for (i) data storage, (ii) distributed lock management and
-(iii) distributed pub-sub messaging.
+A given IB Connect cluster shares a common database, accessed through a
+Storage. The database is used for (i) data storage, (ii) distributed lock
+management and (iii) distributed pub-sub messaging. Postgres (PgStorage) is the
+default and supports any number of instances. SQLite (SqliteStorage) supports
+a single instance, with an in-process Notifier and DistLock.
+Background work that only one instance should perform at a time runs under a
+Leader, which holds a DistLock while scheduling the work.
 
 Each instance of IB Connect will load a GatewayController to manage the transfer
 of data between IB API and the database, and a worker to make representations of
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/russross/meddler"
+)
+
+// FxRates maps an ISO 4217 code to the value of one major unit of that
+// currency in a common currency (usually the account's base currency). Any two
+// currencies present in the map can therefore be converted between.
+type FxRates map[int16]float64
+
+// NewFxRates loads the exchange rates captured with the passed account snapshot.
+func NewFxRates(db meddler.DB, accountSnapshotId int64) (FxRates, error) {
+	var rates []*FxRate
+	err := meddler.QueryAll(db, &rates, "SELECT * FROM fx_rate WHERE account_snapshot_id = $1", accountSnapshotId)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make(FxRates)
+	for _, rate := range rates {
+		r[rate.Iso4217Code] = rate.Rate
+	}
+	return r, nil
+}
+
+// Convert converts a major unit amount between the two currencies.
+func (r FxRates) Convert(amount float64, from int16, to int16) (float64, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	fromRate, ok := r[from]
+	if !ok || fromRate == 0 {
+		return 0, fmt.Errorf("no exchange rate for ISO 4217 code %d", from)
+	}
+
+	toRate, ok := r[to]
+	if !ok || toRate == 0 {
+		return 0, fmt.Errorf("no exchange rate for ISO 4217 code %d", to)
+	}
+
+	return amount * fromRate / toRate, nil
+}
+
+// Convert returns a copy of the AccountAmount with every monetary field
+// expressed in the target currency.
+func (a AccountAmount) Convert(c Currencies, r FxRates, target int16) (AccountAmount, error) {
+	for _, m := range a.monetaries() {
+		converted, err := m.Convert(c, r, target)
+		if err != nil {
+			return a, err
+		}
+		*m = converted
+	}
+	return a, nil
+}
+
+// View returns the AccountAmountView equivalent of the AccountAmount, which is
+// how v_account_amount would present it.
+func (a AccountAmount) View(c Currencies, accountType string) AccountAmountView {
+	return AccountAmountView{
+		AccountSnapshotId:        a.AccountSnapshotId,
+		Currency:                 c[a.Iso4217Code].AlphabeticCode,
+		Base:                     a.Base,
+		AccountType:              accountType,
+		Cushion:                  a.Cushion,
+		LookAheadNextChange:      a.LookAheadNextChange,
+		AccruedCash:              a.AccruedCash.Human(c),
+		AvailableFunds:           a.AvailableFunds.Human(c),
+		BuyingPower:              a.BuyingPower.Human(c),
+		EquityWithLoanValue:      a.EquityWithLoanValue.Human(c),
+		ExcessLiquidity:          a.ExcessLiquidity.Human(c),
+		FullAvailableFunds:       a.FullAvailableFunds.Human(c),
+		FullExcessLiquidity:      a.FullExcessLiquidity.Human(c),
+		FullInitMarginReq:        a.FullInitMarginReq.Human(c),
+		FullMaintMarginReq:       a.FullMaintMarginReq.Human(c),
+		GrossPositionValue:       a.GrossPositionValue.Human(c),
+		InitMarginReq:            a.InitMarginReq.Human(c),
+		LookAheadAvailableFunds:  a.LookAheadAvailableFunds.Human(c),
+		LookAheadExcessLiquidity: a.LookAheadExcessLiquidity.Human(c),
+		LookAheadInitMarginReq:   a.LookAheadInitMarginReq.Human(c),
+		LookAheadMaintMarginReq:  a.LookAheadMaintMarginReq.Human(c),
+		MaintMarginReq:           a.MaintMarginReq.Human(c),
+		NetLiquidation:           a.NetLiquidation.Human(c),
+		TotalCashBalance:         a.TotalCashBalance.Human(c),
+		TotalCashValue:           a.TotalCashValue.Human(c),
+	}
+}
+
+// monetaries returns pointers to every monetary field.
+func (a *AccountAmount) monetaries() []*Monetary {
+	return []*Monetary{
+		&a.AccruedCash,
+		&a.AvailableFunds,
+		&a.BuyingPower,
+		&a.EquityWithLoanValue,
+		&a.ExcessLiquidity,
+		&a.FullAvailableFunds,
+		&a.FullExcessLiquidity,
+		&a.FullInitMarginReq,
+		&a.FullMaintMarginReq,
+		&a.GrossPositionValue,
+		&a.InitMarginReq,
+		&a.LookAheadAvailableFunds,
+		&a.LookAheadExcessLiquidity,
+		&a.LookAheadInitMarginReq,
+		&a.LookAheadMaintMarginReq,
+		&a.MaintMarginReq,
+		&a.NetLiquidation,
+		&a.TotalCashBalance,
+		&a.TotalCashValue,
+	}
+}
+
+// Convert expresses the position's monetary fields in the target currency.
+func (p *AccountPositionView) Convert(r FxRates, target Iso4217) error {
+	for _, f := range []*float64{&p.MarketPrice, &p.MarketValue, &p.AverageCost, &p.UnrealizedPNL, &p.RealizedPNL} {
+		converted, err := r.Convert(*f, p.Iso4217Code, target.Iso4217Code)
+		if err != nil {
+			return err
+		}
+		*f = converted
+	}
+	p.Iso4217Code = target.Iso4217Code
+	p.Currency = target.Currency
+	return nil
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"testing"
+)
+
+var testCurrencies = Currencies{
+	0:   Iso4217{0, 0, "NIL", "Nil Value"},
+	36:  Iso4217{36, 2, "AUD", "Australian Dollar"},
+	392: Iso4217{392, 0, "JPY", "Yen"},
+	840: Iso4217{840, 2, "USD", "US Dollar"},
+}
+
+// AUD base currency
+var testRates = FxRates{
+	36:  1,
+	392: 0.0105,
+	840: 1.0695,
+}
+
+func TestFxRatesConvert(t *testing.T) {
+	usd, err := testRates.Convert(100, 36, 840)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usd < 93.50 || usd > 93.51 {
+		t.Fatalf("AUD 100 should be USD 93.50 (was %f)", usd)
+	}
+
+	_, err = testRates.Convert(100, 36, 978)
+	if err == nil {
+		t.Fatal("conversion to a currency without a rate should fail")
+	}
+}
+
+func TestMonetaryConvert(t *testing.T) {
+	aud := Monetary{36, 10000}
+
+	jpy, err := aud.Convert(testCurrencies, testRates, 392)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jpy.Iso4217Code != 392 || jpy.Amount != 9524 {
+		t.Fatalf("AUD 100.00 should be JPY 9524 (was %v)", jpy)
+	}
+
+	back, err := jpy.Convert(testCurrencies, testRates, 36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Human(testCurrencies) != "AUD 100.00" {
+		t.Fatalf("JPY 9524 should be AUD 100.00 (was %s)", back.Human(testCurrencies))
+	}
+}
+
+func TestMonetaryConvertNil(t *testing.T) {
+	none := Monetary{}
+	converted, err := none.Convert(testCurrencies, FxRates{}, 840)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted != none {
+		t.Fatal("nil amounts should not be converted")
+	}
+}
+
+func TestMonetaryHuman(t *testing.T) {
+	if h := (Monetary{840, -123456}).Human(testCurrencies); h != "USD -1234.56" {
+		t.Fatalf("unexpected %s", h)
+	}
+	if h := (Monetary{392, 500}).Human(testCurrencies); h != "JPY 500" {
+		t.Fatalf("unexpected %s", h)
+	}
+}
+
+func TestAccountAmountConvert(t *testing.T) {
+	amt := AccountAmount{NetLiquidation: Monetary{36, 10000}}
+	converted, err := amt.Convert(testCurrencies, testRates, 840)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	view := converted.View(testCurrencies, "INDIVIDUAL")
+	if view.NetLiquidation != "USD 93.50" {
+		t.Fatalf("unexpected net liquidation %s", view.NetLiquidation)
+	}
+	if view.AccruedCash != "NIL 0" {
+		t.Fatalf("unexpected accrued cash %s", view.AccruedCash)
+	}
+}
@@ -0,0 +1,109 @@
+package core
+
+// hub manages the subscribers of a notifier. The notifier's goroutine owns the
+// subscribers, running each command (such as a subscription change) sent to
+// ch, delivering notifications and, on exit, closing every subscriber and
+// terminated.
+type hub struct {
+	exit        chan bool
+	terminated  chan struct{}
+	ch          chan command
+	subscribers []chan<- *Notification
+}
+
+func newHub() *hub {
+	return &hub{
+		exit:       make(chan bool),
+		terminated: make(chan struct{}),
+		ch:         make(chan command),
+	}
+}
+
+// Subscribe blocks until the passed channel is registered to receive
+// notifications or the notifier has terminated.
+func (h *hub) Subscribe(c chan<- *Notification) {
+	h.sendCommand(func() {
+		h.subscribers = append(h.subscribers, c)
+	})
+}
+
+// Unsubscribe blocks until the passed channel will no longer receive
+// notifications or the notifier has terminated. It also maintains a goroutine
+// to sink the channel until the unsubscribe is finalised, which frees the
+// caller from handling this.
+func (h *hub) Unsubscribe(c chan *Notification) {
+	terminated := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-c:
+			case <-terminated:
+				return
+			}
+		}
+	}()
+	h.sendCommand(func() {
+		newSubscribers := make([]chan<- *Notification, 0)
+		for _, existing := range h.subscribers {
+			if existing != c {
+				newSubscribers = append(newSubscribers, existing)
+			}
+		}
+		h.subscribers = newSubscribers
+	})
+	close(terminated)
+}
+
+// Close must be called when the notifier is no longer required. It blocks until
+// the notifier has closed, and is safe to call multiple times.
+func (h *hub) Close() {
+	select {
+	case <-h.terminated:
+		return
+	case h.exit <- true:
+	}
+	<-h.terminated
+}
+
+// deliver sends the notification to every subscriber. It must only be called
+// by the notifier's goroutine.
+func (h *hub) deliver(n *Notification) {
+	for _, sub := range h.subscribers {
+		sub <- n
+	}
+}
+
+// closeSubscribers closes every subscription channel. It must only be called
+// by the notifier's goroutine as it exits.
+func (h *hub) closeSubscribers() {
+	for _, sub := range h.subscribers {
+		close(sub)
+	}
+}
+
+// command allows thread-safe subscribe/unsubscribe management.
+type command struct {
+	fun func()
+	ack chan struct{}
+}
+
+// sendCommand delivers the func to the notifier, blocking the calling goroutine
+// until the command is acknowledged as completed or the notifier exits.
+func (h *hub) sendCommand(c func()) {
+	cmd := command{c, make(chan struct{})}
+
+	// send cmd
+	select {
+	case <-h.terminated:
+		return
+	case h.ch <- cmd:
+	}
+
+	// await ack (also handle termination)
+	select {
+	case <-h.terminated:
+		return
+	case <-cmd.ack:
+		return
+	}
+}
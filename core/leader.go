@@ -0,0 +1,121 @@
+package core
+
+import "time"
+
+// Leader runs a unit of work on whichever node holds a cluster lock. The work
+// runs when the lock is acquired, every interval while it is held and, if a
+// Notifier is passed, whenever an account feed completes. Requests arriving
+// while work is running or already scheduled are coalesced into a single run.
+type Leader struct {
+	exit       chan bool
+	terminated chan struct{}
+	requests   chan struct{}
+	distLock   DistLock
+	n          Notifier
+	id         int64
+	interval   time.Duration
+	work       func()
+}
+
+// NewLeader returns a Leader requesting lock id from distLock. The Notifier
+// may be nil if the work does not follow account feeds.
+func NewLeader(distLock DistLock, id int64, interval time.Duration, n Notifier, work func()) *Leader {
+	l := &Leader{
+		exit:       make(chan bool),
+		terminated: make(chan struct{}),
+		requests:   make(chan struct{}, 1),
+		distLock:   distLock,
+		n:          n,
+		id:         id,
+		interval:   interval,
+		work:       work,
+	}
+	l.initLeader()
+	return l
+}
+
+// Close terminates the Leader, releasing the lock. Close can be called multiple
+// times safely, and it will block until the Leader and any running work have
+// finished.
+func (l *Leader) Close() {
+	select {
+	case <-l.terminated:
+		return
+	case l.exit <- true:
+	}
+	<-l.terminated
+}
+
+func (l *Leader) initLeader() {
+	// work may be slow or publish notifications, so it must not block the
+	// goroutine handling the lock, notifications and termination
+	stop := make(chan struct{})
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-l.requests:
+				l.work()
+			}
+		}
+	}()
+
+	go func() {
+		abandonLock := make(chan struct{})
+		lockReply := l.distLock.Request(l.id, abandonLock)
+		var notifications chan *Notification
+		if l.n != nil {
+			notifications = make(chan *Notification)
+			l.n.Subscribe(notifications)
+		}
+		leader := false
+		ticker := time.NewTicker(l.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.exit:
+				if notifications != nil {
+					l.n.Unsubscribe(notifications)
+				}
+				close(abandonLock)
+				close(stop)
+				<-workerDone
+				close(l.terminated)
+				return
+			case acquiredLock, ok := <-lockReply:
+				if !ok {
+					lockReply = nil
+					leader = false
+					continue
+				}
+				leader = acquiredLock
+				if leader {
+					l.request() // catch up on anything missed while not leader
+				}
+			case <-ticker.C:
+				if leader {
+					l.request()
+				}
+			case event, ok := <-notifications:
+				if !ok {
+					notifications = nil
+					continue
+				}
+				if leader && event.Type == NtAccountFeedDone {
+					l.request()
+				}
+			}
+		}
+	}()
+}
+
+// request schedules the work, coalescing with any already scheduled.
+func (l *Leader) request() {
+	select {
+	case l.requests <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+// expectRun fails unless the work runs before the timeout.
+func expectRun(t *testing.T, runs <-chan struct{}) {
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for work")
+	}
+}
+
+// expectNoRun fails if the work runs within a short period.
+func expectNoRun(t *testing.T, runs <-chan struct{}) {
+	select {
+	case <-runs:
+		t.Fatal("work ran unexpectedly")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestLeaderRunsWorkOnlyWhileHoldingLock(t *testing.T) {
+	distLock := NewLocalDistLock()
+	defer distLock.Close()
+	n := NewLocalNotifier()
+	defer n.Close()
+	if err := n.RegisterAll(NtTypes()); err != nil {
+		t.Fatal(err)
+	}
+
+	lock := int64(8812734098123)
+	runs1 := make(chan struct{}, 10)
+	l1 := NewLeader(distLock, lock, time.Hour, n, func() { runs1 <- struct{}{} })
+	expectRun(t, runs1)
+
+	runs2 := make(chan struct{}, 10)
+	l2 := NewLeader(distLock, lock, time.Hour, n, func() { runs2 <- struct{}{} })
+	defer l2.Close()
+
+	n.Publish(NtAccountFeedDone, 0)
+	expectRun(t, runs1)
+	expectNoRun(t, runs2)
+
+	n.Publish(NtAccountSnapshot, 0)
+	expectNoRun(t, runs1)
+
+	// closing the leader releases the lock to the other
+	l1.Close()
+	l1.Close()
+	expectRun(t, runs2)
+}
+
+func TestLeaderWithoutNotifier(t *testing.T) {
+	distLock := NewLocalDistLock()
+	defer distLock.Close()
+
+	runs := make(chan struct{}, 10)
+	l := NewLeader(distLock, int64(8812734098124), 10*time.Millisecond, nil, func() { runs <- struct{}{} })
+	defer l.Close()
+
+	// the first run is on acquiring the lock, and the second on the ticker
+	expectRun(t, runs)
+	expectRun(t, runs)
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// LocalDistLock is an in-memory DistLock whose locks are exclusive within this
+// process, for a Storage that no other node shares. It needs no database, so
+// also suits tests and programs embedding IB Connect packages. Unlike a
+// PgDistLock, a lock held by one request is never granted to another request
+// of the same LocalDistLock.
+type LocalDistLock struct {
+	exit       chan bool
+	terminated chan struct{}
+	mu         sync.Mutex
+	held       map[int64]bool
+	closed     bool
+}
+
+// NewLocalDistLock returns a lock manager for this process.
+func NewLocalDistLock() *LocalDistLock {
+	d := &LocalDistLock{
+		exit:       make(chan bool),
+		terminated: make(chan struct{}),
+		held:       make(map[int64]bool),
+	}
+	go func() {
+		<-d.exit
+		d.mu.Lock()
+		d.closed = true
+		d.held = make(map[int64]bool)
+		d.mu.Unlock()
+		close(d.terminated)
+	}()
+	return d
+}
+
+// Request attempts to acquire a lock for the passed id, with the same contract
+// as PgDistLock.Request. A lock held by another request is retried until it is
+// released or the request is abandoned.
+func (d *LocalDistLock) Request(id int64, abandon <-chan struct{}) <-chan bool {
+	reply := make(chan bool)
+	go func() {
+		defer close(reply)
+		for !d.acquire(id) {
+			select {
+			case <-d.terminated:
+				return
+			case <-abandon:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+		defer d.release(id)
+
+		select {
+		case <-d.terminated:
+			return
+		case <-abandon:
+			return
+		case reply <- true:
+		}
+
+		select {
+		case <-d.terminated:
+		case <-abandon:
+		}
+	}()
+	return reply
+}
+
+// acquire takes the lock if it is free and the lock manager has not closed,
+// returning true if it did.
+func (d *LocalDistLock) acquire(id int64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed || d.held[id] {
+		return false
+	}
+	d.held[id] = true
+	return true
+}
+
+func (d *LocalDistLock) release(id int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.held, id)
+}
+
+// Close terminates the lock manager and all locks. It will cause all reply
+// channels to close. Close can be called multiple times safely, and it will
+// block until the lock manager has been closed.
+func (d *LocalDistLock) Close() {
+	select {
+	case <-d.terminated:
+		return
+	case d.exit <- true:
+	}
+	<-d.terminated
+}
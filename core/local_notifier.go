@@ -0,0 +1,78 @@
+package core
+
+import "log"
+
+// LocalNotifier is an in-memory Notifier that delivers the notifications
+// published in this process, for a Storage that no other node shares. It needs
+// no database, so also suits tests and programs embedding IB Connect packages.
+type LocalNotifier struct {
+	*hub
+	types     map[NtType]bool
+	published chan *Notification
+}
+
+// NewLocalNotifier creates a new LocalNotifier and correctly initializes it.
+func NewLocalNotifier() *LocalNotifier {
+	n := &LocalNotifier{
+		hub:   newHub(),
+		types: make(map[NtType]bool),
+		// buffered like a Postgres listener, so publishers rarely wait on
+		// subscribers
+		published: make(chan *Notification, 32),
+	}
+	n.initSubscribers()
+	return n
+}
+
+// Publish transmits a notification to all subscribers.
+func (n *LocalNotifier) Publish(ntType NtType, id int64) {
+	select {
+	case <-n.terminated:
+	case n.published <- &Notification{Type: ntType, Id: id}:
+	}
+}
+
+// RegisterAll is a convenience function to register all present ntTypes.
+func (n *LocalNotifier) RegisterAll(t []NtType) error {
+	for _, nt := range t {
+		err := n.Register(nt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Register registers a type of notification to be delivered. Notifications of
+// unregistered types are discarded, as a PgNotifier does.
+func (n *LocalNotifier) Register(t NtType) error {
+	n.sendCommand(func() {
+		n.types[t] = true
+	})
+	return nil
+}
+
+// initSubscribers starts the goroutine for event delivery, termination and
+// subscription management.
+func (n *LocalNotifier) initSubscribers() {
+	go func() {
+		for {
+			select {
+			case <-n.terminated:
+				return
+			case <-n.exit:
+				n.closeSubscribers()
+				close(n.terminated)
+			case cmd := <-n.ch:
+				cmd.fun()
+				close(cmd.ack)
+			case localN := <-n.published:
+				if !n.types[localN.Type] {
+					log.Printf("Error delivering notification %v: unregistered type '%s'", localN, localN.Type)
+					continue
+				}
+				n.deliver(localN)
+			}
+		}
+	}()
+}
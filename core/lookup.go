@@ -0,0 +1,196 @@
+package core
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/russross/meddler"
+)
+
+// GetIso4217 returns the Iso4217 object for the passed alphabetic code, or
+// sql.ErrNoRows if the currency is unknown.
+func GetIso4217(db meddler.DB, currency string) (Iso4217, error) {
+	iso := new(Iso4217)
+	err := meddler.QueryRow(db, iso, "SELECT * FROM iso_4217 WHERE alphabetic_code = $1", currency)
+	return *iso, err
+}
+
+// GetAccount returns the Account object, creating a database record if needed.
+func GetAccount(db meddler.DB, accountCode string) (Account, error) {
+	existing := new(Account)
+	err := meddler.QueryRow(db, existing, "SELECT * FROM account WHERE account_code = $1", accountCode)
+	if err != nil && err != sql.ErrNoRows {
+		return *existing, err
+	}
+
+	if existing.Id != 0 {
+		return *existing, nil
+	}
+
+	acct := &Account{}
+	acct.AccountCode = accountCode
+	err = meddler.Insert(db, "account", acct)
+	return *acct, err
+}
+
+// GetAccountType returns the AccountType object, creating a database record if needed.
+func GetAccountType(db meddler.DB, desc string) (AccountType, error) {
+	existing := new(AccountType)
+	err := meddler.QueryRow(db, existing, "SELECT * FROM account_type WHERE type_desc = $1", desc)
+	if err != nil && err != sql.ErrNoRows {
+		return *existing, err
+	}
+
+	if existing.Id != 0 {
+		return *existing, nil
+	}
+
+	at := &AccountType{}
+	at.TypeDescription = desc
+	err = meddler.Insert(db, "account_type", at)
+	return *at, err
+}
+
+// GetAccountValueKey returns the AccountValueKey object, creating a database
+// record if needed.
+func GetAccountValueKey(db meddler.DB, name string) (AccountValueKey, error) {
+	existing := new(AccountValueKey)
+	err := meddler.QueryRow(db, existing, "SELECT * FROM account_value_key WHERE key_name = $1", name)
+	if err != nil && err != sql.ErrNoRows {
+		return *existing, err
+	}
+
+	if existing.Id != 0 {
+		return *existing, nil
+	}
+
+	k := &AccountValueKey{}
+	k.KeyName = name
+	err = meddler.Insert(db, "account_value_key", k)
+	return *k, err
+}
+
+// GetSecurityType returns the SecurityType object, creating a database record if needed.
+func GetSecurityType(db meddler.DB, desc string) (SecurityType, error) {
+	existing := new(SecurityType)
+	err := meddler.QueryRow(db, existing, "SELECT * FROM security_type WHERE security_type = $1", desc)
+	if err != nil && err != sql.ErrNoRows {
+		return *existing, err
+	}
+
+	if existing.Id != 0 {
+		return *existing, nil
+	}
+
+	st := &SecurityType{}
+	st.SecurityType = desc
+	err = meddler.Insert(db, "security_type", st)
+	return *st, err
+}
+
+// GetSymbol returns the Symbol object, creating a database record if needed.
+func GetSymbol(db meddler.DB, desc string) (Symbol, error) {
+	existing := new(Symbol)
+	err := meddler.QueryRow(db, existing, "SELECT * FROM symbol WHERE symbol = $1", desc)
+	if err != nil && err != sql.ErrNoRows {
+		return *existing, err
+	}
+
+	if existing.Id != 0 {
+		return *existing, nil
+	}
+
+	s := &Symbol{}
+	s.Symbol = desc
+	err = meddler.Insert(db, "symbol", s)
+	return *s, err
+}
+
+// GetExchange returns the Exchange object, creating a database record if needed.
+func GetExchange(db meddler.DB, desc string) (Exchange, error) {
+	existing := new(Exchange)
+	err := meddler.QueryRow(db, existing, "SELECT * FROM exchange WHERE exchange = $1", desc)
+	if err != nil && err != sql.ErrNoRows {
+		return *existing, err
+	}
+
+	if existing.Id != 0 {
+		return *existing, nil
+	}
+
+	e := &Exchange{}
+	e.Exchange = desc
+	err = meddler.Insert(db, "exchange", e)
+	return *e, err
+}
+
+// GetContract returns the Contract matching every field of the criteria except
+// Id and Created, creating a database record (with the criteria's Created) if
+// needed.
+func GetContract(db meddler.DB, criteria Contract) (Contract, error) {
+	existing := new(Contract)
+	err := meddler.QueryRow(db, existing,
+		"SELECT * FROM contract WHERE ib_contract_id = $1 AND "+
+			"iso_4217_code = $2 AND symbol_id = $3 AND local_symbol_id = $4 AND "+
+			"security_type_id = $5 AND primary_exchange_id = $6",
+		criteria.IbContractId, criteria.Iso4217Code, criteria.SymbolId,
+		criteria.LocalSymbolId, criteria.SecurityTypeId, criteria.PrimaryExchangeId)
+	if err != nil && err != sql.ErrNoRows {
+		return *existing, err
+	}
+
+	if existing.Id != 0 {
+		return *existing, nil
+	}
+
+	err = meddler.Insert(db, "contract", &criteria)
+	return criteria, err
+}
+
+// ContractDetails are the descriptive fields IB reports for a contract.
+type ContractDetails struct {
+	IbContractId    int64
+	Currency        string
+	Symbol          string
+	LocalSymbol     string
+	SecurityType    string
+	PrimaryExchange string
+}
+
+// ResolveContract returns the Contract with the details, creating any database
+// records needed (including the Contract itself, with the passed created time).
+func ResolveContract(db meddler.DB, d ContractDetails, created time.Time) (Contract, error) {
+	c := Contract{IbContractId: d.IbContractId, Created: created}
+
+	iso, err := GetIso4217(db, d.Currency)
+	if err != nil {
+		return c, err
+	}
+	c.Iso4217Code = iso.Iso4217Code
+
+	symbol, err := GetSymbol(db, d.Symbol)
+	if err != nil {
+		return c, err
+	}
+	c.SymbolId = symbol.Id
+
+	localSymbol, err := GetSymbol(db, d.LocalSymbol)
+	if err != nil {
+		return c, err
+	}
+	c.LocalSymbolId = localSymbol.Id
+
+	secType, err := GetSecurityType(db, d.SecurityType)
+	if err != nil {
+		return c, err
+	}
+	c.SecurityTypeId = secType.Id
+
+	exg, err := GetExchange(db, d.PrimaryExchange)
+	if err != nil {
+		return c, err
+	}
+	c.PrimaryExchangeId = exg.Id
+
+	return GetContract(db, c)
+}
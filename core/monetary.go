@@ -28,35 +28,95 @@ func NewMonetary(db meddler.DB, currency string, amount string) (Monetary, error
 	}
 	m.Iso4217Code = iso.Iso4217Code
 
-	var major, minor int
-
-	split := strings.Split(amount, ".")
+	negative := strings.HasPrefix(amount, "-")
+	split := strings.Split(strings.TrimPrefix(amount, "-"), ".")
 	if len(split) > 2 {
 		return *m, fmt.Errorf("amount '%s' should be an integer or contain a single decimal point", amount)
 	}
 
+	major, err := strconv.ParseInt(split[0], 10, 64)
+	if err != nil {
+		return *m, err
+	}
+
+	// convert it to cents based on what this currency uses, rounding any
+	// digits beyond the currency's minor unit
+	var minor int64
 	if len(split) == 2 {
-		major, err = strconv.Atoi(split[0])
-		if err != nil {
+		if _, err := strconv.ParseUint(split[1], 10, 64); err != nil {
 			return *m, err
 		}
 
-		minor, err = strconv.Atoi(split[1])
-		if err != nil {
-			return *m, err
+		digits := int(iso.MinorUnit)
+		fraction := split[1] + strings.Repeat("0", digits)
+		if digits > 0 {
+			minor, err = strconv.ParseInt(fraction[:digits], 10, 64)
+			if err != nil {
+				return *m, err
+			}
 		}
-	} else {
-		major, err = strconv.Atoi(amount)
-		if err != nil {
-			return *m, err
+		if fraction[digits] >= '5' {
+			minor++
 		}
 	}
 
-	// convert it to cents based on what this currency uses
-	m.Amount = (int64(major) * int64(math.Pow10(int(iso.MinorUnit)))) + int64(minor)
+	m.Amount = major*int64(math.Pow10(int(iso.MinorUnit))) + minor
+	if negative {
+		m.Amount = -m.Amount
+	}
 	return *m, nil
 }
 
+// Float returns the amount in major units of the currency (eg dollars rather
+// than cents).
+func (m Monetary) Float(c Currencies) (float64, error) {
+	iso, ok := c[m.Iso4217Code]
+	if !ok {
+		return 0, fmt.Errorf("unknown ISO 4217 code %d", m.Iso4217Code)
+	}
+	return float64(m.Amount) / math.Pow10(int(iso.MinorUnit)), nil
+}
+
+// Convert returns the amount expressed in the target currency, using the rates
+// to move between currencies. Nil amounts (ISO 4217 code 000) are returned
+// unchanged, as they indicate IB did not report a value.
+func (m Monetary) Convert(c Currencies, r FxRates, target int16) (Monetary, error) {
+	if m.Iso4217Code == 0 || m.Iso4217Code == target {
+		return m, nil
+	}
+
+	to, ok := c[target]
+	if !ok {
+		return m, fmt.Errorf("unknown ISO 4217 code %d", target)
+	}
+
+	major, err := m.Float(c)
+	if err != nil {
+		return m, err
+	}
+
+	converted, err := r.Convert(major, m.Iso4217Code, target)
+	if err != nil {
+		return m, err
+	}
+
+	return Monetary{
+		Iso4217Code: target,
+		Amount:      int64(math.Round(converted * math.Pow10(int(to.MinorUnit)))),
+	}, nil
+}
+
+// Human returns the amount in the same format as the monetary_human SQL
+// function (eg "AUD 62.69").
+func (m Monetary) Human(c Currencies) string {
+	iso, ok := c[m.Iso4217Code]
+	if !ok {
+		return fmt.Sprintf("%03d %d", m.Iso4217Code, m.Amount)
+	}
+	major := float64(m.Amount) / math.Pow10(int(iso.MinorUnit))
+	return fmt.Sprintf("%s %.*f", iso.AlphabeticCode, iso.MinorUnit, major)
+}
+
 // Iso4217 represents officially-reported information about a specific currency.
 type Iso4217 struct {
 	Iso4217Code    int16  `meddler:"iso_4217_code"`
@@ -65,6 +125,35 @@ type Iso4217 struct {
 	Currency       string `meddler:"currency"`
 }
 
+// Currencies holds every ISO 4217 record keyed by its numeric code. It allows
+// Monetary values to be formatted and converted without further queries.
+type Currencies map[int16]Iso4217
+
+// NewCurrencies loads all ISO 4217 records from the database.
+func NewCurrencies(db meddler.DB) (Currencies, error) {
+	var isos []*Iso4217
+	err := meddler.QueryAll(db, &isos, "SELECT * FROM iso_4217")
+	if err != nil {
+		return nil, err
+	}
+
+	c := make(Currencies)
+	for _, iso := range isos {
+		c[iso.Iso4217Code] = *iso
+	}
+	return c, nil
+}
+
+// Find returns the record for the passed alphabetic code (eg "AUD").
+func (c Currencies) Find(alphabeticCode string) (Iso4217, bool) {
+	for _, iso := range c {
+		if iso.AlphabeticCode == alphabeticCode {
+			return iso, true
+		}
+	}
+	return Iso4217{}, false
+}
+
 // MonetaryMeddler converts between Monetary values and the associated Postgres
 // composite type.
 type MonetaryMeddler struct{}
@@ -84,31 +173,41 @@ func (mm MonetaryMeddler) PostRead(fieldAddr, scanTarget interface{}) error {
 		return fmt.Errorf("MonetaryMeddler.PostRead: nil pointer")
 	}
 	raw := *ptr
-	str := bytes.NewBuffer(raw).String()
+	m, err := parseMonetary(bytes.NewBuffer(raw).String())
+	if err != nil {
+		return fmt.Errorf("MonetaryMeddler.PostRead: %v", err)
+	}
+	*money = m
+	return nil
+}
 
+// parseMonetary parses the text of a monetary composite type, such as
+// "(36,6269)". Spaces are allowed around each value.
+func parseMonetary(str string) (Monetary, error) {
+	money := Monetary{}
 	if !strings.HasPrefix(str, "(") || !strings.HasSuffix(str, ")") {
-		return fmt.Errorf("MonetaryMeddler.PostRead: '%s' is not a composite type", str)
+		return money, fmt.Errorf("'%s' is not a composite type", str)
 	}
 
 	str = str[1 : len(str)-1] // drop ( and )
 	split := strings.Split(str, ",")
 	if len(split) != 2 {
-		return fmt.Errorf("MonetaryMeddler.PostRead: '%s' did not have the expected 2 composite type column values", str)
+		return money, fmt.Errorf("'%s' did not have the expected 2 composite type column values", str)
 	}
 
-	iso, err := strconv.Atoi(split[0])
+	iso, err := strconv.Atoi(strings.TrimSpace(split[0]))
 	if err != nil {
-		return fmt.Errorf("MonetaryMeddler.PostRead: '%s' field '%s' is not an integer: %v", str, split[0], err)
+		return money, fmt.Errorf("'%s' field '%s' is not an integer: %v", str, split[0], err)
 	}
 	money.Iso4217Code = int16(iso)
 
-	amt, err := strconv.Atoi(split[1])
+	amt, err := strconv.Atoi(strings.TrimSpace(split[1]))
 	if err != nil {
-		return fmt.Errorf("MonetaryMeddler.PostRead: '%s' field '%s' is not an integer: %v", str, split[1], err)
+		return money, fmt.Errorf("'%s' field '%s' is not an integer: %v", str, split[1], err)
 	}
 	money.Amount = int64(amt)
 
-	return nil
+	return money, nil
 }
 
 func (mm MonetaryMeddler) PreWrite(field interface{}) (saveValue interface{}, err error) {
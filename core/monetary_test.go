@@ -19,6 +19,18 @@ func TestMonetary1DP(t *testing.T) {
 	doMoneyTest(t, "AUD", "62.69", 36, 6269)
 }
 
+func TestMonetaryNegative(t *testing.T) {
+	doMoneyTest(t, "AUD", "-62.69", 36, -6269)
+}
+
+func TestMonetaryShortFraction(t *testing.T) {
+	doMoneyTest(t, "AUD", "62.5", 36, 6250)
+}
+
+func TestMonetaryRoundedFraction(t *testing.T) {
+	doMoneyTest(t, "AUD", "62.695", 36, 6270)
+}
+
 func TestMonetaryErrorFormat(t *testing.T) {
 	doMoneyTest(t, "AUD", "62.69.34", 0, 0)
 }
@@ -69,3 +81,21 @@ func doMoneyTest(t *testing.T, curr string, amt string, expectedIso int16, expec
 		t.Fatal("Amount incorrect")
 	}
 }
+
+func TestParseMonetary(t *testing.T) {
+	for _, str := range []string{"(36,6269)", "(36, 6269)"} {
+		m, err := parseMonetary(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Iso4217Code != 36 || m.Amount != 6269 {
+			t.Fatalf("'%s' parsed as %v", str, m)
+		}
+	}
+
+	for _, str := range []string{"36,6269", "(36)", "(AUD,6269)"} {
+		if _, err := parseMonetary(str); err == nil {
+			t.Fatalf("expected '%s' to fail", str)
+		}
+	}
+}
@@ -5,6 +5,8 @@ const (
 	NtRefreshAll      NtType = "refreshall"
 	NtAccountRefresh  NtType = "accountrefresh"
 	NtAccountFeedDone NtType = "accountfeeddone"
+	NtAccountSnapshot NtType = "accountsnapshot"
+	NtAlert           NtType = "alert"
 )
 
 // NtTypes returns all official NtTypes used in the application.
@@ -13,5 +15,7 @@ func NtTypes() []NtType {
 	ntTypes = append(ntTypes, NtRefreshAll)
 	ntTypes = append(ntTypes, NtAccountRefresh)
 	ntTypes = append(ntTypes, NtAccountFeedDone)
+	ntTypes = append(ntTypes, NtAccountSnapshot)
+	ntTypes = append(ntTypes, NtAlert)
 	return ntTypes
 }
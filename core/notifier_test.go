@@ -6,16 +6,72 @@ import (
 )
 
 func TestNotifications(t *testing.T) {
+	notifier := getPgNotifier(t)
+	defer notifier.Close()
+	testNotifications(t, notifier)
+}
+
+func TestLocalNotifications(t *testing.T) {
+	notifier := NewLocalNotifier()
+	defer notifier.Close()
+	testNotifications(t, notifier)
+}
+
+func TestNotifierClosureClosesSubscribers(t *testing.T) {
+	testNotifierClosureClosesSubscribers(t, getPgNotifier(t))
+}
+
+func TestLocalNotifierClosureClosesSubscribers(t *testing.T) {
+	testNotifierClosureClosesSubscribers(t, NewLocalNotifier())
+}
+
+func TestLocalNotifierDiscardsUnregisteredTypes(t *testing.T) {
+	notifier := NewLocalNotifier()
+	defer notifier.Close()
+
+	var ntPlay NtType = "plaything"
+	err := notifier.Register(ntPlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nc := make(chan *Notification)
+	notifier.Subscribe(nc)
+	defer notifier.Unsubscribe(nc)
+
+	// notifications are delivered in order, so the unregistered one would
+	// arrive first
+	go func() {
+		notifier.Publish("unregistered", 1)
+		notifier.Publish(ntPlay, 2)
+	}()
+
+	select {
+	case msg := <-nc:
+		if msg.Type != ntPlay || msg.Id != 2 {
+			t.Fatalf("unexpected notification %+v", msg)
+		}
+	case <-time.After(3000 * time.Millisecond):
+		t.Fatal("did not receive the registered notification")
+	}
+}
+
+func getPgNotifier(t *testing.T) Notifier {
 	config := NewTestConfig(t)
 
-	notifier, err := NewNotifier(config.DbUrl)
+	notifier, err := NewPgNotifier(config.DbUrl)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer notifier.Close()
 
+	return notifier
+}
+
+// testNotifications ensures a published notification reaches every
+// subscriber.
+func testNotifications(t *testing.T, notifier Notifier) {
 	var ntPlay NtType = "plaything"
-	err = notifier.RegisterAll([]NtType{ntPlay})
+	err := notifier.RegisterAll([]NtType{ntPlay})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -56,3 +112,30 @@ func TestNotifications(t *testing.T) {
 		}
 	}
 }
+
+// testNotifierClosureClosesSubscribers ensures closing the notifier closes the
+// channel of every subscriber, and that later calls return.
+func testNotifierClosureClosesSubscribers(t *testing.T, notifier Notifier) {
+	nc1 := make(chan *Notification)
+	nc2 := make(chan *Notification)
+	notifier.Subscribe(nc1)
+	notifier.Subscribe(nc2)
+
+	notifier.Close()
+
+	for _, nc := range []chan *Notification{nc1, nc2} {
+		select {
+		case msg, ok := <-nc:
+			if ok {
+				t.Fatalf("unexpected notification %+v", msg)
+			}
+		case <-time.After(3000 * time.Millisecond):
+			t.Fatal("subscription channel not closed")
+		}
+	}
+
+	// a closed notifier neither blocks nor panics
+	notifier.Close()
+	notifier.Subscribe(make(chan *Notification))
+	notifier.Publish("plaything", 1)
+}
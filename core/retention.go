@@ -0,0 +1,86 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionTier keeps one snapshot per Interval (or every snapshot if Interval
+// is zero) until snapshots are For old. A zero For keeps them forever.
+type RetentionTier struct {
+	Interval time.Duration
+	For      time.Duration
+}
+
+// RetentionPolicy is a sequence of tiers applying to progressively older
+// snapshots. Snapshots older than every tier are deleted. An empty policy keeps
+// every snapshot forever.
+type RetentionPolicy []RetentionTier
+
+// NewRetentionPolicy parses comma-separated tiers of the form INTERVAL:AGE,
+// such as "all:30d,1h:1y,1d:forever". INTERVAL is "all" or a duration, and AGE
+// is a duration or "forever". Durations are Go durations (eg "1h") or a whole
+// number of days ("d"), weeks ("w") or 365 day years ("y").
+func NewRetentionPolicy(s string) (RetentionPolicy, error) {
+	var p RetentionPolicy
+	if s == "" {
+		return p, nil
+	}
+
+	for i, tier := range strings.Split(s, ",") {
+		parts := strings.Split(tier, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("retention tier '%s' is not INTERVAL:AGE", tier)
+		}
+
+		t := RetentionTier{}
+		var err error
+		if parts[0] != "all" {
+			t.Interval, err = parseRetentionDuration(parts[0])
+			if err != nil {
+				return nil, err
+			}
+		}
+		if parts[1] != "forever" {
+			t.For, err = parseRetentionDuration(parts[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if i > 0 {
+			previous := p[i-1]
+			if previous.For == 0 {
+				return nil, fmt.Errorf("retention tier '%s' follows a tier kept forever", tier)
+			}
+			if t.For != 0 && t.For <= previous.For {
+				return nil, fmt.Errorf("retention tier '%s' must be kept longer than %v", tier, previous.For)
+			}
+			if t.Interval <= previous.Interval {
+				return nil, fmt.Errorf("retention tier '%s' must have a longer interval than %v", tier, previous.Interval)
+			}
+		}
+		p = append(p, t)
+	}
+	return p, nil
+}
+
+func parseRetentionDuration(s string) (time.Duration, error) {
+	days := map[byte]int{'d': 1, 'w': 7, 'y': 365}
+	if len(s) > 1 {
+		if multiple, ok := days[s[len(s)-1]]; ok {
+			n, err := strconv.Atoi(s[:len(s)-1])
+			if err == nil && n > 0 {
+				return time.Duration(n*multiple) * 24 * time.Hour, nil
+			}
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("retention duration '%s' is not a positive duration", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewRetentionPolicy(t *testing.T) {
+	day := 24 * time.Hour
+	p, err := NewRetentionPolicy("all:30d,1h:1y,1d:forever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := RetentionPolicy{{For: 30 * day}, {Interval: time.Hour, For: 365 * day}, {Interval: day}}
+	if len(p) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, p)
+	}
+	for i := range p {
+		if p[i] != expected[i] {
+			t.Fatalf("expected %v but got %v", expected, p)
+		}
+	}
+
+	p, err = NewRetentionPolicy("")
+	if err != nil || len(p) != 0 {
+		t.Fatalf("expected empty policy but got %v %v", p, err)
+	}
+
+	p, err = NewRetentionPolicy("15m:2w")
+	if err != nil || len(p) != 1 || p[0].Interval != 15*time.Minute || p[0].For != 14*day {
+		t.Fatalf("unexpected policy %v %v", p, err)
+	}
+
+	for _, invalid := range []string{"all", "all:30", "all:-1d", "hourly:1y", "all:forever,1d:1y",
+		"all:30d,1h:7d", "1d:30d,1h:1y", "all:30d,all:1y"} {
+		if _, err := NewRetentionPolicy(invalid); err == nil {
+			t.Fatalf("expected error for '%s'", invalid)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package core
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io/fs"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benalexau/ibconnect/db"
+	"github.com/russross/meddler"
+	"modernc.org/sqlite"
+)
+
+const sqliteScheme = "sqlite://"
+
+// sqliteDriverName is the driver SqliteStorage opens, which wraps the SQLite
+// driver to run the queries written for Postgres.
+const sqliteDriverName = "ibconnect-sqlite"
+
+// sqliteTimeFormat is how the SQLite driver stores times (with the
+// _time_format=sqlite option).
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+var sqliteTimestamp = regexp.MustCompile(`^\d{4}-\d\d-\d\d \d\d:\d\d:\d\d(\.\d+)?[+-]\d\d:\d\d$`)
+
+// SqliteStorage is a Storage in a single SQLite file, for installations with
+// one ibcd process. Its Notifier and DistLock are local to the process, so
+// commands needing the daemon's notifications (such as ibcd refresh) are
+// unavailable. Tables are not partitioned.
+type SqliteStorage struct {
+	Path string
+}
+
+func (s *SqliteStorage) Open() (*sql.DB, error) {
+	if err := registerSqlite(); err != nil {
+		return nil, err
+	}
+	meddler.Default = meddler.SQLite
+	meddler.Register("monetary", MonetaryMeddler{})
+
+	// every transaction takes the write lock when it begins, so concurrent
+	// writers wait for the busy timeout rather than failing to upgrade a read
+	// lock
+	d, err := sql.Open(sqliteDriverName, "file:"+s.Path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)"+
+		"&_pragma=journal_mode(WAL)&_txlock=immediate&_time_format=sqlite")
+	if err != nil {
+		return nil, err
+	}
+
+	// goose's version table needs an auto-incrementing id, which SQLite only
+	// provides for an INTEGER PRIMARY KEY
+	_, err = d.Exec("CREATE TABLE IF NOT EXISTS goose_db_version (" +
+		"id INTEGER PRIMARY KEY, version_id BIGINT NOT NULL, is_applied BOOLEAN NOT NULL, " +
+		"tstamp TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP)")
+	if err != nil {
+		d.Close()
+		return nil, err
+	}
+
+	sqliteCurrencies.Lock()
+	sqliteCurrencies.db = d
+	sqliteCurrencies.Unlock()
+	return d, nil
+}
+
+func (s *SqliteStorage) NewNotifier() (Notifier, error) {
+	return NewLocalNotifier(), nil
+}
+
+func (s *SqliteStorage) NewDistLock() (DistLock, error) {
+	return NewLocalDistLock(), nil
+}
+
+func (s *SqliteStorage) Migrations() fs.FS {
+	sub, _ := fs.Sub(db.SqliteMigrations, "sqlite") // never fails for a valid directory name
+	return sub
+}
+
+var registerSqliteOnce sync.Once
+var registerSqliteErr error
+
+// registerSqlite registers the wrapping driver, and Go implementations of the
+// Postgres functions used by queries and views.
+func registerSqlite() error {
+	registerSqliteOnce.Do(func() {
+		functions := []struct {
+			name  string
+			nArgs int32
+			fun   func(args []driver.Value) (driver.Value, error)
+		}{
+			{"monetary_human", 1, sqliteMonetaryHuman},
+			{"date_trunc", 2, sqliteDateTrunc},
+			{"epoch", 1, sqliteEpoch},
+			{"floor", 1, sqliteFloor},
+			// tables are not partitioned, so there are never partitions to create
+			{"create_snapshot_partitions", 1, func(args []driver.Value) (driver.Value, error) { return int64(0), nil }},
+		}
+		for _, f := range functions {
+			fun := f.fun
+			err := sqlite.RegisterScalarFunction(f.name, f.nArgs,
+				func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+					return fun(args)
+				})
+			if err != nil {
+				registerSqliteErr = fmt.Errorf("sqlite: registering %s: %v", f.name, err)
+				return
+			}
+		}
+
+		// sql.Open does not connect, but finds the registered driver
+		probe, err := sql.Open("sqlite", "")
+		if err != nil {
+			registerSqliteErr = err
+			return
+		}
+		sql.Register(sqliteDriverName, sqliteDriver{probe.Driver()})
+		probe.Close()
+	})
+	return registerSqliteErr
+}
+
+// sqliteQuery adapts a query written for Postgres. Parameters such as $1
+// become SQLite's ?1 (as SQLite numbers $1 by its first appearance rather than
+// its digits), and row locks are dropped as SQLite transactions lock the whole
+// database.
+func sqliteQuery(query string) string {
+	b := []byte(query)
+	quoted := false
+	for i, c := range b {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case c == '$' && !quoted && i+1 < len(b) && b[i+1] >= '0' && b[i+1] <= '9':
+			b[i] = '?'
+		}
+	}
+	return strings.Replace(string(b), " FOR UPDATE", "", -1)
+}
+
+// sqliteDriver wraps the SQLite driver, adapting queries and converting
+// times. SQLite has no time type, so a time computed by an expression (rather
+// than read from a TIMESTAMP column) is otherwise returned as a string.
+type sqliteDriver struct {
+	driver.Driver
+}
+
+func (d sqliteDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteConn{c}, nil
+}
+
+type sqliteConn struct {
+	driver.Conn
+}
+
+func (c sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	s, err := c.Conn.Prepare(sqliteQuery(query))
+	if err != nil {
+		return nil, err
+	}
+	return sqliteStmt{s}, nil
+}
+
+type sqliteStmt struct {
+	driver.Stmt
+}
+
+func (s sqliteStmt) Query(args []driver.Value) (driver.Rows, error) {
+	r, err := s.Stmt.Query(args)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteRows{r}, nil
+}
+
+type sqliteRows struct {
+	driver.Rows
+}
+
+func (r sqliteRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	for i, v := range dest {
+		if s, ok := v.(string); ok && sqliteTimestamp.MatchString(s) {
+			if t, err := time.Parse(sqliteTimeFormat, s); err == nil {
+				dest[i] = t
+			}
+		}
+	}
+	return nil
+}
+
+// sqliteCurrencies caches the iso_4217 table for monetary_human, which SQLite
+// calls without access to the database. The table is reference data inserted
+// by the first migration, so once loaded it never changes.
+var sqliteCurrencies struct {
+	sync.Mutex
+	db *sql.DB
+	c  Currencies
+}
+
+func sqliteMonetaryHuman(args []driver.Value) (driver.Value, error) {
+	var str string
+	switch v := args[0].(type) {
+	case string:
+		str = v
+	case []byte:
+		str = string(v)
+	default:
+		return nil, fmt.Errorf("monetary_human: %v is not a monetary", args[0])
+	}
+	m, err := parseMonetary(str)
+	if err != nil {
+		return nil, err
+	}
+
+	sqliteCurrencies.Lock()
+	defer sqliteCurrencies.Unlock()
+	if len(sqliteCurrencies.c) == 0 {
+		c, err := NewCurrencies(sqliteCurrencies.db)
+		if err != nil {
+			return nil, err
+		}
+		sqliteCurrencies.c = c
+	}
+	return m.Human(sqliteCurrencies.c), nil
+}
+
+func sqliteDateTrunc(args []driver.Value) (driver.Value, error) {
+	t, err := sqliteTime(args[1])
+	if err != nil {
+		return nil, err
+	}
+	switch unit, _ := args[0].(string); unit {
+	case "day":
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case "month":
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return nil, fmt.Errorf("date_trunc: unsupported unit %v", args[0])
+	}
+	return t.Format(sqliteTimeFormat), nil
+}
+
+func sqliteEpoch(args []driver.Value) (driver.Value, error) {
+	t, err := sqliteTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return float64(t.UnixNano()) / float64(time.Second), nil
+}
+
+func sqliteFloor(args []driver.Value) (driver.Value, error) {
+	switch v := args[0].(type) {
+	case int64:
+		return v, nil
+	case float64:
+		return math.Floor(v), nil
+	}
+	return nil, fmt.Errorf("floor: %v is not a number", args[0])
+}
+
+// sqliteTime returns the UTC time of a function argument.
+func sqliteTime(v driver.Value) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC(), nil
+	case string:
+		for _, layout := range []string{sqliteTimeFormat, "2006-01-02 15:04:05", time.RFC3339Nano} {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed.UTC(), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("%v is not a time", v)
+}
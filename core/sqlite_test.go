@@ -0,0 +1,61 @@
+package core
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+func TestSqliteQuery(t *testing.T) {
+	for query, expected := range map[string]string{
+		"SELECT * FROM account WHERE account_code = $1":           "SELECT * FROM account WHERE account_code = ?1",
+		"SELECT * FROM v_alert WHERE account_code = $1 LIMIT $12": "SELECT * FROM v_alert WHERE account_code = ?1 LIMIT ?12",
+		"SELECT '$1', $2": "SELECT '$1', ?2",
+		"SELECT * FROM webhook WHERE enabled ORDER BY id FOR UPDATE": "SELECT * FROM webhook WHERE enabled ORDER BY id",
+		"INSERT INTO account (account_code) VALUES (?)":              "INSERT INTO account (account_code) VALUES (?)",
+	} {
+		if actual := sqliteQuery(query); actual != expected {
+			t.Fatalf("expected '%s' to become '%s', got '%s'", query, expected, actual)
+		}
+	}
+}
+
+func TestSqliteFunctions(t *testing.T) {
+	created := "2016-03-17 14:30:15.5+00:00"
+
+	v, err := sqliteDateTrunc([]driver.Value{"month", created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "2016-03-01 00:00:00+00:00" {
+		t.Fatalf("unexpected month %v", v)
+	}
+
+	v, err = sqliteDateTrunc([]driver.Value{"day", time.Date(2016, 3, 17, 14, 30, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "2016-03-17 00:00:00+00:00" {
+		t.Fatalf("unexpected day %v", v)
+	}
+
+	v, err = sqliteEpoch([]driver.Value{created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != float64(1458225015.5) {
+		t.Fatalf("unexpected epoch %v", v)
+	}
+
+	v, err = sqliteFloor([]driver.Value{float64(2.7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != float64(2) {
+		t.Fatalf("unexpected floor %v", v)
+	}
+
+	if _, err := sqliteDateTrunc([]driver.Value{"week", created}); err == nil {
+		t.Fatal("expected unsupported unit to fail")
+	}
+}
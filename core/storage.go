@@ -0,0 +1,74 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/benalexau/ibconnect/db"
+)
+
+// Storage is a database backend, providing the database along with the
+// Notifier and DistLock that coordinate the nodes sharing it.
+type Storage interface {
+	// Open configures Meddler for the database and opens it.
+	Open() (*sql.DB, error)
+
+	// NewNotifier returns a Notifier delivering notifications published by
+	// every node sharing the database.
+	NewNotifier() (Notifier, error)
+
+	// NewDistLock returns a DistLock exclusive across every node sharing the
+	// database.
+	NewDistLock() (DistLock, error)
+
+	// Migrations returns the SQL migrations of the database schema.
+	Migrations() fs.FS
+}
+
+// NewStorage returns the Storage for a postgres:// or sqlite:// URL.
+func NewStorage(dbUrl string) (Storage, error) {
+	switch {
+	case strings.HasPrefix(dbUrl, "postgres://"):
+		return &PgStorage{dbUrl}, nil
+	case strings.HasPrefix(dbUrl, sqliteScheme):
+		path := strings.TrimPrefix(dbUrl, sqliteScheme)
+		if path == "" {
+			return nil, fmt.Errorf("database URL '%s' does not name a file", dbUrl)
+		}
+		return &SqliteStorage{path}, nil
+	}
+	return nil, fmt.Errorf("database URL '%s' did not begin with postgres:// or %s", dbUrl, sqliteScheme)
+}
+
+// PgStorage is the default Storage, being a Postgres database that any number
+// of nodes can share.
+type PgStorage struct {
+	DbUrl string
+}
+
+func (s *PgStorage) Open() (*sql.DB, error) {
+	return InitMeddler(s.DbUrl)
+}
+
+func (s *PgStorage) NewNotifier() (Notifier, error) {
+	n, err := NewPgNotifier(s.DbUrl)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (s *PgStorage) NewDistLock() (DistLock, error) {
+	dl, err := NewPgDistLock(s.DbUrl)
+	if err != nil {
+		return nil, err
+	}
+	return dl, nil
+}
+
+func (s *PgStorage) Migrations() fs.FS {
+	sub, _ := fs.Sub(db.Migrations, "migrations") // never fails for a valid directory name
+	return sub
+}
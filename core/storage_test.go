@@ -0,0 +1,27 @@
+package core
+
+import "testing"
+
+func TestNewStorage(t *testing.T) {
+	s, err := NewStorage("postgres://ibc_dev@localhost/ibc_dev?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*PgStorage); !ok {
+		t.Fatalf("expected PgStorage, got %T", s)
+	}
+
+	s, err = NewStorage("sqlite:///var/lib/ibconnect/ibc.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlite, ok := s.(*SqliteStorage); !ok || sqlite.Path != "/var/lib/ibconnect/ibc.db" {
+		t.Fatalf("expected SqliteStorage of /var/lib/ibconnect/ibc.db, got %#v", s)
+	}
+
+	for _, dbUrl := range []string{"", "sqlite://", "mysql://localhost/ibc_dev"} {
+		if _, err := NewStorage(dbUrl); err == nil {
+			t.Fatalf("expected '%s' to be rejected", dbUrl)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package core
+
+import "time"
+
+type Webhook struct {
+	Id             int64     `meddler:"id,pk"`
+	Created        time.Time `meddler:"created,utctime"`
+	Url            string    `meddler:"url"`
+	Secret         string    `meddler:"secret" json:",omitempty"`
+	Enabled        bool      `meddler:"enabled"`
+	LastSnapshotId int64     `meddler:"last_snapshot_id" json:"-"`
+}
+
+type WebhookDelivery struct {
+	Id          int64     `meddler:"id,pk"`
+	WebhookId   int64     `meddler:"webhook_id"`
+	Created     time.Time `meddler:"created,utctime"`
+	Payload     string    `meddler:"payload" json:"-"`
+	Attempts    int       `meddler:"attempts"`
+	NextAttempt time.Time `meddler:"next_attempt,utctime"`
+	Delivered   time.Time `meddler:"delivered,utctimez"`
+	Failed      bool      `meddler:"failed"`
+	LastStatus  int       `meddler:"last_status"`
+	LastError   string    `meddler:"last_error"`
+}
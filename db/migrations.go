@@ -0,0 +1,16 @@
+// Package db embeds the SQL migrations so ibcd can apply them without the
+// files (or goose) being present at runtime.
+package db
+
+import "embed"
+
+// Migrations holds the Postgres migrations directory.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+// SqliteMigrations holds the SQLite migrations directory. Its versions follow
+// the Postgres migrations, with 017 creating the schema as of Postgres 017.
+//
+//go:embed sqlite/*.sql
+var SqliteMigrations embed.FS
@@ -0,0 +1,26 @@
+-- +goose Up
+
+-- fx_rate stores the exchange rates reported by IB alongside each account
+-- snapshot. The rate is the value of one major unit of the currency expressed
+-- in the account's base currency, so the base currency has a rate of 1.
+CREATE TABLE fx_rate (
+    id BIGSERIAL PRIMARY KEY,
+    account_snapshot_id BIGSERIAL NOT NULL REFERENCES account_snapshot(id) ON DELETE RESTRICT,
+    iso_4217_code SMALLINT NOT NULL REFERENCES iso_4217(iso_4217_code) ON DELETE RESTRICT,
+    rate NUMERIC NOT NULL,
+    UNIQUE(account_snapshot_id, iso_4217_code)
+);
+
+CREATE VIEW v_fx_rate AS (
+    SELECT
+        account_snapshot_id, fx_rate.iso_4217_code, alphabetic_code, rate
+    FROM
+        fx_rate,
+        iso_4217
+    WHERE
+        iso_4217.iso_4217_code = fx_rate.iso_4217_code
+);
+
+-- +goose Down
+DROP VIEW v_fx_rate;
+DROP TABLE fx_rate;
@@ -0,0 +1,101 @@
+-- +goose Up
+
+-- account_snapshot records the account's base currency, being the currency IB
+-- reports consolidated (BASE) values in. NIL (000) indicates it was unknown.
+ALTER TABLE account_snapshot
+    ADD COLUMN base_iso_4217_code SMALLINT NOT NULL DEFAULT 0 REFERENCES iso_4217(iso_4217_code) ON DELETE RESTRICT;
+
+-- account_amount stores one row per currency IB reported values in. The row
+-- with base = TRUE holds IB's BASE rollup, which is expressed in the account's
+-- base currency. Values IB did not report in a given currency are NIL (000).
+ALTER TABLE account_amount
+    ADD COLUMN iso_4217_code SMALLINT NOT NULL DEFAULT 0 REFERENCES iso_4217(iso_4217_code) ON DELETE RESTRICT,
+    ADD COLUMN base BOOLEAN NOT NULL DEFAULT FALSE,
+    DROP CONSTRAINT account_amount_account_snapshot_id_key,
+    ADD UNIQUE(account_snapshot_id, iso_4217_code, base);
+
+-- existing rows only held values reported in the base currency
+UPDATE account_amount SET iso_4217_code = (net_liquidation).iso_4217_code;
+UPDATE account_snapshot SET base_iso_4217_code = account_amount.iso_4217_code
+    FROM account_amount WHERE account_amount.account_snapshot_id = account_snapshot.id;
+
+DROP VIEW v_account_amount;
+CREATE VIEW v_account_amount AS (
+    SELECT
+        account_snapshot_id,
+        alphabetic_code AS currency,
+        base,
+	type_desc AS account_type,
+        cushion,
+        look_ahead_next_change,
+        monetary_human(accrued_cash) AS accrued_cash,
+        monetary_human(available_funds) AS available_funds,
+        monetary_human(buying_power) AS buying_power,
+        monetary_human(equity_with_loan_value) AS equity_with_loan_value,
+        monetary_human(excess_liquidity) AS excess_liquidity,
+        monetary_human(full_available_funds) AS full_available_funds,
+        monetary_human(full_excess_liquidity) AS full_excess_liquidity,
+        monetary_human(full_init_margin_req) AS full_init_margin_req,
+        monetary_human(full_maint_margin_req) AS full_maint_margin_req,
+        monetary_human(gross_position_value) AS gross_position_value,
+        monetary_human(init_margin_req) AS init_margin_req,
+        monetary_human(look_ahead_available_funds) AS look_ahead_available_funds,
+        monetary_human(look_ahead_excess_liquidity) AS look_ahead_excess_liquidity,
+        monetary_human(look_ahead_init_margin_req) AS look_ahead_init_margin_req,
+        monetary_human(look_ahead_maint_margin_req) AS look_ahead_maint_margin_req,
+        monetary_human(maint_margin_req) AS maint_margin_req,
+        monetary_human(net_liquidation) AS net_liquidation,
+        monetary_human(total_cash_balance) AS total_cash_balance,
+        monetary_human(total_cash_value) AS total_cash_value
+    FROM
+        account_amount, account_type, iso_4217
+    WHERE
+        account_type.id = account_type_id AND
+        iso_4217.iso_4217_code = account_amount.iso_4217_code
+    ORDER BY base DESC, alphabetic_code
+);
+
+-- +goose Down
+DROP VIEW v_account_amount;
+
+DELETE FROM account_amount USING account_snapshot
+    WHERE account_snapshot.id = account_amount.account_snapshot_id AND
+    (base OR account_amount.iso_4217_code <> base_iso_4217_code);
+
+ALTER TABLE account_amount
+    DROP CONSTRAINT account_amount_account_snapshot_id_iso_4217_code_base_key,
+    DROP COLUMN base,
+    DROP COLUMN iso_4217_code,
+    ADD UNIQUE(account_snapshot_id);
+
+ALTER TABLE account_snapshot DROP COLUMN base_iso_4217_code;
+
+CREATE VIEW v_account_amount AS (
+    SELECT
+        account_snapshot_id,
+	type_desc AS account_type,
+        cushion,
+        look_ahead_next_change,
+        monetary_human(accrued_cash) AS accrued_cash,
+        monetary_human(available_funds) AS available_funds,
+        monetary_human(buying_power) AS buying_power,
+        monetary_human(equity_with_loan_value) AS equity_with_loan_value,
+        monetary_human(excess_liquidity) AS excess_liquidity,
+        monetary_human(full_available_funds) AS full_available_funds,
+        monetary_human(full_excess_liquidity) AS full_excess_liquidity,
+        monetary_human(full_init_margin_req) AS full_init_margin_req,
+        monetary_human(full_maint_margin_req) AS full_maint_margin_req,
+        monetary_human(gross_position_value) AS gross_position_value,
+        monetary_human(init_margin_req) AS init_margin_req,
+        monetary_human(look_ahead_available_funds) AS look_ahead_available_funds,
+        monetary_human(look_ahead_excess_liquidity) AS look_ahead_excess_liquidity,
+        monetary_human(look_ahead_init_margin_req) AS look_ahead_init_margin_req,
+        monetary_human(look_ahead_maint_margin_req) AS look_ahead_maint_margin_req,
+        monetary_human(maint_margin_req) AS maint_margin_req,
+        monetary_human(net_liquidation) AS net_liquidation,
+        monetary_human(total_cash_balance) AS total_cash_balance,
+        monetary_human(total_cash_value) AS total_cash_value
+    FROM
+        account_amount, account_type
+    WHERE account_type.id = account_type_id
+);
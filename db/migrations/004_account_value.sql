@@ -0,0 +1,36 @@
+-- +goose Up
+
+CREATE TABLE account_value_key (
+    id BIGSERIAL PRIMARY KEY,
+    key_name VARCHAR(100) NOT NULL UNIQUE
+);
+
+-- account_value stores every account value IB reported, verbatim. The currency
+-- is IB's own string (eg "BASE", or empty for values without a currency) and
+-- the segment is IB's key suffix (eg "S" for "NetLiquidation-S", or empty for
+-- the total across all segments). account_amount is a typed, curated subset.
+CREATE TABLE account_value (
+    id BIGSERIAL PRIMARY KEY,
+    account_snapshot_id BIGSERIAL NOT NULL REFERENCES account_snapshot(id) ON DELETE RESTRICT,
+    account_value_key_id BIGSERIAL NOT NULL REFERENCES account_value_key(id) ON DELETE RESTRICT,
+    currency VARCHAR(10) NOT NULL,
+    segment VARCHAR(10) NOT NULL,
+    value VARCHAR(100) NOT NULL,
+    UNIQUE(account_snapshot_id, account_value_key_id, currency, segment)
+);
+
+CREATE VIEW v_account_value AS (
+    SELECT
+        account_snapshot_id, key_name, currency, segment, value
+    FROM
+        account_value,
+        account_value_key
+    WHERE
+        account_value_key.id = account_value.account_value_key_id
+    ORDER BY key_name, segment, currency
+);
+
+-- +goose Down
+DROP VIEW v_account_value;
+DROP TABLE account_value;
+DROP TABLE account_value_key;
@@ -0,0 +1,31 @@
+-- +goose Up
+
+-- cash_flow records external deposits (positive) and withdrawals (negative) so
+-- performance calculations can distinguish them from investment returns. IB API
+-- does not report these, so they are recorded via the REST API.
+CREATE TABLE cash_flow (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGSERIAL NOT NULL REFERENCES account(id) ON DELETE RESTRICT,
+    occurred TIMESTAMP NOT NULL,
+    amount monetary NOT NULL,
+    description VARCHAR(200) NOT NULL
+);
+
+CREATE INDEX cash_flow_account_occurred_idx ON cash_flow(account_id, occurred);
+
+CREATE VIEW v_cash_flow AS (
+    SELECT
+        cash_flow.id, account_code, occurred,
+        monetary_human(amount) AS amount,
+        description
+    FROM
+        cash_flow,
+        account
+    WHERE
+        account.id = cash_flow.account_id
+    ORDER BY occurred
+);
+
+-- +goose Down
+DROP VIEW v_cash_flow;
+DROP TABLE cash_flow;
@@ -0,0 +1,14 @@
+-- +goose Up
+
+-- contract_industry classifies contracts for exposure reporting. IB reports the
+-- industry, category and subcategory in its contract details, which are not
+-- requested by the account feed, so this table is populated externally.
+CREATE TABLE contract_industry (
+    ib_contract_id BIGINT PRIMARY KEY,
+    industry VARCHAR(100) NOT NULL,
+    category VARCHAR(100) NOT NULL DEFAULT '',
+    subcategory VARCHAR(100) NOT NULL DEFAULT ''
+);
+
+-- +goose Down
+DROP TABLE contract_industry;
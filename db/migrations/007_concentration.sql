@@ -0,0 +1,44 @@
+-- +goose Up
+
+-- account_group allows accounts to be managed together, such as the accounts
+-- held by one client of a financial advisor.
+CREATE TABLE account_group (
+    id BIGSERIAL PRIMARY KEY,
+    group_name VARCHAR(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE account_group_member (
+    account_group_id BIGINT NOT NULL REFERENCES account_group(id) ON DELETE CASCADE,
+    account_id BIGINT NOT NULL REFERENCES account(id) ON DELETE RESTRICT,
+    PRIMARY KEY(account_group_id, account_id)
+);
+
+CREATE VIEW v_account_group_member AS (
+    SELECT
+        group_name, account_code
+    FROM
+        account_group,
+        account_group_member,
+        account
+    WHERE
+        account_group.id = account_group_member.account_group_id AND
+        account.id = account_group_member.account_id
+    ORDER BY group_name, account_code
+);
+
+-- concentration_threshold is the percentage of NetLiquidation a single
+-- underlying may represent before being flagged. Each row applies to exactly
+-- one account or one group.
+CREATE TABLE concentration_threshold (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT UNIQUE REFERENCES account(id) ON DELETE CASCADE,
+    account_group_id BIGINT UNIQUE REFERENCES account_group(id) ON DELETE CASCADE,
+    threshold NUMERIC NOT NULL CHECK (threshold > 0),
+    CHECK ((account_id IS NULL) <> (account_group_id IS NULL))
+);
+
+-- +goose Down
+DROP TABLE concentration_threshold;
+DROP VIEW v_account_group_member;
+DROP TABLE account_group_member;
+DROP TABLE account_group;
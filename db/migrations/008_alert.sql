@@ -0,0 +1,69 @@
+-- +goose Up
+
+-- alert_rule defines a condition on an account metric that raises an alert.
+-- A NULL account_id applies the rule to every account. Once firing, a rule
+-- only resolves when the metric has moved past the threshold by hysteresis.
+CREATE TABLE alert_rule (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT REFERENCES account(id) ON DELETE CASCADE,
+    rule_name VARCHAR(100) NOT NULL,
+    metric VARCHAR(50) NOT NULL,
+    operator VARCHAR(2) NOT NULL CHECK (operator IN ('<', '>')),
+    threshold NUMERIC NOT NULL,
+    hysteresis NUMERIC NOT NULL DEFAULT 0 CHECK (hysteresis >= 0),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE VIEW v_alert_rule AS (
+    SELECT
+        alert_rule.id, COALESCE(account_code, '') AS account_code, rule_name,
+        metric, operator, threshold, hysteresis, enabled
+    FROM
+        alert_rule LEFT JOIN account ON account.id = alert_rule.account_id
+    ORDER BY alert_rule.id
+);
+
+-- alert_state records whether each rule is firing for each account, and the
+-- snapshot it was last evaluated against so snapshots are evaluated once.
+CREATE TABLE alert_state (
+    alert_rule_id BIGINT NOT NULL REFERENCES alert_rule(id) ON DELETE CASCADE,
+    account_id BIGINT NOT NULL REFERENCES account(id) ON DELETE CASCADE,
+    account_snapshot_id BIGINT NOT NULL REFERENCES account_snapshot(id) ON DELETE CASCADE,
+    firing BOOLEAN NOT NULL,
+    PRIMARY KEY(alert_rule_id, account_id)
+);
+
+-- alert is the append-only history of rules starting and stopping firing.
+CREATE TABLE alert (
+    id BIGSERIAL PRIMARY KEY,
+    created TIMESTAMP NOT NULL,
+    alert_rule_id BIGINT NOT NULL REFERENCES alert_rule(id) ON DELETE CASCADE,
+    account_snapshot_id BIGINT NOT NULL REFERENCES account_snapshot(id) ON DELETE RESTRICT,
+    firing BOOLEAN NOT NULL,
+    value NUMERIC NOT NULL
+);
+
+CREATE INDEX alert_created_idx ON alert(created);
+
+CREATE VIEW v_alert AS (
+    SELECT
+        alert.id, alert.created, account_code, alert_rule_id, rule_name, metric,
+        operator, threshold, firing, value
+    FROM
+        alert,
+        alert_rule,
+        account_snapshot,
+        account
+    WHERE
+        alert_rule.id = alert.alert_rule_id AND
+        account_snapshot.id = alert.account_snapshot_id AND
+        account.id = account_snapshot.account_id
+    ORDER BY alert.created DESC
+);
+
+-- +goose Down
+DROP VIEW v_alert;
+DROP TABLE alert;
+DROP TABLE alert_state;
+DROP VIEW v_alert_rule;
+DROP TABLE alert_rule;
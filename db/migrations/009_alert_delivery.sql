@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- alert_delivery is the outbox of alerts awaiting delivery by each notifier.
+-- Rows are inserted in the same transaction as the alert, so no alert is lost
+-- if a node fails before delivering it.
+CREATE TABLE alert_delivery (
+    id BIGSERIAL PRIMARY KEY,
+    alert_id BIGINT NOT NULL REFERENCES alert(id) ON DELETE CASCADE,
+    notifier VARCHAR(2000) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt TIMESTAMP NOT NULL,
+    delivered TIMESTAMP,
+    failed BOOLEAN NOT NULL DEFAULT FALSE,
+    last_error VARCHAR(1000) NOT NULL DEFAULT '',
+    UNIQUE(alert_id, notifier)
+);
+
+CREATE INDEX alert_delivery_pending_idx ON alert_delivery(notifier, next_attempt)
+    WHERE delivered IS NULL AND NOT failed;
+
+-- +goose Down
+DROP TABLE alert_delivery;
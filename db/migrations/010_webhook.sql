@@ -0,0 +1,36 @@
+-- +goose Up
+
+-- webhook is a subscription to be advised of new snapshots. last_snapshot_id
+-- is the highest snapshot ID already queued for the webhook, so a new webhook
+-- only receives snapshots taken after it was created.
+CREATE TABLE webhook (
+    id BIGSERIAL PRIMARY KEY,
+    created TIMESTAMP NOT NULL,
+    url VARCHAR(2000) NOT NULL,
+    secret VARCHAR(200) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_snapshot_id BIGINT NOT NULL DEFAULT 0
+);
+
+-- webhook_delivery is both the outbox and the delivery log. The payload is
+-- fixed when queued, so retries are identical.
+CREATE TABLE webhook_delivery (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhook(id) ON DELETE CASCADE,
+    created TIMESTAMP NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt TIMESTAMP NOT NULL,
+    delivered TIMESTAMP,
+    failed BOOLEAN NOT NULL DEFAULT FALSE,
+    last_status INTEGER NOT NULL DEFAULT 0,
+    last_error VARCHAR(1000) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX webhook_delivery_webhook_idx ON webhook_delivery(webhook_id, created);
+CREATE INDEX webhook_delivery_pending_idx ON webhook_delivery(next_attempt)
+    WHERE delivered IS NULL AND NOT failed;
+
+-- +goose Down
+DROP TABLE webhook_delivery;
+DROP TABLE webhook;
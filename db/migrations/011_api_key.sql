@@ -0,0 +1,60 @@
+-- +goose Up
+
+-- api_key authenticates REST clients. Only the SHA-256 hex digest of the key
+-- is stored. An admin key may access every account and manage the server.
+CREATE TABLE api_key (
+    id BIGSERIAL PRIMARY KEY,
+    created TIMESTAMP NOT NULL,
+    key_name VARCHAR(100) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    admin BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+-- api_key_scope permits a non-admin key to access one account, or every
+-- account of one group (including accounts later added to the group).
+CREATE TABLE api_key_scope (
+    id BIGSERIAL PRIMARY KEY,
+    api_key_id BIGINT NOT NULL REFERENCES api_key(id) ON DELETE CASCADE,
+    account_id BIGINT REFERENCES account(id) ON DELETE CASCADE,
+    account_group_id BIGINT REFERENCES account_group(id) ON DELETE CASCADE,
+    CHECK ((account_id IS NULL) <> (account_group_id IS NULL))
+);
+
+CREATE INDEX api_key_scope_api_key_idx ON api_key_scope(api_key_id);
+
+CREATE VIEW v_api_key_scope AS (
+    SELECT
+        api_key_scope.id, api_key_id, account_code, group_name
+    FROM
+        api_key_scope
+        LEFT JOIN account ON account.id = api_key_scope.account_id
+        LEFT JOIN account_group ON account_group.id = api_key_scope.account_group_id
+    ORDER BY api_key_scope.id
+);
+
+-- v_api_key_account lists the account codes each key may access.
+CREATE VIEW v_api_key_account AS (
+    SELECT
+        api_key_id, account_code
+    FROM
+        api_key_scope,
+        account
+    WHERE
+        account.id = api_key_scope.account_id
+    UNION
+    SELECT
+        api_key_id, account_code
+    FROM
+        api_key_scope,
+        account_group_member,
+        account
+    WHERE
+        account_group_member.account_group_id = api_key_scope.account_group_id AND
+        account.id = account_group_member.account_id
+);
+
+-- +goose Down
+DROP VIEW v_api_key_account;
+DROP VIEW v_api_key_scope;
+DROP TABLE api_key_scope;
+DROP TABLE api_key;
@@ -0,0 +1,8 @@
+-- +goose Up
+
+-- cert_subject is the common name of a client certificate that authenticates
+-- as the key, as an alternative to presenting the key itself.
+ALTER TABLE api_key ADD COLUMN cert_subject VARCHAR(500) UNIQUE;
+
+-- +goose Down
+ALTER TABLE api_key DROP COLUMN cert_subject;
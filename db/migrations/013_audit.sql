@@ -0,0 +1,37 @@
+-- +goose Up
+
+-- audit_log records each request for account data. principal is the name of
+-- the API key (empty if authentication is disabled) and account_codes is a
+-- comma-separated list of the accounts returned. Rows cannot be updated or
+-- deleted.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    created TIMESTAMP NOT NULL,
+    correlation_id CHAR(36) NOT NULL,
+    principal VARCHAR(100) NOT NULL,
+    api_key_id BIGINT,
+    remote_addr VARCHAR(100) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    route VARCHAR(200) NOT NULL,
+    path VARCHAR(2000) NOT NULL,
+    status INTEGER NOT NULL,
+    account_codes TEXT NOT NULL
+);
+
+CREATE INDEX audit_log_created_idx ON audit_log(created);
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH STATEMENT EXECUTE PROCEDURE audit_log_append_only();
+
+-- +goose Down
+DROP TRIGGER audit_log_append_only ON audit_log;
+DROP FUNCTION audit_log_append_only();
+DROP TABLE audit_log;
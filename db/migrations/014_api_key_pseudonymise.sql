@@ -0,0 +1,8 @@
+-- +goose Up
+
+-- pseudonymise replaces account codes with stable tokens in the responses to
+-- requests authenticated by the key.
+ALTER TABLE api_key ADD COLUMN pseudonymise BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE api_key DROP COLUMN pseudonymise;
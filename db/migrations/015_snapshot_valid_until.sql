@@ -0,0 +1,31 @@
+-- +goose Up
+
+-- valid_until is the latest time the account feed found the snapshot
+-- unchanged, extending the snapshot instead of storing an identical one. It is
+-- NULL if the snapshot was only observed when created.
+ALTER TABLE account_snapshot ADD COLUMN valid_until TIMESTAMP;
+
+CREATE OR REPLACE VIEW v_account_snapshot_latest AS (
+    SELECT
+        account_code, max(COALESCE(valid_until, created)) AS latest
+    FROM
+        account_snapshot,
+	account
+    WHERE
+        account.id = account_snapshot.account_id
+    GROUP BY account_code
+);
+
+-- +goose Down
+CREATE OR REPLACE VIEW v_account_snapshot_latest AS (
+    SELECT
+        account_code, max(created) AS latest
+    FROM
+        account_snapshot,
+	account
+    WHERE
+        account.id = account_snapshot.account_id
+    GROUP BY account_code
+);
+
+ALTER TABLE account_snapshot DROP COLUMN valid_until;
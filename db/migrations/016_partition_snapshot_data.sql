@@ -0,0 +1,243 @@
+-- +goose Up
+
+-- account_amount and account_position are partitioned by the month of their
+-- snapshot, recorded in snapshot_created (always the account_snapshot's
+-- created). Each month's rows are held in a partition named after the table
+-- and month (eg account_position_2016_03), created ahead of time by the
+-- cluster leader. Rows for a month without a partition land in the table's
+-- default partition until create_snapshot_partitions moves them out.
+DROP VIEW v_account_amount;
+DROP VIEW v_account_position;
+
+ALTER TABLE account_amount RENAME TO account_amount_unpartitioned;
+ALTER TABLE account_position RENAME TO account_position_unpartitioned;
+
+CREATE TABLE account_amount (
+    LIKE account_amount_unpartitioned INCLUDING CONSTRAINTS,
+    snapshot_created TIMESTAMP NOT NULL,
+    PRIMARY KEY (id, snapshot_created),
+    UNIQUE (account_snapshot_id, iso_4217_code, base, snapshot_created),
+    FOREIGN KEY (account_snapshot_id) REFERENCES account_snapshot(id) ON DELETE RESTRICT,
+    FOREIGN KEY (account_type_id) REFERENCES account_type(id) ON DELETE RESTRICT,
+    FOREIGN KEY (iso_4217_code) REFERENCES iso_4217(iso_4217_code) ON DELETE RESTRICT
+) PARTITION BY RANGE (snapshot_created);
+ALTER TABLE account_amount ALTER COLUMN id SET DEFAULT nextval('account_amount_id_seq');
+ALTER SEQUENCE account_amount_id_seq OWNED BY account_amount.id;
+CREATE TABLE account_amount_default PARTITION OF account_amount DEFAULT;
+
+CREATE TABLE account_position (
+    LIKE account_position_unpartitioned INCLUDING CONSTRAINTS,
+    snapshot_created TIMESTAMP NOT NULL,
+    PRIMARY KEY (id, snapshot_created),
+    UNIQUE (account_snapshot_id, contract_id, snapshot_created),
+    FOREIGN KEY (account_snapshot_id) REFERENCES account_snapshot(id) ON DELETE RESTRICT,
+    FOREIGN KEY (contract_id) REFERENCES contract(id) ON DELETE RESTRICT
+) PARTITION BY RANGE (snapshot_created);
+ALTER TABLE account_position ALTER COLUMN id SET DEFAULT nextval('account_position_id_seq');
+ALTER SEQUENCE account_position_id_seq OWNED BY account_position.id;
+CREATE TABLE account_position_default PARTITION OF account_position DEFAULT;
+
+-- create_snapshot_partition creates the partition of the table holding the
+-- passed time's month, moving any of the month's rows out of the default
+-- partition. It returns FALSE if the partition already exists.
+-- +goose StatementBegin
+CREATE FUNCTION create_snapshot_partition(parent TEXT, t TIMESTAMP) RETURNS BOOLEAN AS $$
+DECLARE
+    month_start TIMESTAMP := date_trunc('month', t);
+    month_end TIMESTAMP := date_trunc('month', t) + INTERVAL '1 month';
+    part TEXT := parent || '_' || to_char(date_trunc('month', t), 'YYYY_MM');
+BEGIN
+    IF to_regclass(part) IS NOT NULL THEN
+        RETURN FALSE;
+    END IF;
+    EXECUTE format('CREATE TABLE %I (LIKE %I INCLUDING DEFAULTS INCLUDING CONSTRAINTS)', part, parent);
+    EXECUTE format('WITH moved AS (DELETE FROM %I WHERE snapshot_created >= %L AND snapshot_created < %L RETURNING *) '
+        'INSERT INTO %I SELECT * FROM moved', parent || '_default', month_start, month_end, part);
+    EXECUTE format('ALTER TABLE %I ATTACH PARTITION %I FOR VALUES FROM (%L) TO (%L)',
+        parent, part, month_start, month_end);
+    RETURN TRUE;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- create_snapshot_partitions creates the account_amount and account_position
+-- partitions holding the passed time's month, returning how many were created.
+-- +goose StatementBegin
+CREATE FUNCTION create_snapshot_partitions(t TIMESTAMP) RETURNS INTEGER AS $$
+DECLARE
+    created INTEGER := 0;
+BEGIN
+    IF create_snapshot_partition('account_amount', t) THEN
+        created := created + 1;
+    END IF;
+    IF create_snapshot_partition('account_position', t) THEN
+        created := created + 1;
+    END IF;
+    RETURN created;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- existing rows are copied into a partition for each month holding snapshots
+SELECT create_snapshot_partitions(snapshot_month) FROM
+    (SELECT DISTINCT date_trunc('month', created) AS snapshot_month FROM account_snapshot) AS months;
+SELECT create_snapshot_partitions(now() AT TIME ZONE 'UTC');
+
+INSERT INTO account_amount
+    SELECT a.*, s.created FROM account_amount_unpartitioned a, account_snapshot s
+    WHERE s.id = a.account_snapshot_id;
+INSERT INTO account_position
+    SELECT p.*, s.created FROM account_position_unpartitioned p, account_snapshot s
+    WHERE s.id = p.account_snapshot_id;
+
+DROP TABLE account_amount_unpartitioned;
+DROP TABLE account_position_unpartitioned;
+
+CREATE VIEW v_account_amount AS (
+    SELECT
+        account_snapshot_id,
+        alphabetic_code AS currency,
+        base,
+	type_desc AS account_type,
+        cushion,
+        look_ahead_next_change,
+        monetary_human(accrued_cash) AS accrued_cash,
+        monetary_human(available_funds) AS available_funds,
+        monetary_human(buying_power) AS buying_power,
+        monetary_human(equity_with_loan_value) AS equity_with_loan_value,
+        monetary_human(excess_liquidity) AS excess_liquidity,
+        monetary_human(full_available_funds) AS full_available_funds,
+        monetary_human(full_excess_liquidity) AS full_excess_liquidity,
+        monetary_human(full_init_margin_req) AS full_init_margin_req,
+        monetary_human(full_maint_margin_req) AS full_maint_margin_req,
+        monetary_human(gross_position_value) AS gross_position_value,
+        monetary_human(init_margin_req) AS init_margin_req,
+        monetary_human(look_ahead_available_funds) AS look_ahead_available_funds,
+        monetary_human(look_ahead_excess_liquidity) AS look_ahead_excess_liquidity,
+        monetary_human(look_ahead_init_margin_req) AS look_ahead_init_margin_req,
+        monetary_human(look_ahead_maint_margin_req) AS look_ahead_maint_margin_req,
+        monetary_human(maint_margin_req) AS maint_margin_req,
+        monetary_human(net_liquidation) AS net_liquidation,
+        monetary_human(total_cash_balance) AS total_cash_balance,
+        monetary_human(total_cash_value) AS total_cash_value
+    FROM
+        account_amount, account_type, iso_4217
+    WHERE
+        account_type.id = account_type_id AND
+        iso_4217.iso_4217_code = account_amount.iso_4217_code
+    ORDER BY base DESC, alphabetic_code
+);
+
+-- created is taken from the partition key, so filtering on it skips partitions
+CREATE VIEW v_account_position AS (
+    SELECT
+        account_snapshot.id AS account_snapshot_id,
+        account_position.snapshot_created AS created, account_code, pos,
+	market_price, market_value, average_cost, unrealized_pnl, realized_pnl,
+	-- start of v_contract
+	ib_contract_id, iso_4217_code, currency, security_type, exchange,
+        symbol, local_symbol
+	-- end of v_contract
+    FROM
+        account_position,
+	account_snapshot,
+	account,
+        v_contract
+    WHERE
+        account_snapshot.id = account_position.account_snapshot_id AND
+        account.id = account_snapshot.account_id AND
+        v_contract.contract_id = account_position.contract_id
+    ORDER BY market_value
+);
+
+-- +goose Down
+DROP VIEW v_account_amount;
+DROP VIEW v_account_position;
+DROP FUNCTION create_snapshot_partitions(TIMESTAMP);
+DROP FUNCTION create_snapshot_partition(TEXT, TIMESTAMP);
+
+ALTER TABLE account_amount RENAME TO account_amount_partitioned;
+ALTER TABLE account_position RENAME TO account_position_partitioned;
+
+CREATE TABLE account_amount (
+    LIKE account_amount_partitioned INCLUDING CONSTRAINTS,
+    PRIMARY KEY (id),
+    UNIQUE (account_snapshot_id, iso_4217_code, base),
+    FOREIGN KEY (account_snapshot_id) REFERENCES account_snapshot(id) ON DELETE RESTRICT,
+    FOREIGN KEY (account_type_id) REFERENCES account_type(id) ON DELETE RESTRICT,
+    FOREIGN KEY (iso_4217_code) REFERENCES iso_4217(iso_4217_code) ON DELETE RESTRICT
+);
+INSERT INTO account_amount SELECT * FROM account_amount_partitioned;
+ALTER TABLE account_amount DROP COLUMN snapshot_created;
+ALTER TABLE account_amount ALTER COLUMN id SET DEFAULT nextval('account_amount_id_seq');
+ALTER SEQUENCE account_amount_id_seq OWNED BY account_amount.id;
+
+CREATE TABLE account_position (
+    LIKE account_position_partitioned INCLUDING CONSTRAINTS,
+    PRIMARY KEY (id),
+    UNIQUE (account_snapshot_id, contract_id),
+    FOREIGN KEY (account_snapshot_id) REFERENCES account_snapshot(id) ON DELETE RESTRICT,
+    FOREIGN KEY (contract_id) REFERENCES contract(id) ON DELETE RESTRICT
+);
+INSERT INTO account_position SELECT * FROM account_position_partitioned;
+ALTER TABLE account_position DROP COLUMN snapshot_created;
+ALTER TABLE account_position ALTER COLUMN id SET DEFAULT nextval('account_position_id_seq');
+ALTER SEQUENCE account_position_id_seq OWNED BY account_position.id;
+
+DROP TABLE account_amount_partitioned;
+DROP TABLE account_position_partitioned;
+
+CREATE VIEW v_account_amount AS (
+    SELECT
+        account_snapshot_id,
+        alphabetic_code AS currency,
+        base,
+	type_desc AS account_type,
+        cushion,
+        look_ahead_next_change,
+        monetary_human(accrued_cash) AS accrued_cash,
+        monetary_human(available_funds) AS available_funds,
+        monetary_human(buying_power) AS buying_power,
+        monetary_human(equity_with_loan_value) AS equity_with_loan_value,
+        monetary_human(excess_liquidity) AS excess_liquidity,
+        monetary_human(full_available_funds) AS full_available_funds,
+        monetary_human(full_excess_liquidity) AS full_excess_liquidity,
+        monetary_human(full_init_margin_req) AS full_init_margin_req,
+        monetary_human(full_maint_margin_req) AS full_maint_margin_req,
+        monetary_human(gross_position_value) AS gross_position_value,
+        monetary_human(init_margin_req) AS init_margin_req,
+        monetary_human(look_ahead_available_funds) AS look_ahead_available_funds,
+        monetary_human(look_ahead_excess_liquidity) AS look_ahead_excess_liquidity,
+        monetary_human(look_ahead_init_margin_req) AS look_ahead_init_margin_req,
+        monetary_human(look_ahead_maint_margin_req) AS look_ahead_maint_margin_req,
+        monetary_human(maint_margin_req) AS maint_margin_req,
+        monetary_human(net_liquidation) AS net_liquidation,
+        monetary_human(total_cash_balance) AS total_cash_balance,
+        monetary_human(total_cash_value) AS total_cash_value
+    FROM
+        account_amount, account_type, iso_4217
+    WHERE
+        account_type.id = account_type_id AND
+        iso_4217.iso_4217_code = account_amount.iso_4217_code
+    ORDER BY base DESC, alphabetic_code
+);
+
+CREATE VIEW v_account_position AS (
+    SELECT
+        account_snapshot.id AS account_snapshot_id, created, account_code, pos,
+	market_price, market_value, average_cost, unrealized_pnl, realized_pnl,
+	-- start of v_contract
+	ib_contract_id, iso_4217_code, currency, security_type, exchange,
+        symbol, local_symbol
+	-- end of v_contract
+    FROM
+        account_position,
+	account_snapshot,
+	account,
+        v_contract
+    WHERE
+        account_snapshot.id = account_position.account_snapshot_id AND
+        account.id = account_snapshot.account_id AND
+        v_contract.contract_id = account_position.contract_id
+    ORDER BY market_value
+);
@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- epoch returns the seconds since the Unix epoch of a UTC timestamp. Queries
+-- call it instead of extract, which the SQLite backend cannot provide.
+CREATE FUNCTION epoch(t TIMESTAMP) RETURNS DOUBLE PRECISION AS $$
+    SELECT CAST(extract(epoch FROM t) AS DOUBLE PRECISION)
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- +goose Down
+DROP FUNCTION epoch(TIMESTAMP);
@@ -0,0 +1,60 @@
+package exposure
+
+import (
+	"sort"
+)
+
+// DefaultThreshold is the percentage of NetLiquidation a single underlying may
+// represent when no threshold has been configured.
+const DefaultThreshold = 10.0
+
+// Underlying is the exposure to a single underlying symbol, including any
+// options or futures on it. Breach is true if GrossPercent exceeds the
+// threshold.
+type Underlying struct {
+	Bucket
+	SecurityTypes []string
+	Breach        bool
+}
+
+// Concentration presents the underlyings of one or more accounts ranked by
+// descending gross market value, with those exceeding Threshold flagged.
+type Concentration struct {
+	Currency       string
+	NetLiquidation float64
+	Threshold      float64
+	Breaches       int
+	Underlyings    []Underlying
+}
+
+// NewConcentration collapses the positions by underlying symbol. IB reports
+// the underlying as the symbol of derivative contracts, so options and futures
+// are collapsed with the underlying itself. Market value is used as the
+// measure of exposure, which understates the exposure of derivatives.
+func NewConcentration(currency string, netLiquidation float64, positions []Position, threshold float64) Concentration {
+	c := Concentration{Currency: currency, NetLiquidation: netLiquidation, Threshold: threshold}
+
+	securityTypes := make(map[string]map[string]bool)
+	for _, p := range positions {
+		if securityTypes[p.Symbol] == nil {
+			securityTypes[p.Symbol] = make(map[string]bool)
+		}
+		securityTypes[p.Symbol][p.SecurityType] = true
+	}
+
+	for _, b := range group(positions, netLiquidation, func(p Position) string { return p.Symbol }) {
+		u := Underlying{Bucket: b, SecurityTypes: []string{}}
+		for st := range securityTypes[b.Name] {
+			u.SecurityTypes = append(u.SecurityTypes, st)
+		}
+		sort.Strings(u.SecurityTypes)
+
+		// without NetLiquidation every position is a breach
+		u.Breach = netLiquidation <= 0 || u.GrossPercent > threshold
+		if u.Breach {
+			c.Breaches++
+		}
+		c.Underlyings = append(c.Underlyings, u)
+	}
+	return c
+}
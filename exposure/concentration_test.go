@@ -0,0 +1,45 @@
+package exposure
+
+import (
+	"testing"
+)
+
+func TestNewConcentration(t *testing.T) {
+	positions := []Position{
+		{Symbol: "BHP", SecurityType: "STK", MarketValue: 100},
+		{Symbol: "BHP", SecurityType: "OPT", MarketValue: -30},
+		{Symbol: "BHP", SecurityType: "FUT", MarketValue: 20},
+		{Symbol: "CBA", SecurityType: "STK", MarketValue: 50},
+		{Symbol: "RIO", SecurityType: "STK", MarketValue: 130},
+	}
+	c := NewConcentration("AUD", 1000, positions, 14)
+
+	if len(c.Underlyings) != 3 {
+		t.Fatalf("expected 3 underlyings (was %d)", len(c.Underlyings))
+	}
+
+	bhp := c.Underlyings[0]
+	if bhp.Name != "BHP" || bhp.Gross != 150 || bhp.Net != 90 || bhp.Positions != 3 {
+		t.Fatalf("unexpected BHP %v", bhp)
+	}
+	if len(bhp.SecurityTypes) != 3 || bhp.SecurityTypes[0] != "FUT" {
+		t.Fatalf("unexpected BHP security types %v", bhp.SecurityTypes)
+	}
+	if !bhp.Breach {
+		t.Fatal("BHP at 15% should breach a 14% threshold")
+	}
+
+	if c.Underlyings[1].Name != "RIO" || c.Underlyings[1].Breach {
+		t.Fatalf("RIO at 13%% should rank second without breaching %v", c.Underlyings[1])
+	}
+	if c.Breaches != 1 {
+		t.Fatalf("expected 1 breach (was %d)", c.Breaches)
+	}
+}
+
+func TestNewConcentrationWithoutNetLiquidation(t *testing.T) {
+	c := NewConcentration("AUD", 0, []Position{{Symbol: "BHP", MarketValue: 1}}, DefaultThreshold)
+	if c.Breaches != 1 {
+		t.Fatal("positions without NetLiquidation should breach")
+	}
+}
@@ -0,0 +1,13 @@
+/*
+Package exposure breaks down the positions of one or more accounts by security
+type, currency, exchange and industry.
+
+Exposures are calculated from the latest snapshot of each account. Gross market
+value sums the absolute market value of every position, whereas net market value
+allows short positions to offset long positions. Both are also expressed as a
+percentage of NetLiquidation, so gross exposure above 100% indicates leverage.
+
+Industries are read from the contract_industry table. Positions in contracts
+that have not been classified are reported under Unclassified.
+*/
+package exposure
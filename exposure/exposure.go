@@ -0,0 +1,120 @@
+package exposure
+
+import (
+	"math"
+	"sort"
+)
+
+// Unclassified is the industry of positions absent from contract_industry.
+const Unclassified = "Unclassified"
+
+// Position is a single position, with its market value expressed in the
+// report currency.
+type Position struct {
+	AccountCode  string  `meddler:"account_code"`
+	Symbol       string  `meddler:"symbol"`
+	SecurityType string  `meddler:"security_type"`
+	Iso4217Code  int16   `meddler:"iso_4217_code"`
+	Currency     string  `meddler:"currency"`
+	Exchange     string  `meddler:"exchange"`
+	Industry     string  `meddler:"industry"`
+	MarketValue  float64 `meddler:"market_value"`
+}
+
+// Bucket is the exposure to positions sharing a security type, currency,
+// exchange or industry.
+type Bucket struct {
+	Name         string
+	Positions    int
+	Gross        float64
+	Net          float64
+	GrossPercent float64
+	NetPercent   float64
+}
+
+// Report presents the exposure of one or more accounts. Buckets are sorted by
+// descending gross market value.
+type Report struct {
+	Currency       string
+	NetLiquidation float64
+	Positions      int
+	Gross          float64
+	Net            float64
+	GrossPercent   float64
+	NetPercent     float64
+	BySecurityType []Bucket
+	ByCurrency     []Bucket
+	ByExchange     []Bucket
+	ByIndustry     []Bucket
+}
+
+// NewReport groups the positions, which must all be expressed in the passed
+// currency. Percentages are zero if netLiquidation is zero.
+func NewReport(currency string, netLiquidation float64, positions []Position) Report {
+	r := Report{Currency: currency, NetLiquidation: netLiquidation}
+	total := Bucket{}
+	for _, p := range positions {
+		total.add(p)
+	}
+	total.percent(netLiquidation)
+	r.Positions = total.Positions
+	r.Gross = total.Gross
+	r.Net = total.Net
+	r.GrossPercent = total.GrossPercent
+	r.NetPercent = total.NetPercent
+
+	r.BySecurityType = group(positions, netLiquidation, func(p Position) string { return p.SecurityType })
+	r.ByCurrency = group(positions, netLiquidation, func(p Position) string { return p.Currency })
+	r.ByExchange = group(positions, netLiquidation, func(p Position) string { return p.Exchange })
+	r.ByIndustry = group(positions, netLiquidation, func(p Position) string {
+		if p.Industry == "" {
+			return Unclassified
+		}
+		return p.Industry
+	})
+	return r
+}
+
+func group(positions []Position, netLiquidation float64, name func(Position) string) []Bucket {
+	buckets := make(map[string]*Bucket)
+	for _, p := range positions {
+		n := name(p)
+		if buckets[n] == nil {
+			buckets[n] = &Bucket{Name: n}
+		}
+		buckets[n].add(p)
+	}
+
+	sorted := []Bucket{}
+	for _, b := range buckets {
+		b.percent(netLiquidation)
+		sorted = append(sorted, *b)
+	}
+	sort.Sort(byGross(sorted))
+	return sorted
+}
+
+func (b *Bucket) add(p Position) {
+	b.Positions++
+	b.Gross += math.Abs(p.MarketValue)
+	b.Net += p.MarketValue
+}
+
+func (b *Bucket) percent(netLiquidation float64) {
+	if netLiquidation == 0 {
+		return
+	}
+	b.GrossPercent = b.Gross / netLiquidation * 100
+	b.NetPercent = b.Net / netLiquidation * 100
+}
+
+type byGross []Bucket
+
+func (b byGross) Len() int      { return len(b) }
+func (b byGross) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byGross) Less(i, j int) bool {
+	if b[i].Gross == b[j].Gross {
+		return b[i].Name < b[j].Name
+	}
+	return b[i].Gross > b[j].Gross
+}
@@ -0,0 +1,49 @@
+package exposure
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewReport(t *testing.T) {
+	positions := []Position{
+		{Symbol: "BHP", SecurityType: "STK", Currency: "AUD", Exchange: "ASX", Industry: "Basic Materials", MarketValue: 600},
+		{Symbol: "CBA", SecurityType: "STK", Currency: "AUD", Exchange: "ASX", MarketValue: 300},
+		{Symbol: "SPY", SecurityType: "OPT", Currency: "USD", Exchange: "CBOE", MarketValue: -100},
+	}
+	r := NewReport("AUD", 1000, positions)
+
+	if r.Positions != 3 || r.Gross != 1000 || r.Net != 800 {
+		t.Fatalf("unexpected totals %d %f %f", r.Positions, r.Gross, r.Net)
+	}
+	if r.GrossPercent != 100 || r.NetPercent != 80 {
+		t.Fatalf("unexpected percentages %f %f", r.GrossPercent, r.NetPercent)
+	}
+
+	if len(r.BySecurityType) != 2 || r.BySecurityType[0].Name != "STK" || r.BySecurityType[0].Net != 900 {
+		t.Fatalf("unexpected security types %v", r.BySecurityType)
+	}
+	opt := r.BySecurityType[1]
+	if opt.Gross != 100 || opt.Net != -100 || math.Abs(opt.NetPercent+10) > 1e-9 {
+		t.Fatalf("unexpected short exposure %v", opt)
+	}
+
+	if len(r.ByCurrency) != 2 || len(r.ByExchange) != 2 {
+		t.Fatalf("unexpected currencies %v or exchanges %v", r.ByCurrency, r.ByExchange)
+	}
+
+	industries := make(map[string]Bucket)
+	for _, b := range r.ByIndustry {
+		industries[b.Name] = b
+	}
+	if industries[Unclassified].Positions != 2 || industries["Basic Materials"].Gross != 600 {
+		t.Fatalf("unexpected industries %v", r.ByIndustry)
+	}
+}
+
+func TestNewReportWithoutNetLiquidation(t *testing.T) {
+	r := NewReport("USD", 0, []Position{{SecurityType: "STK", MarketValue: 100}})
+	if r.GrossPercent != 0 || r.BySecurityType[0].GrossPercent != 0 {
+		t.Fatal("percentages should be zero without NetLiquidation")
+	}
+}
@@ -0,0 +1,119 @@
+package exposure
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+// ErrUnknownCurrency indicates a requested currency is not an ISO 4217 code, or
+// no exchange rate for it was captured with an account's latest snapshot.
+var ErrUnknownCurrency = errors.New("exposure: unknown currency")
+
+// LoadReport calculates the exposure of the latest snapshot of each account.
+// Values are expressed in the passed currency, or in the base currency of the
+// first account if currency is empty. Conversions use the exchange rates
+// captured with each snapshot.
+func LoadReport(db meddler.DB, accountCodes []string, currency string) (Report, error) {
+	h, err := loadHoldings(db, accountCodes, currency)
+	if err != nil {
+		return Report{}, err
+	}
+	return NewReport(h.currency, h.netLiquidation, h.positions), nil
+}
+
+// holdings are the positions and combined NetLiquidation of the latest
+// snapshot of one or more accounts, expressed in a single currency.
+type holdings struct {
+	currency       string
+	netLiquidation float64
+	positions      []Position
+}
+
+func loadHoldings(db meddler.DB, accountCodes []string, currency string) (holdings, error) {
+	h := holdings{}
+	currencies, err := core.NewCurrencies(db)
+	if err != nil {
+		return h, err
+	}
+
+	var target core.Iso4217
+	if currency != "" {
+		iso, ok := currencies.Find(currency)
+		if !ok {
+			return h, ErrUnknownCurrency
+		}
+		target = iso
+	}
+
+	for _, code := range accountCodes {
+		snap, err := LatestSnapshot(db, code)
+		if err != nil {
+			return h, err
+		}
+		if target.AlphabeticCode == "" {
+			target = currencies[snap.BaseIso4217Code]
+		}
+
+		rates, err := core.NewFxRates(db, snap.Id)
+		if err != nil {
+			return h, err
+		}
+		if _, ok := rates[target.Iso4217Code]; !ok && target.Iso4217Code != snap.BaseIso4217Code {
+			return h, ErrUnknownCurrency
+		}
+
+		amt := new(core.AccountAmount)
+		err = meddler.QueryRow(db, amt, "SELECT * FROM account_amount WHERE account_snapshot_id = $1 AND base", snap.Id)
+		if err != nil {
+			return h, err
+		}
+		nl, err := amt.NetLiquidation.Convert(currencies, rates, target.Iso4217Code)
+		if err != nil {
+			return h, fmt.Errorf("exposure: account %s: %v", code, err)
+		}
+		value, err := nl.Float(currencies)
+		if err != nil {
+			return h, err
+		}
+		h.netLiquidation += value
+
+		var ps []*Position
+		err = meddler.QueryAll(db, &ps, "SELECT account_code, symbol, security_type, iso_4217_code, currency, "+
+			"exchange, COALESCE(industry, '') AS industry, market_value FROM v_account_position "+
+			"LEFT JOIN contract_industry USING (ib_contract_id) WHERE account_snapshot_id = $1", snap.Id)
+		if err != nil {
+			return h, err
+		}
+		for _, p := range ps {
+			p.MarketValue, err = rates.Convert(p.MarketValue, p.Iso4217Code, target.Iso4217Code)
+			if err != nil {
+				return h, fmt.Errorf("exposure: account %s: %v", code, err)
+			}
+			h.positions = append(h.positions, *p)
+		}
+	}
+
+	h.currency = target.AlphabeticCode
+	return h, nil
+}
+
+// LoadConcentration calculates the concentration of the latest snapshot of each
+// account, with values expressed as for LoadReport.
+func LoadConcentration(db meddler.DB, accountCodes []string, currency string, threshold float64) (Concentration, error) {
+	h, err := loadHoldings(db, accountCodes, currency)
+	if err != nil {
+		return Concentration{}, err
+	}
+	return NewConcentration(h.currency, h.netLiquidation, h.positions, threshold), nil
+}
+
+// LatestSnapshot returns the most recent snapshot of the account.
+func LatestSnapshot(db meddler.DB, accountCode string) (*core.AccountSnapshot, error) {
+	snap := new(core.AccountSnapshot)
+	err := meddler.QueryRow(db, snap, "SELECT account_snapshot.* FROM account_snapshot, account "+
+		"WHERE account.id = account_id AND account_code = $1 ORDER BY created DESC LIMIT 1", accountCode)
+	return snap, err
+}
@@ -0,0 +1,67 @@
+package exposure
+
+import (
+	"database/sql"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+// AccountThreshold returns the concentration threshold of the account. This is
+// the threshold configured for the account, otherwise the lowest threshold of
+// any group containing it, otherwise DefaultThreshold.
+func AccountThreshold(db meddler.DB, accountCode string) (float64, error) {
+	t := new(core.ConcentrationThreshold)
+	err := meddler.QueryRow(db, t, "SELECT concentration_threshold.* FROM concentration_threshold, account "+
+		"WHERE account.id = account_id AND account_code = $1", accountCode)
+	if err == nil {
+		return t.Threshold, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	err = meddler.QueryRow(db, t, "SELECT concentration_threshold.* FROM concentration_threshold, "+
+		"account_group_member, account WHERE concentration_threshold.account_group_id = "+
+		"account_group_member.account_group_id AND account.id = account_group_member.account_id AND "+
+		"account_code = $1 ORDER BY threshold LIMIT 1", accountCode)
+	if err == sql.ErrNoRows {
+		return DefaultThreshold, nil
+	}
+	return t.Threshold, err
+}
+
+// GroupThreshold returns the concentration threshold configured for the group,
+// otherwise DefaultThreshold.
+func GroupThreshold(db meddler.DB, groupName string) (float64, error) {
+	t := new(core.ConcentrationThreshold)
+	err := meddler.QueryRow(db, t, "SELECT concentration_threshold.* FROM concentration_threshold, account_group "+
+		"WHERE account_group.id = account_group_id AND group_name = $1", groupName)
+	if err == sql.ErrNoRows {
+		return DefaultThreshold, nil
+	}
+	return t.Threshold, err
+}
+
+// GroupAccountCodes returns the codes of the accounts in the group, returning
+// sql.ErrNoRows if the group does not exist.
+func GroupAccountCodes(db meddler.DB, groupName string) ([]string, error) {
+	group := new(core.AccountGroup)
+	err := meddler.QueryRow(db, group, "SELECT * FROM account_group WHERE group_name = $1", groupName)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []*core.Account
+	err = meddler.QueryAll(db, &accounts, "SELECT account.* FROM account, account_group_member "+
+		"WHERE account.id = account_id AND account_group_id = $1 ORDER BY account_code", group.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := []string{}
+	for _, a := range accounts {
+		codes = append(codes, a.AccountCode)
+	}
+	return codes, nil
+}
@@ -3,7 +3,9 @@ package gateway
 import (
 	"database/sql"
 	"fmt"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/benalexau/ibconnect/core"
@@ -12,12 +14,16 @@ import (
 	"github.com/russross/meddler"
 )
 
+// AccountFeedFactory creates account feeds. If Compress is true, snapshots
+// identical to the account's previous snapshot are not stored, and the
+// previous snapshot's ValidUntil is extended instead.
 type AccountFeedFactory struct {
 	AccountRefresh *cronexpr.Expression
+	Compress       bool
 }
 
 func (f *AccountFeedFactory) NewFeed(ctx *FeedContext) *Feed {
-	a := &AccountFeed{}
+	a := &AccountFeed{compress: f.Compress}
 	notifications := []core.NtType{core.NtRefreshAll, core.NtAccountRefresh}
 	callback := a.callback
 	a.generic = NewGenericFeed(ctx, f.AccountRefresh, notifications, callback)
@@ -31,12 +37,17 @@ func (f *AccountFeedFactory) Done() core.NtType {
 
 type AccountFeed struct {
 	generic   *GenericFeed
+	compress  bool
 	tx        *sql.Tx                                         // scope is single callback only
 	fc        *FeedContext                                    // scope is single callback only
 	pam       *ib.PrimaryAccountManager                       // scope is single callback only
 	created   time.Time                                       // scope is single callback only
 	snapshots map[core.Account]core.AccountSnapshot           // scope is single callback only
-	amounts   map[core.AccountSnapshot]core.AccountAmount     // scope is single callback only
+	bases     map[string]string                               // scope is single callback only
+	amounts   map[amountKey]core.AccountAmount                // scope is single callback only
+	rates     map[core.AccountSnapshot][]core.FxRate          // scope is single callback only
+	values    map[core.AccountSnapshot][]core.AccountValue    // scope is single callback only
+	valueKeys map[string]core.AccountValueKey                 // scope is single callback only
 	positions map[core.AccountSnapshot][]core.AccountPosition // scope is single callback only
 }
 
@@ -63,7 +74,11 @@ func (a *AccountFeed) callback(ctx *FeedContext) {
 	a.pam = pam
 	a.created = time.Now()
 	a.snapshots = make(map[core.Account]core.AccountSnapshot)
-	a.amounts = make(map[core.AccountSnapshot]core.AccountAmount)
+	a.bases = make(map[string]string)
+	a.amounts = make(map[amountKey]core.AccountAmount)
+	a.rates = make(map[core.AccountSnapshot][]core.FxRate)
+	a.values = make(map[core.AccountSnapshot][]core.AccountValue)
+	a.valueKeys = make(map[string]core.AccountValueKey)
 	a.positions = make(map[core.AccountSnapshot][]core.AccountPosition)
 
 	defer func() {
@@ -72,7 +87,11 @@ func (a *AccountFeed) callback(ctx *FeedContext) {
 		a.pam = nil
 		a.created = time.Time{}
 		a.snapshots = nil
+		a.bases = nil
 		a.amounts = nil
+		a.rates = nil
+		a.values = nil
+		a.valueKeys = nil
 		a.positions = nil
 	}()
 
@@ -106,6 +125,14 @@ func (a *AccountFeed) processResults() error {
 		return err
 	}
 
+	if a.compress {
+		err = a.skipUnchanged()
+		if err != nil {
+			a.tx.Rollback()
+			return fmt.Errorf("gateway: account_feed skip unchanged: %v", err)
+		}
+	}
+
 	err = a.store()
 	if err != nil {
 		a.tx.Rollback()
@@ -119,210 +146,246 @@ func (a *AccountFeed) processResults() error {
 		return err
 	}
 
+	for _, snapshot := range a.snapshots {
+		a.fc.N.Publish(core.NtAccountSnapshot, snapshot.Id)
+	}
 	a.fc.N.Publish(core.NtAccountFeedDone, 1)
 	return nil
 }
 
+// amountKey identifies a single AccountAmount row, being the values reported
+// in one currency, or the BASE rollup reported in the account's base currency.
+type amountKey struct {
+	snapshot core.AccountSnapshot
+	currency string
+	base     bool
+}
+
+// baseCurrencies records the base currency of each account. IB always reports
+// NetLiquidation in the base currency, and this is needed before the BASE
+// values can be stored.
+func (a *AccountFeed) baseCurrencies() {
+	for key, value := range a.pam.Values() {
+		if key.Key == "NetLiquidation" && value.Currency != "BASE" {
+			a.bases[key.AccountCode] = value.Currency
+		}
+	}
+}
+
 func (a *AccountFeed) amount() error {
+	a.baseCurrencies()
+	details := make(map[core.AccountSnapshot]core.AccountAmount)
+
 	for key, value := range a.pam.Values() {
 		snapshot, err := a.getSnapshot(key.AccountCode)
 		if err != nil {
 			return fmt.Errorf("get snapshot %v", err)
 		}
 
-		amt := a.amounts[snapshot]
-		amt.AccountSnapshotId = snapshot.Id
-
-		if value.Currency == "BASE" {
-			continue
+		err = a.value(snapshot, key.Key, value.Currency, value.Value)
+		if err != nil {
+			return fmt.Errorf("value %s %v", key.Key, err)
 		}
 
+		detail := details[snapshot]
+
 		switch key.Key {
+		case "ExchangeRate":
+			if value.Currency == "BASE" {
+				continue
+			}
+			rate, err := a.fxRate(snapshot, value.Currency, value.Value)
+			if err != nil {
+				return fmt.Errorf("ExchangeRate %s %s %v", value.Currency, value.Value, err)
+			}
+			if rate != nil {
+				a.rates[snapshot] = append(a.rates[snapshot], *rate)
+			}
+			continue
 		case "AccountType":
-			val, err := a.getAccountType(value.Value)
+			val, err := core.GetAccountType(a.tx, value.Value)
 			if err != nil {
 				return fmt.Errorf("account type %v", err)
 			}
-			amt.AccountType = val.Id
+			detail.AccountType = val.Id
+			details[snapshot] = detail
+			continue
 		case "Cushion":
 			val, err := strconv.ParseFloat(value.Value, 64)
 			if err != nil {
 				return err
 			}
-			amt.Cushion = val
+			detail.Cushion = val
+			details[snapshot] = detail
+			continue
 		case "LookAheadNextChange":
 			val, err := strconv.Atoi(value.Value)
 			if err != nil {
 				return err
 			}
-			amt.LookAheadNextChange = int16(val)
-		case "AccruedCash":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("AccruedCash %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.AccruedCash = val
-		case "AvailableFunds":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("AvailableFunds %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.AvailableFunds = val
-		case "BuyingPower":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("BuyingPower %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.BuyingPower = val
-		case "EquityWithLoanValue":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("EquityWithLoanValue %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.EquityWithLoanValue = val
-		case "ExcessLiquidity":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("ExcessLiquidity %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.ExcessLiquidity = val
-		case "FullAvailableFunds":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("FullAvailableFunds %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.FullAvailableFunds = val
-		case "FullExcessLiquidity":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("FullExcessLiquidity %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.FullExcessLiquidity = val
-		case "FullInitMarginReq":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("FullInitMarginReq %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.FullInitMarginReq = val
-		case "FullMaintMarginReq":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("FullMaintMarginReq %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.FullMaintMarginReq = val
-		case "GrossPositionValue":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("GrossPositionValue %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.GrossPositionValue = val
-		case "InitMarginReq":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("InitMarginReq %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.InitMarginReq = val
-		case "LookAheadAvailableFunds":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("LookAheadAvailableFunds %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.LookAheadAvailableFunds = val
-		case "LookAheadExcessLiquidity":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("LookAheadExcessLiquidity %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.LookAheadExcessLiquidity = val
-		case "LookAheadInitMarginReq":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("LookAheadInitMarginReq %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.LookAheadInitMarginReq = val
-		case "LookAheadMaintMarginReq":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("LookAheadMaintMarginReq %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.LookAheadMaintMarginReq = val
-		case "MaintMarginReq":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("MaintMarginReq %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.MaintMarginReq = val
-		case "NetLiquidation":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("NetLiquidation %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.NetLiquidation = val
-		case "TotalCashBalance":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("TotalCashBalance %s %s %v", value.Currency, value.Value, err)
-			}
-			amt.TotalCashValue = val
-		case "TotalCashValue":
-			val, err := core.NewMonetary(a.tx, value.Currency, value.Value)
-			if err != nil {
-				return fmt.Errorf("TotalCashValue %s %s %v", value.Currency, value.Value, err)
+			detail.LookAheadNextChange = int16(val)
+			details[snapshot] = detail
+			continue
+		}
+
+		ak := amountKey{snapshot, value.Currency, false}
+		if value.Currency == "BASE" {
+			base, ok := a.bases[key.AccountCode]
+			if !ok {
+				continue
 			}
-			amt.TotalCashValue = val
-		default:
+			ak = amountKey{snapshot, base, true}
+		}
+
+		amt := a.amounts[ak]
+		field := monetaryField(&amt, key.Key)
+		if field == nil {
 			continue
 		}
-		a.amounts[snapshot] = amt
-	}
-	return nil
-}
 
-func (a *AccountFeed) position() error {
-	for key, value := range a.pam.Portfolio() {
-		snapshot, err := a.getSnapshot(key.AccountCode)
+		val, err := core.NewMonetary(a.tx, ak.currency, value.Value)
 		if err != nil {
-			return err
+			return fmt.Errorf("%s %s %s %v", key.Key, value.Currency, value.Value, err)
 		}
+		*field = val
 
-		newPosition := new(core.AccountPosition)
-		newPosition.AccountSnapshotId = snapshot.Id
+		amt.AccountSnapshotId = snapshot.Id
+		amt.SnapshotCreated = snapshot.Created.UTC()
+		amt.Iso4217Code = val.Iso4217Code
+		amt.Base = ak.base
+		a.amounts[ak] = amt
+	}
 
-		c := new(core.Contract)
-		c.IbContractId = value.Contract.ContractId
+	// account-level values apply to every currency
+	for ak, amt := range a.amounts {
+		detail := details[ak.snapshot]
+		amt.AccountType = detail.AccountType
+		amt.Cushion = detail.Cushion
+		amt.LookAheadNextChange = detail.LookAheadNextChange
+		a.amounts[ak] = amt
+	}
+	return nil
+}
 
-		iso := new(core.Iso4217)
-		err = meddler.QueryRow(a.tx, iso, "SELECT * FROM iso_4217 WHERE alphabetic_code = $1", value.Contract.Currency)
-		if err != nil {
-			return err
-		}
-		c.Iso4217Code = iso.Iso4217Code
+// value records the account value verbatim, splitting any segment suffix from
+// the key.
+func (a *AccountFeed) value(snapshot core.AccountSnapshot, key string, currency string, value string) error {
+	name, segment := splitSegment(key)
+	valueKey, err := a.getAccountValueKey(name)
+	if err != nil {
+		return err
+	}
 
-		symbol, err := a.getSymbol(value.Contract.Symbol)
-		if err != nil {
-			return err
-		}
-		c.SymbolId = symbol.Id
+	v := core.AccountValue{
+		AccountSnapshotId: snapshot.Id,
+		AccountValueKeyId: valueKey.Id,
+		Currency:          currency,
+		Segment:           segment,
+		Value:             value,
+	}
+	a.values[snapshot] = append(a.values[snapshot], v)
+	return nil
+}
 
-		localSymbol, err := a.getSymbol(value.Contract.LocalSymbol)
-		if err != nil {
-			return err
+// splitSegment separates IB's segment suffix (eg "-S" for securities or "-C"
+// for commodities) from an account value key.
+func splitSegment(key string) (name string, segment string) {
+	for _, suffix := range []string{"-C", "-S"} {
+		if strings.HasSuffix(key, suffix) && len(key) > len(suffix) {
+			return key[:len(key)-len(suffix)], suffix[1:]
 		}
-		c.LocalSymbolId = localSymbol.Id
+	}
+	return key, ""
+}
 
-		secType, err := a.getSecurityType(value.Contract.SecurityType)
-		if err != nil {
-			return err
-		}
-		c.SecurityTypeId = secType.Id
+// monetaryField returns the AccountAmount field that stores the passed IB
+// account value key, or nil if the key is not stored in AccountAmount.
+func monetaryField(amt *core.AccountAmount, key string) *core.Monetary {
+	switch key {
+	case "AccruedCash":
+		return &amt.AccruedCash
+	case "AvailableFunds":
+		return &amt.AvailableFunds
+	case "BuyingPower":
+		return &amt.BuyingPower
+	case "EquityWithLoanValue":
+		return &amt.EquityWithLoanValue
+	case "ExcessLiquidity":
+		return &amt.ExcessLiquidity
+	case "FullAvailableFunds":
+		return &amt.FullAvailableFunds
+	case "FullExcessLiquidity":
+		return &amt.FullExcessLiquidity
+	case "FullInitMarginReq":
+		return &amt.FullInitMarginReq
+	case "FullMaintMarginReq":
+		return &amt.FullMaintMarginReq
+	case "GrossPositionValue":
+		return &amt.GrossPositionValue
+	case "InitMarginReq":
+		return &amt.InitMarginReq
+	case "LookAheadAvailableFunds":
+		return &amt.LookAheadAvailableFunds
+	case "LookAheadExcessLiquidity":
+		return &amt.LookAheadExcessLiquidity
+	case "LookAheadInitMarginReq":
+		return &amt.LookAheadInitMarginReq
+	case "LookAheadMaintMarginReq":
+		return &amt.LookAheadMaintMarginReq
+	case "MaintMarginReq":
+		return &amt.MaintMarginReq
+	case "NetLiquidation":
+		return &amt.NetLiquidation
+	case "TotalCashBalance":
+		return &amt.TotalCashBalance
+	case "TotalCashValue":
+		return &amt.TotalCashValue
+	}
+	return nil
+}
+
+// fxRate returns the FxRate for the passed currency, or nil if the currency is
+// not an ISO 4217 currency known to the database (eg IB's CNH).
+func (a *AccountFeed) fxRate(snapshot core.AccountSnapshot, currency string, value string) (*core.FxRate, error) {
+	val, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	iso, err := core.GetIso4217(a.tx, currency)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		exg, err := a.getExchange(value.Contract.PrimaryExchange)
+	return &core.FxRate{
+		AccountSnapshotId: snapshot.Id,
+		Iso4217Code:       iso.Iso4217Code,
+		Rate:              val,
+	}, nil
+}
+
+func (a *AccountFeed) position() error {
+	for key, value := range a.pam.Portfolio() {
+		snapshot, err := a.getSnapshot(key.AccountCode)
 		if err != nil {
 			return err
 		}
-		c.PrimaryExchangeId = exg.Id
 
-		con, err := a.getContract(*c)
+		newPosition := new(core.AccountPosition)
+		newPosition.AccountSnapshotId = snapshot.Id
+		newPosition.SnapshotCreated = snapshot.Created.UTC()
+
+		con, err := core.ResolveContract(a.tx, core.ContractDetails{
+			IbContractId:    value.Contract.ContractId,
+			Currency:        value.Contract.Currency,
+			Symbol:          value.Contract.Symbol,
+			LocalSymbol:     value.Contract.LocalSymbol,
+			SecurityType:    value.Contract.SecurityType,
+			PrimaryExchange: value.Contract.PrimaryExchange,
+		}, a.created)
 		if err != nil {
 			return err
 		}
@@ -345,7 +408,7 @@ func (a *AccountFeed) position() error {
 // getSnapshot returns the correct snapshot to use for this account key,
 // taking care to create the records when required.
 func (a *AccountFeed) getSnapshot(accountKey string) (core.AccountSnapshot, error) {
-	acct, err := a.getAccount(accountKey)
+	acct, err := core.GetAccount(a.tx, accountKey)
 	if err != nil {
 		return core.AccountSnapshot{}, err
 	}
@@ -355,7 +418,7 @@ func (a *AccountFeed) getSnapshot(accountKey string) (core.AccountSnapshot, erro
 		return existing, nil
 	}
 
-	snapshot, err := a.createAccountSnapshot(acct.Id)
+	snapshot, err := a.createAccountSnapshot(acct.Id, a.bases[accountKey])
 	if err != nil {
 		return core.AccountSnapshot{}, err
 	}
@@ -364,125 +427,143 @@ func (a *AccountFeed) getSnapshot(accountKey string) (core.AccountSnapshot, erro
 	return snapshot, nil
 }
 
-// getAccount returns the Account object, creating a database record if needed.
-func (a *AccountFeed) getAccount(accountKey string) (core.Account, error) {
-	existing := new(core.Account)
-	err := meddler.QueryRow(a.tx, existing, "SELECT * FROM account WHERE account_code = $1", accountKey)
-	if err != nil && err != sql.ErrNoRows {
-		return *existing, err
-	}
-
-	if existing.Id != 0 {
-		return *existing, nil
-	}
-
-	acct := &core.Account{}
-	acct.AccountCode = accountKey
-	err = meddler.Insert(a.tx, "account", acct)
-	return *acct, err
-}
-
-// createAccountSnapshot creates an AccountSnapshot object.
-func (a *AccountFeed) createAccountSnapshot(accountId int64) (core.AccountSnapshot, error) {
+// createAccountSnapshot creates an AccountSnapshot object. The base currency
+// may be empty if it is unknown.
+func (a *AccountFeed) createAccountSnapshot(accountId int64, baseCurrency string) (core.AccountSnapshot, error) {
 	snap := &core.AccountSnapshot{}
 	snap.AccountId = accountId
 	snap.Created = a.created
+	if baseCurrency != "" {
+		iso, err := core.GetIso4217(a.tx, baseCurrency)
+		if err != nil {
+			return *snap, err
+		}
+		snap.BaseIso4217Code = iso.Iso4217Code
+	}
 	err := meddler.Insert(a.tx, "account_snapshot", snap)
 	return *snap, err
 }
 
-// getAccountType returns the AccountType object, creating a database record if needed.
-func (a *AccountFeed) getAccountType(desc string) (core.AccountType, error) {
-	existing := new(core.AccountType)
-	err := meddler.QueryRow(a.tx, existing, "SELECT * FROM account_type WHERE type_desc = $1", desc)
-	if err != nil && err != sql.ErrNoRows {
-		return *existing, err
+// getAccountValueKey returns the AccountValueKey object, creating a database
+// record if needed. Keys are cached for the callback, as every account will
+// report the same keys.
+func (a *AccountFeed) getAccountValueKey(name string) (core.AccountValueKey, error) {
+	if cached, ok := a.valueKeys[name]; ok {
+		return cached, nil
 	}
 
-	if existing.Id != 0 {
-		return *existing, nil
+	k, err := core.GetAccountValueKey(a.tx, name)
+	if err == nil {
+		a.valueKeys[name] = k
 	}
-
-	at := &core.AccountType{}
-	at.TypeDescription = desc
-	err = meddler.Insert(a.tx, "account_type", at)
-	return *at, err
+	return k, err
 }
 
-// getSecurityType returns the SecurityType object, creating a database record if needed.
-func (a *AccountFeed) getSecurityType(desc string) (core.SecurityType, error) {
-	existing := new(core.SecurityType)
-	err := meddler.QueryRow(a.tx, existing, "SELECT * FROM security_type WHERE security_type = $1", desc)
-	if err != nil && err != sql.ErrNoRows {
-		return *existing, err
-	}
+// skipUnchanged discards each new snapshot identical to the account's previous
+// snapshot, instead extending the previous snapshot's ValidUntil to this
+// callback's time. Discarded snapshots are not published, as nothing changed.
+func (a *AccountFeed) skipUnchanged() error {
+	for acct, snapshot := range a.snapshots {
+		previous := new(core.AccountSnapshot)
+		err := meddler.QueryRow(a.tx, previous, "SELECT * FROM account_snapshot WHERE account_id = $1 AND "+
+			"created < $2 ORDER BY created DESC LIMIT 1", acct.Id, snapshot.Created.UTC())
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
 
-	if existing.Id != 0 {
-		return *existing, nil
-	}
+		unchanged, err := a.unchanged(snapshot, *previous)
+		if err != nil {
+			return err
+		}
+		if !unchanged {
+			continue
+		}
 
-	st := &core.SecurityType{}
-	st.SecurityType = desc
-	err = meddler.Insert(a.tx, "security_type", st)
-	return *st, err
-}
+		_, err = a.tx.Exec("DELETE FROM account_snapshot WHERE id = $1", snapshot.Id)
+		if err != nil {
+			return err
+		}
+		_, err = a.tx.Exec("UPDATE account_snapshot SET valid_until = $1 WHERE id = $2", snapshot.Created.UTC(), previous.Id)
+		if err != nil {
+			return err
+		}
 
-// getSymbol returns the Symbol object, creating a database record if needed.
-func (a *AccountFeed) getSymbol(desc string) (core.Symbol, error) {
-	existing := new(core.Symbol)
-	err := meddler.QueryRow(a.tx, existing, "SELECT * FROM symbol WHERE symbol = $1", desc)
-	if err != nil && err != sql.ErrNoRows {
-		return *existing, err
+		delete(a.snapshots, acct)
+		delete(a.rates, snapshot)
+		delete(a.values, snapshot)
+		delete(a.positions, snapshot)
+		for ak := range a.amounts {
+			if ak.snapshot == snapshot {
+				delete(a.amounts, ak)
+			}
+		}
 	}
+	return nil
+}
 
-	if existing.Id != 0 {
-		return *existing, nil
+// unchanged returns true if the new snapshot has the same base currency,
+// amounts, rates, values and positions as the previous snapshot.
+func (a *AccountFeed) unchanged(snapshot core.AccountSnapshot, previous core.AccountSnapshot) (bool, error) {
+	if snapshot.BaseIso4217Code != previous.BaseIso4217Code {
+		return false, nil
 	}
 
-	s := &core.Symbol{}
-	s.Symbol = desc
-	err = meddler.Insert(a.tx, "symbol", s)
-	return *s, err
-}
-
-// getExchange returns the Exchange object, creating a database record if needed.
-func (a *AccountFeed) getExchange(desc string) (core.Exchange, error) {
-	existing := new(core.Exchange)
-	err := meddler.QueryRow(a.tx, existing, "SELECT * FROM exchange WHERE exchange = $1", desc)
-	if err != nil && err != sql.ErrNoRows {
-		return *existing, err
+	var amounts []core.AccountAmount
+	for ak, amt := range a.amounts {
+		if ak.snapshot == snapshot {
+			amounts = append(amounts, amt)
+		}
 	}
 
-	if existing.Id != 0 {
-		return *existing, nil
+	var previousAmounts []*core.AccountAmount
+	var previousRates []*core.FxRate
+	var previousValues []*core.AccountValue
+	var previousPositions []*core.AccountPosition
+	for _, q := range []struct {
+		dst   interface{}
+		table string
+	}{
+		{&previousAmounts, "account_amount"},
+		{&previousRates, "fx_rate"},
+		{&previousValues, "account_value"},
+		{&previousPositions, "account_position"},
+	} {
+		err := meddler.QueryAll(a.tx, q.dst, "SELECT * FROM "+q.table+" WHERE account_snapshot_id = $1", previous.Id)
+		if err != nil {
+			return false, err
+		}
 	}
 
-	e := &core.Exchange{}
-	e.Exchange = desc
-	err = meddler.Insert(a.tx, "exchange", e)
-	return *e, err
+	return sameRecords(amounts, previousAmounts) &&
+		sameRecords(a.rates[snapshot], previousRates) &&
+		sameRecords(a.values[snapshot], previousValues) &&
+		sameRecords(a.positions[snapshot], previousPositions), nil
 }
 
-// getContract returns the Contract object, creating a database record if needed.
-func (a *AccountFeed) getContract(criteria core.Contract) (core.Contract, error) {
-	existing := new(core.Contract)
-	err := meddler.QueryRow(a.tx, existing,
-		"SELECT * FROM contract WHERE ib_contract_id = $1 AND "+
-			"iso_4217_code = $2 AND symbol_id = $3 AND local_symbol_id = $4 AND "+
-			"security_type_id = $5 AND primary_exchange_id = $6",
-		criteria.IbContractId, criteria.Iso4217Code, criteria.SymbolId,
-		criteria.LocalSymbolId, criteria.SecurityTypeId, criteria.PrimaryExchangeId)
-	if err != nil && err != sql.ErrNoRows {
-		return *existing, err
-	}
-
-	if existing.Id != 0 {
-		return *existing, nil
+// sameRecords returns true if two slices (of structs or struct pointers) hold
+// the same records in any order, ignoring their Id, AccountSnapshotId and
+// SnapshotCreated.
+func sameRecords(x interface{}, y interface{}) bool {
+	fingerprints := func(records interface{}) map[string]int {
+		counts := make(map[string]int)
+		v := reflect.ValueOf(records)
+		for i := 0; i < v.Len(); i++ {
+			record := reflect.Indirect(v.Index(i))
+			copied := reflect.New(record.Type()).Elem()
+			copied.Set(record)
+			for _, name := range []string{"Id", "AccountSnapshotId", "SnapshotCreated"} {
+				if f := copied.FieldByName(name); f.IsValid() {
+					f.Set(reflect.Zero(f.Type()))
+				}
+			}
+			counts[fmt.Sprintf("%+v", copied.Interface())]++
+		}
+		return counts
 	}
-
-	criteria.Created = a.created
-	err = meddler.Insert(a.tx, "contract", &criteria)
-	return criteria, err
+	return reflect.DeepEqual(fingerprints(x), fingerprints(y))
 }
 
 // store writes the full updates into the database in a single transaction.
@@ -494,6 +575,24 @@ func (a *AccountFeed) store() error {
 		}
 	}
 
+	for _, rates := range a.rates {
+		for _, r := range rates {
+			err := meddler.Insert(a.tx, "fx_rate", &r)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, values := range a.values {
+		for _, v := range values {
+			err := meddler.Insert(a.tx, "account_value", &v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	for _, pos := range a.positions {
 		for _, p := range pos {
 			err := meddler.Insert(a.tx, "account_position", &p)
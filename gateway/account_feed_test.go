@@ -1,32 +1,149 @@
 package gateway
 
 import (
+	"database/sql"
 	"testing"
 	"time"
 
 	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
 )
 
 func TestAccountFeedInsertsDataOnStartup(t *testing.T) {
 	c := core.NewTestConfig(t)
-	var ff FeedFactory = &AccountFeedFactory{c.AccountRefresh}
+	var ff FeedFactory = &AccountFeedFactory{AccountRefresh: c.AccountRefresh}
 	TestSimpleFeedInsertsDataOnStartup(t, &ff, "account_snapshot", 15*time.Second)
 }
 
 func TestAccountFeedHandlesEngineTermination(t *testing.T) {
 	c := core.NewTestConfig(t)
-	var ff FeedFactory = &AccountFeedFactory{c.AccountRefresh}
+	var ff FeedFactory = &AccountFeedFactory{AccountRefresh: c.AccountRefresh}
 	TestSimpleFeedHandlesEngineTermination(t, &ff, 15*time.Second)
 }
 
 func TestAccountFeedHandlesNoEngine(t *testing.T) {
 	c := core.NewTestConfig(t)
-	var ff FeedFactory = &AccountFeedFactory{c.AccountRefresh}
+	var ff FeedFactory = &AccountFeedFactory{AccountRefresh: c.AccountRefresh}
 	TestSimpleFeedHandlesNoEngine(t, &ff)
 }
 
 func TestAccountFeedPublishesDoneMessage(t *testing.T) {
 	c := core.NewTestConfig(t)
-	var ff FeedFactory = &AccountFeedFactory{c.AccountRefresh}
+	var ff FeedFactory = &AccountFeedFactory{AccountRefresh: c.AccountRefresh}
 	TestSimpleFeedPublishesDoneMessage(t, &ff, 15*time.Second)
 }
+
+func TestAccountFeedInsertsFxRates(t *testing.T) {
+	c := core.NewTestConfig(t)
+	var ff FeedFactory = &AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	TestSimpleFeedInsertsDataOnStartup(t, &ff, "fx_rate", 15*time.Second)
+}
+
+func TestAccountFeedInsertsValues(t *testing.T) {
+	c := core.NewTestConfig(t)
+	var ff FeedFactory = &AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	TestSimpleFeedInsertsDataOnStartup(t, &ff, "account_value", 15*time.Second)
+}
+
+func TestSplitSegment(t *testing.T) {
+	cases := map[string][2]string{
+		"NetLiquidation":   {"NetLiquidation", ""},
+		"NetLiquidation-S": {"NetLiquidation", "S"},
+		"AvailableFunds-C": {"AvailableFunds", "C"},
+		"-S":               {"-S", ""},
+	}
+	for key, expected := range cases {
+		name, segment := splitSegment(key)
+		if name != expected[0] || segment != expected[1] {
+			t.Fatalf("%s split into '%s' '%s'", key, name, segment)
+		}
+	}
+}
+
+func TestSameRecords(t *testing.T) {
+	a := []core.FxRate{{Id: 1, AccountSnapshotId: 1, Iso4217Code: 36, Rate: 0.7}, {Id: 2, AccountSnapshotId: 1, Iso4217Code: 840, Rate: 1}}
+	b := []*core.FxRate{{Id: 8, AccountSnapshotId: 2, Iso4217Code: 840, Rate: 1}, {Id: 9, AccountSnapshotId: 2, Iso4217Code: 36, Rate: 0.7}}
+	if !sameRecords(a, b) {
+		t.Fatal("records differing only by ID and order should be the same")
+	}
+
+	b[1].Rate = 0.71
+	if sameRecords(a, b) {
+		t.Fatal("records with different rates should differ")
+	}
+	if sameRecords(a, b[:1]) || !sameRecords([]core.FxRate{}, []*core.FxRate{}) {
+		t.Fatal("unexpected result comparing different lengths or empty records")
+	}
+}
+
+func TestAccountFeedSkipsUnchanged(t *testing.T) {
+	c := core.NewTestConfig(t)
+	db, err := core.InitMeddler(c.DbUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// everything is rolled back, so the test leaves no trace
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	account, err := core.GetAccount(tx, "UNCHANGEDTEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2001, 1, 1, 12, 0, 0, 0, time.UTC)
+	previous := &core.AccountSnapshot{AccountId: account.Id, Created: created}
+	if err := meddler.Insert(tx, "account_snapshot", previous); err != nil {
+		t.Fatal(err)
+	}
+	if err := meddler.Insert(tx, "fx_rate", &core.FxRate{AccountSnapshotId: previous.Id, Iso4217Code: 36, Rate: 0.7}); err != nil {
+		t.Fatal(err)
+	}
+
+	// newSnapshot prepares a feed holding a new snapshot with the rate
+	newSnapshot := func(minutes time.Duration, rate float64) (*AccountFeed, core.AccountSnapshot) {
+		snapshot := &core.AccountSnapshot{AccountId: account.Id, Created: created.Add(minutes * time.Minute)}
+		if err := meddler.Insert(tx, "account_snapshot", snapshot); err != nil {
+			t.Fatal(err)
+		}
+		a := &AccountFeed{
+			tx:        tx,
+			snapshots: map[core.Account]core.AccountSnapshot{account: *snapshot},
+			amounts:   make(map[amountKey]core.AccountAmount),
+			rates:     map[core.AccountSnapshot][]core.FxRate{*snapshot: {{AccountSnapshotId: snapshot.Id, Iso4217Code: 36, Rate: rate}}},
+			values:    make(map[core.AccountSnapshot][]core.AccountValue),
+			positions: make(map[core.AccountSnapshot][]core.AccountPosition),
+		}
+		return a, *snapshot
+	}
+
+	a, unchanged := newSnapshot(5, 0.7)
+	if err := a.skipUnchanged(); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.snapshots) != 0 || len(a.rates) != 0 {
+		t.Fatalf("unchanged snapshot was not skipped: %v", a.snapshots)
+	}
+	if err := tx.QueryRow("SELECT id FROM account_snapshot WHERE id = $1", unchanged.Id).Scan(new(int64)); err != sql.ErrNoRows {
+		t.Fatalf("unchanged snapshot was not deleted (%v)", err)
+	}
+	extended := new(core.AccountSnapshot)
+	if err := meddler.Load(tx, "account_snapshot", extended, previous.Id); err != nil {
+		t.Fatal(err)
+	}
+	if !extended.ValidUntil.Equal(unchanged.Created) || !extended.Observed().Equal(unchanged.Created) {
+		t.Fatalf("previous snapshot valid until %v, expected %v", extended.ValidUntil, unchanged.Created)
+	}
+
+	a, changed := newSnapshot(10, 0.71)
+	if err := a.skipUnchanged(); err != nil {
+		t.Fatal(err)
+	}
+	if a.snapshots[account] != changed || len(a.rates[changed]) != 1 {
+		t.Fatal("changed snapshot was skipped")
+	}
+}
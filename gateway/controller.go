@@ -17,15 +17,15 @@ type GatewayController struct {
 	exit       chan bool
 	terminated chan struct{}
 	db         *sql.DB
-	n          *core.Notifier
-	distLock   *core.DistLock
+	n          core.Notifier
+	distLock   core.DistLock
 	ibGws      []string
 	ibClientId int
 	ffs        []FeedFactory
 	restarts   int
 }
 
-func NewGatewayController(ffs []FeedFactory, db *sql.DB, n *core.Notifier, distLock *core.DistLock, ibGws []string, ibClientId int) (*GatewayController, error) {
+func NewGatewayController(ffs []FeedFactory, db *sql.DB, n core.Notifier, distLock core.DistLock, ibGws []string, ibClientId int) (*GatewayController, error) {
 	g := &GatewayController{
 		exit:       make(chan bool),
 		terminated: make(chan struct{}),
@@ -8,7 +8,7 @@ import (
 
 func FeedFactories(c core.Config) []FeedFactory {
 	f := []FeedFactory{}
-	f = append(f, &AccountFeedFactory{c.AccountRefresh})
+	f = append(f, &AccountFeedFactory{AccountRefresh: c.AccountRefresh, Compress: c.AccountCompress})
 	return f
 }
 
@@ -37,6 +37,6 @@ type FeedError struct {
 type FeedContext struct {
 	Errors chan FeedError
 	DB     *sql.DB
-	N      *core.Notifier
+	N      core.Notifier
 	Eng    *ib.Engine
 }
@@ -68,9 +68,11 @@ func (a *GenericFeed) init() {
 		}
 	}()
 
+	// subscribed before the first callback, so the feed learns of the
+	// notifier closing at any time after it starts
 	notifyChan := make(chan *core.Notification)
+	a.ctx.N.Subscribe(notifyChan)
 	go func() {
-		a.ctx.N.Subscribe(notifyChan)
 		defer a.ctx.N.Unsubscribe(notifyChan)
 		for {
 			select {
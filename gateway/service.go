@@ -24,7 +24,7 @@ type GatewayService struct {
 
 // NewGatewayService loads a GatewayService. It guarantees any errors are reported
 // to the passed error channel.
-func NewGatewayService(errors chan<- GatewayError, ffs []FeedFactory, db *sql.DB, n *core.Notifier, ibGw string, ibClientId int) *GatewayService {
+func NewGatewayService(errors chan<- GatewayError, ffs []FeedFactory, db *sql.DB, n core.Notifier, ibGw string, ibClientId int) *GatewayService {
 	ctx := &FeedContext{
 		Errors: make(chan FeedError),
 		DB:     db,
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+// checkConfig validates the environment variables and tests that the database
+// and every IB Gateway can be reached, exiting with status 1 on any failure.
+func checkConfig(args []string) {
+	flags := flag.NewFlagSet("check-config", flag.ExitOnError)
+	timeout := flags.Duration("timeout", 5*time.Second, "time to wait for each connection")
+	flags.Parse(args)
+
+	c, err := core.NewConfig()
+	if !report("environment", err) {
+		os.Exit(1)
+	}
+
+	ok := report("database", pingDb(c.DbUrl, *timeout))
+	for _, ibGw := range c.IbGws {
+		conn, err := net.DialTimeout("tcp", ibGw, *timeout)
+		if err == nil {
+			conn.Close()
+		}
+		ok = report("gateway "+ibGw, err) && ok
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// report prints the outcome of a check, returning true if it passed.
+func report(check string, err error) bool {
+	if err != nil {
+		fmt.Printf("%-30s FAIL %v\n", check, err)
+		return false
+	}
+	fmt.Printf("%-30s OK\n", check)
+	return true
+}
+
+func pingDb(dbUrl string, timeout time.Duration) error {
+	s, err := core.NewStorage(dbUrl)
+	if err != nil {
+		return err
+	}
+	db, err := s.Open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- db.Ping()
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("no response within %v", timeout)
+	}
+}
@@ -7,8 +7,9 @@ import (
 )
 
 // handleSignals registers signal handlers, returning a channel that will be
-// closed on SIGTERM, SIGHUB or SIGINT.
-func handleSignals() <-chan struct{} {
+// closed on SIGTERM, SIGHUB or SIGINT. If reload is not nil, SIGHUP is instead
+// sent to reload (without blocking, as one pending reload suffices).
+func handleSignals(reload chan<- struct{}) <-chan struct{} {
 	terminated := make(chan struct{})
 	signalCh := make(chan os.Signal, 4)
 	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGINT)
@@ -18,8 +19,14 @@ func handleSignals() <-chan struct{} {
 			close(terminated)
 		}()
 
-		select {
-		case <-signalCh:
+		for sig := range signalCh {
+			if sig == syscall.SIGHUP && reload != nil {
+				select {
+				case reload <- struct{}{}:
+				default:
+				}
+				continue
+			}
 			return
 		}
 	}()
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/benalexau/ibconnect/archive"
+	"github.com/benalexau/ibconnect/core"
+)
+
+// export writes snapshot history to a directory of NDJSON or Parquet files,
+// printing the high-water mark to pass as -after to the next export.
+func export(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory to write the files to (created if needed)")
+	format := flags.String("format", "ndjson", "file format: ndjson or parquet")
+	from := flags.String("from", "", "RFC 3339 time of the earliest snapshot to export")
+	to := flags.String("to", "", "RFC 3339 time of the latest snapshot to export (default now)")
+	after := flags.Int64("after", 0, "only export snapshots with a greater ID, such as the previous high-water mark")
+	flags.Parse(args)
+
+	f, err := archive.NewFormat(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	o := archive.Options{To: time.Now().UTC(), After: *after}
+	if *from != "" {
+		o.From, err = time.Parse(time.RFC3339, *from)
+		if err != nil {
+			log.Fatalf("from '%s' is not RFC 3339", *from)
+		}
+	}
+	if *to != "" {
+		o.To, err = time.Parse(time.RFC3339, *to)
+		if err != nil {
+			log.Fatalf("to '%s' is not RFC 3339", *to)
+		}
+	}
+
+	c, err := core.NewConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s, err := core.NewStorage(c.DbUrl)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := s.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	err = os.MkdirAll(*dir, 0755)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	m, err := archive.Export(db, *dir, f, o)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("exported %d snapshots to %s", m.Counts[archive.SnapshotsFile], *dir)
+	fmt.Println(m.HighWaterMark)
+}
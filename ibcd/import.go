@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/benalexau/ibconnect/archive"
+	"github.com/benalexau/ibconnect/core"
+)
+
+// importArchive loads a directory of NDJSON files written by export, skipping
+// snapshots that are already stored.
+func importArchive(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory of the exported NDJSON files")
+	flags.Parse(args)
+
+	c, err := core.NewConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s, err := core.NewStorage(c.DbUrl)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := s.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	r, err := archive.Import(db, *dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("imported %d snapshots from %s (%d already stored)", r.Imported, *dir, r.Skipped)
+}
@@ -1,40 +1,46 @@
 package main
 
 import (
-	"log"
-
-	"github.com/benalexau/ibconnect/core"
-	"github.com/benalexau/ibconnect/gateway"
-	"github.com/benalexau/ibconnect/server"
+	"fmt"
+	"os"
 )
 
+// command is an ibcd subcommand, which parses its own flags.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string)
+}
+
+var commands = []command{
+	{"serve", "run the gateway feeds, alerts, webhooks and REST API (the default)", serve},
+	{"migrate", "apply database migrations", migrateSchema},
+	{"check-config", "validate the environment and test database and gateway reachability", checkConfig},
+	{"refresh", "request a refresh of every feed and wait for the account feed", refresh},
+	{"export", "write snapshot history to NDJSON or Parquet files", export},
+	{"import", "load snapshot history from NDJSON files", importArchive},
+}
+
 func main() {
-	c, err := core.NewConfig()
-	if err != nil {
-		log.Fatal(err)
+	if len(os.Args) < 2 {
+		serve(nil)
+		return
 	}
 
-	ctx, err := core.NewContext(c)
-	if err != nil {
-		log.Fatal(err)
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			cmd.run(os.Args[2:])
+			return
+		}
 	}
-	defer ctx.Close()
 
-	ffs := gateway.FeedFactories(c)
-	gatewayController, err := gateway.NewGatewayController(ffs, ctx.DB, ctx.N, ctx.DL, c.IbGws, c.IbClientId)
-	if err != nil {
-		log.Fatal(err)
+	if os.Args[1] != "help" && os.Args[1] != "-h" && os.Args[1] != "--help" {
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n\n", os.Args[1])
 	}
-	defer gatewayController.Close()
-
-	terminated := handleSignals()
-
-	handler := server.Handler(c.ErrInfo, ctx.DB, ctx.N)
-	err = server.Serve(terminated, c.Address(), handler)
-	if err != nil {
-		log.Fatal(err)
+	fmt.Fprintf(os.Stderr, "usage: %s [command] [flags]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-13s %s\n", cmd.name, cmd.usage)
 	}
-
-	// ensure we have terminated (useful if HTTP server commented out etc)
-	<-terminated
+	fmt.Fprintf(os.Stderr, "\nrun '%s <command> -h' for the flags of a command\n", os.Args[0])
+	os.Exit(2)
 }
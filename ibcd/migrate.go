@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/migrate"
+)
+
+// migrateSchema applies the SQL migrations the database does not yet have,
+// which serve also does at startup.
+func migrateSchema(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dir := flags.String("dir", "", "directory of the SQL migrations (default those built into ibcd)")
+	flags.Parse(args)
+
+	c, err := core.NewConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s, err := core.NewStorage(c.DbUrl)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ms, err := migrate.Embedded(s)
+	if *dir != "" {
+		ms, err = migrate.Load(*dir)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := s.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	dl, err := s.NewDistLock()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dl.Close()
+
+	done, err := migrate.UpLocked(db, dl, ms)
+	for _, m := range done {
+		log.Printf("applied %s", m.Name)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("applied %d of %d migrations", len(done), len(ms))
+}
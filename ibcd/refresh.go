@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+// refresh asks the running daemon to refresh every feed, blocking until the
+// account feed reports it is done.
+func refresh(args []string) {
+	flags := flag.NewFlagSet("refresh", flag.ExitOnError)
+	timeout := flags.Duration("timeout", time.Minute, "time to wait for the account feed")
+	flags.Parse(args)
+
+	c, err := core.NewConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s, err := core.NewStorage(c.DbUrl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, ok := s.(*core.SqliteStorage); ok {
+		log.Fatal("refresh needs the notifications of a Postgres database; " +
+			"with SQLite, restart ibcd serve to refresh the feeds")
+	}
+
+	n, err := s.NewNotifier()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer n.Close()
+
+	err = n.RegisterAll(core.NtTypes())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	notifications := make(chan *core.Notification)
+	n.Subscribe(notifications)
+	defer n.Unsubscribe(notifications)
+
+	n.Publish(core.NtRefreshAll, 0)
+
+	expired := time.After(*timeout)
+	for {
+		select {
+		case msg := <-notifications:
+			if msg == nil {
+				log.Fatal("notifier closed while waiting for the account feed")
+			}
+			if msg.Type == core.NtAccountFeedDone {
+				log.Print("account feed refreshed")
+				return
+			}
+		case <-expired:
+			log.Fatalf("timeout %v waiting for '%v' after '%v'; is ibcd serve running?", *timeout,
+				core.NtAccountFeedDone, core.NtRefreshAll)
+		}
+	}
+}
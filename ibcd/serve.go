@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"log"
+
+	"github.com/benalexau/ibconnect/alert"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/gateway"
+	"github.com/benalexau/ibconnect/migrate"
+	"github.com/benalexau/ibconnect/partition"
+	"github.com/benalexau/ibconnect/retention"
+	"github.com/benalexau/ibconnect/server"
+	"github.com/benalexau/ibconnect/webhook"
+)
+
+// serve applies any pending migrations, then runs the gateway feeds, alert
+// engine, webhook dispatcher, retention pruner, partition maintainer and REST
+// API until terminated by a signal.
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Parse(args)
+
+	c, err := core.NewConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, err := core.NewContext(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ctx.Close()
+
+	ms, err := migrate.Embedded(ctx.S)
+	if err != nil {
+		log.Fatal(err)
+	}
+	done, err := migrate.UpLocked(ctx.DB, ctx.DL, ms)
+	for _, m := range done {
+		log.Printf("applied migration %s", m.Name)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ffs := gateway.FeedFactories(c)
+	gatewayController, err := gateway.NewGatewayController(ffs, ctx.DB, ctx.N, ctx.DL, c.IbGws, c.IbClientId)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer gatewayController.Close()
+
+	alertEngine, err := alert.NewEngine(ctx.DB, ctx.N, ctx.DL, alert.Senders(c))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer alertEngine.Close()
+
+	webhookDispatcher, err := webhook.NewDispatcher(ctx.DB, ctx.N, ctx.DL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer webhookDispatcher.Close()
+
+	pruner, err := retention.NewPruner(ctx.DB, ctx.DL, c.Retention)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer pruner.Close()
+
+	partitionMaintainer, err := partition.NewMaintainer(ctx.DB, ctx.DL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer partitionMaintainer.Close()
+
+	// SIGHUP reloads the TLS certificate if configured
+	var reload chan struct{}
+	var certLoader *server.CertLoader
+	var tlsConfig *tls.Config
+	if c.TlsCert != "" {
+		reload = make(chan struct{}, 1)
+		certLoader, err = server.NewCertLoader(c.TlsCert, c.TlsKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tlsConfig, err = server.NewTLSConfig(certLoader, c.TlsClientCa)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	terminated := handleSignals(reload)
+	if certLoader != nil {
+		go certLoader.Watch(reload, terminated)
+	}
+
+	handler := server.Handler(c, ctx.DB, ctx.N)
+	err = server.ServeTLS(terminated, c.Address(), handler, tlsConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// ensure we have terminated (useful if HTTP server commented out etc)
+	<-terminated
+}
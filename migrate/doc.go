@@ -0,0 +1,10 @@
+/*
+Package migrate applies the SQL migrations in db/migrations without requiring
+the external goose tool.
+
+Migrations are files named NNN_description.sql holding a "-- +goose Up" section
+(and optionally a "-- +goose Down" section, which is ignored). Applied versions
+are recorded in goose's own goose_db_version table, so databases can move
+between goose and this package in either direction.
+*/
+package migrate
@@ -0,0 +1,8 @@
+package migrate
+
+import "github.com/benalexau/ibconnect/core"
+
+// Embedded returns the migrations of the Storage compiled into the binary.
+func Embedded(s core.Storage) ([]Migration, error) {
+	return loadFS(s.Migrations(), ".")
+}
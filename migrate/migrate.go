@@ -0,0 +1,206 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+// lockKey identifies the DistLock held while migrating.
+const lockKey int64 = 2816514037658437152
+
+// Migration is a schema change identified by its version number.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+}
+
+// Parse returns the Migration held in the named file, whose name must begin
+// with the version number followed by an underscore.
+func Parse(name string, contents string) (Migration, error) {
+	m := Migration{Name: name}
+	prefix := strings.SplitN(name, "_", 2)[0]
+	var err error
+	m.Version, err = strconv.ParseInt(prefix, 10, 64)
+	if err != nil || m.Version <= 0 {
+		return m, fmt.Errorf("migrate: '%s' does not begin with a version number", name)
+	}
+
+	up := false
+	var lines []string
+	for _, line := range strings.Split(contents, "\n") {
+		switch strings.TrimSpace(line) {
+		case "-- +goose Up":
+			up = true
+			continue
+		case "-- +goose Down":
+			up = false
+			continue
+		case "-- +goose StatementBegin", "-- +goose StatementEnd":
+			continue
+		}
+		if up {
+			lines = append(lines, line)
+		}
+	}
+	m.Up = strings.TrimSpace(strings.Join(lines, "\n"))
+	if m.Up == "" {
+		return m, fmt.Errorf("migrate: '%s' has no '-- +goose Up' statements", name)
+	}
+	return m, nil
+}
+
+// Load returns the migrations in the directory ordered by version.
+func Load(dir string) ([]Migration, error) {
+	return loadFS(os.DirFS(dir), ".")
+}
+
+func loadFS(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ms []Migration
+	for _, f := range files {
+		if f.IsDir() || path.Ext(f.Name()) != ".sql" {
+			continue
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, err := Parse(f.Name(), string(b))
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	return ms, sorted(ms)
+}
+
+// sorted orders the migrations by version, returning an error if two share a
+// version.
+func sorted(ms []Migration) error {
+	sort.Sort(byVersion(ms))
+	for i := 1; i < len(ms); i++ {
+		if ms[i].Version == ms[i-1].Version {
+			return fmt.Errorf("migrate: '%s' and '%s' have the same version", ms[i-1].Name, ms[i].Name)
+		}
+	}
+	return nil
+}
+
+type byVersion []Migration
+
+func (b byVersion) Len() int           { return len(b) }
+func (b byVersion) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byVersion) Less(i, j int) bool { return b[i].Version < b[j].Version }
+
+// Applied returns the versions recorded as applied, creating goose's version
+// table if needed.
+func Applied(db *sql.DB) (map[int64]bool, error) {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS goose_db_version (" +
+		"id SERIAL PRIMARY KEY, version_id BIGINT NOT NULL, is_applied BOOLEAN NOT NULL, " +
+		"tstamp TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP)")
+	if err != nil {
+		return nil, err
+	}
+
+	// the most recent row of each version says whether it is applied
+	rows, err := db.Query("SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[int64]bool)
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		if err := rows.Scan(&version, &isApplied); err != nil {
+			return nil, err
+		}
+		if !seen[version] {
+			seen[version] = true
+			if isApplied && version > 0 {
+				applied[version] = true
+			}
+		}
+	}
+	return applied, rows.Err()
+}
+
+// Up applies each migration not yet applied in version order, each in its own
+// transaction, returning the migrations applied. It refuses to apply any if
+// the database has a version later than the last migration, as the schema is
+// then newer than the caller understands.
+func Up(db *sql.DB, ms []Migration) ([]Migration, error) {
+	applied, err := Applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := int64(0)
+	if len(ms) > 0 {
+		latest = ms[len(ms)-1].Version
+	}
+	for version := range applied {
+		if version > latest {
+			return nil, fmt.Errorf("migrate: database schema version %d is newer than the latest migration %d; "+
+				"upgrade ibcd", version, latest)
+		}
+	}
+
+	var done []Migration
+	for _, m := range ms {
+		if applied[m.Version] {
+			continue
+		}
+		if err := apply(db, m); err != nil {
+			return done, fmt.Errorf("migrate: %s: %v", m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// UpLocked calls Up while holding a DistLock, so when several nodes start
+// together one migrates while the others wait and then find nothing to apply.
+func UpLocked(db *sql.DB, dl core.DistLock, ms []Migration) ([]Migration, error) {
+	abandon := make(chan struct{})
+	defer close(abandon)
+	if acquired := <-dl.Request(lockKey, abandon); !acquired {
+		return nil, errors.New("migrate: lock manager closed before the migration lock was acquired")
+	}
+	return Up(db, ms)
+}
+
+func apply(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the whole section is sent at once, which the database runs statement by
+	// statement (including function bodies goose wraps in StatementBegin)
+	if _, err := tx.Exec(m.Up); err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, TRUE)", m.Version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,185 @@
+package migrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+func TestParse(t *testing.T) {
+	contents := `-- +goose Up
+CREATE TABLE a (id INT);
+
+-- +goose StatementBegin
+CREATE FUNCTION f() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'no';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE a;
+`
+	m, err := Parse("007_example.sql", contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != 7 || m.Name != "007_example.sql" {
+		t.Fatalf("unexpected migration %+v", m)
+	}
+	if !strings.HasPrefix(m.Up, "CREATE TABLE a") || !strings.HasSuffix(m.Up, "LANGUAGE plpgsql;") ||
+		strings.Contains(m.Up, "goose") || strings.Contains(m.Up, "DROP") {
+		t.Fatalf("unexpected up section:\n%s", m.Up)
+	}
+
+	for _, name := range []string{"example.sql", "000_zero.sql"} {
+		if _, err := Parse(name, contents); err == nil {
+			t.Fatalf("expected error for %s", name)
+		}
+	}
+	if _, err := Parse("008_empty.sql", "-- +goose Down\nDROP TABLE a;\n"); err == nil {
+		t.Fatal("expected error for migration without up section")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	ms, err := Load("../db/migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) == 0 {
+		t.Fatal("no migrations loaded")
+	}
+	for i, m := range ms {
+		if m.Version != int64(i+1) {
+			t.Fatalf("migration %s is version %d but expected %d", m.Name, m.Version, i+1)
+		}
+	}
+}
+
+func TestUp(t *testing.T) {
+	c := core.NewTestConfig(t)
+	db, err := core.InitMeddler(c.DbUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ms, err := Load("../db/migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first run applies whatever goose has not, and the second nothing
+	if _, err := Up(db, ms); err != nil {
+		t.Fatal(err)
+	}
+	done, err := Up(db, ms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 0 {
+		t.Fatalf("expected no migrations to apply, but applied %d", len(done))
+	}
+
+	applied, err := Applied(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range ms {
+		if !applied[m.Version] {
+			t.Fatalf("%s is not recorded as applied", m.Name)
+		}
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	for dir, s := range map[string]core.Storage{
+		"../db/migrations": &core.PgStorage{},
+		"../db/sqlite":     &core.SqliteStorage{},
+	} {
+		embedded, err := Embedded(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ms, err := Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(embedded) != len(ms) || embedded[len(ms)-1].Up != ms[len(ms)-1].Up {
+			t.Fatalf("embedded %d migrations but %s has %d", len(embedded), dir, len(ms))
+		}
+	}
+}
+
+func TestUpRefusesNewerSchema(t *testing.T) {
+	c := core.NewTestConfig(t)
+	ctx, err := core.NewContext(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	ms, err := Embedded(ctx.S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UpLocked(ctx.DB, ctx.DL, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	// a binary knowing only the earlier migrations must not touch the schema
+	if _, err := UpLocked(ctx.DB, ctx.DL, ms[:len(ms)-1]); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Fatalf("expected newer schema to be refused, got %v", err)
+	}
+}
+
+func TestUpSqlite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ibconnect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &core.SqliteStorage{Path: filepath.Join(dir, "ibc.db")}
+	db, err := s.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dl, err := s.NewDistLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dl.Close()
+
+	ms, err := Embedded(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := UpLocked(db, dl, ms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(ms) {
+		t.Fatalf("applied %d of %d migrations to a new database", len(done), len(ms))
+	}
+	if done, err = UpLocked(db, dl, ms); err != nil || len(done) != 0 {
+		t.Fatalf("expected no migrations to apply, but applied %d (%v)", len(done), err)
+	}
+
+	// views read monetary values through the Go implementation of monetary_human
+	var human string
+	if err := db.QueryRow("SELECT monetary_human($1)", "(36, 6269)").Scan(&human); err != nil {
+		t.Fatal(err)
+	}
+	if human != "AUD 62.69" {
+		t.Fatalf("unexpected monetary_human '%s'", human)
+	}
+}
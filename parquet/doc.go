@@ -0,0 +1,15 @@
+/*
+Package parquet writes Apache Parquet files.
+
+Only what IB Connect's exports need is supported: a flat schema of required
+columns derived from a struct, PLAIN encoding and no compression. Records are
+buffered in memory until a row group is full, so files of any size can be
+written with bounded memory. The file metadata is encoded with the Thrift
+compact protocol, which is implemented here to keep the package free of
+external dependencies.
+
+Struct fields map to Parquet columns as follows: bool to BOOLEAN, integers to
+INT64, floats to DOUBLE, strings to UTF8 BYTE_ARRAY and time.Time to INT64
+TIMESTAMP_MICROS (in UTC). Unexported fields are ignored.
+*/
+package parquet
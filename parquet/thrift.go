@@ -0,0 +1,89 @@
+package parquet
+
+import "bytes"
+
+// Thrift compact protocol type identifiers.
+const (
+	tI32    = 5
+	tI64    = 6
+	tBinary = 8
+	tList   = 9
+	tStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol. Field IDs are
+// delta encoded against the previous field of the enclosing struct.
+type thriftWriter struct {
+	bytes.Buffer
+	last []int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	for v >= 0x80 {
+		t.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	t.WriteByte(byte(v))
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, tI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, tI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.field(id, tBinary)
+	t.str(v)
+}
+
+func (t *thriftWriter) str(v string) {
+	t.varint(uint64(len(v)))
+	t.WriteString(v)
+}
+
+// list writes the header of a list field, whose elements must then be written.
+func (t *thriftWriter) list(id int16, elem byte, size int) {
+	t.field(id, tList)
+	if size < 15 {
+		t.WriteByte(byte(size)<<4 | elem)
+	} else {
+		t.WriteByte(0xf0 | elem)
+		t.varint(uint64(size))
+	}
+}
+
+// begin starts a struct. A struct field requires a field header first, whereas
+// a top level struct or list element does not.
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, tStruct)
+	t.begin()
+}
+
+func (t *thriftWriter) end() {
+	t.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
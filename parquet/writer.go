@@ -0,0 +1,276 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// magic begins and ends every Parquet file.
+const magic = "PAR1"
+
+// DefaultRowGroupSize is the number of records buffered per row group.
+const DefaultRowGroupSize = 10000
+
+// Parquet physical types, converted types and other enumerations.
+const (
+	typeBoolean         = 0
+	typeInt64           = 2
+	typeDouble          = 5
+	typeByteArray       = 6
+	convertedNone       = -1
+	convertedUtf8       = 0
+	convertedTimeMicros = 10
+	repetitionRequired  = 0
+	encodingPlain       = 0
+	encodingRle         = 3
+	codecUncompressed   = 0
+	pageData            = 0
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// column buffers the values of one struct field for the current row group.
+type column struct {
+	name      string
+	field     int
+	physical  int32
+	converted int32
+	values    bytes.Buffer
+	bools     []bool
+}
+
+// chunk records where a column chunk was written.
+type chunk struct {
+	offset int64
+	size   int64
+}
+
+type rowGroup struct {
+	rows   int64
+	size   int64
+	chunks []chunk
+}
+
+// Writer writes records of a single struct type as a Parquet file.
+type Writer struct {
+	RowGroupSize int
+	w            io.Writer
+	offset       int64
+	typ          reflect.Type
+	columns      []*column
+	rows         int
+	numRows      int64
+	groups       []rowGroup
+}
+
+// NewWriter returns a Writer whose schema is derived from the prototype, which
+// must be a struct or a pointer to one. The file header is written immediately.
+func NewWriter(w io.Writer, prototype interface{}) (*Writer, error) {
+	typ := reflect.TypeOf(prototype)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("parquet: %v is not a struct", typ)
+	}
+
+	pw := &Writer{RowGroupSize: DefaultRowGroupSize, w: w, typ: typ}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		c := &column{name: f.Name, field: i, converted: convertedNone}
+		switch {
+		case f.Type == timeType:
+			c.physical, c.converted = typeInt64, convertedTimeMicros
+		case f.Type.Kind() == reflect.Bool:
+			c.physical = typeBoolean
+		case f.Type.Kind() >= reflect.Int && f.Type.Kind() <= reflect.Uint64:
+			c.physical = typeInt64
+		case f.Type.Kind() == reflect.Float32 || f.Type.Kind() == reflect.Float64:
+			c.physical = typeDouble
+		case f.Type.Kind() == reflect.String:
+			c.physical, c.converted = typeByteArray, convertedUtf8
+		default:
+			return nil, fmt.Errorf("parquet: field %s has unsupported type %v", f.Name, f.Type)
+		}
+		pw.columns = append(pw.columns, c)
+	}
+
+	return pw, pw.write([]byte(magic))
+}
+
+// Write buffers the record, writing a row group once RowGroupSize records are
+// buffered. The record must be of the prototype's type or a pointer to it.
+func (w *Writer) Write(record interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(record))
+	if v.Type() != w.typ {
+		return fmt.Errorf("parquet: record is %v rather than %v", v.Type(), w.typ)
+	}
+
+	for _, c := range w.columns {
+		f := v.Field(c.field)
+		var b [8]byte
+		switch {
+		case c.converted == convertedTimeMicros:
+			t := f.Interface().(time.Time)
+			binary.LittleEndian.PutUint64(b[:], uint64(t.Unix()*1e6+int64(t.Nanosecond()/1e3)))
+			c.values.Write(b[:])
+		case c.physical == typeBoolean:
+			c.bools = append(c.bools, f.Bool())
+		case c.physical == typeInt64:
+			if f.Kind() >= reflect.Uint {
+				binary.LittleEndian.PutUint64(b[:], f.Uint())
+			} else {
+				binary.LittleEndian.PutUint64(b[:], uint64(f.Int()))
+			}
+			c.values.Write(b[:])
+		case c.physical == typeDouble:
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(f.Float()))
+			c.values.Write(b[:])
+		case c.physical == typeByteArray:
+			binary.LittleEndian.PutUint32(b[:4], uint32(f.Len()))
+			c.values.Write(b[:4])
+			c.values.WriteString(f.String())
+		}
+	}
+
+	w.rows++
+	if w.rows >= w.RowGroupSize {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush writes any buffered records as a row group.
+func (w *Writer) Flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+
+	g := rowGroup{rows: int64(w.rows)}
+	for _, c := range w.columns {
+		data := c.values.Bytes()
+		if c.physical == typeBoolean {
+			data = make([]byte, (len(c.bools)+7)/8)
+			for i, b := range c.bools {
+				if b {
+					data[i/8] |= 1 << uint(i%8)
+				}
+			}
+		}
+
+		t := &thriftWriter{}
+		t.begin()
+		t.i32(1, pageData)
+		t.i32(2, int32(len(data)))
+		t.i32(3, int32(len(data)))
+		t.structField(5)
+		t.i32(1, int32(w.rows))
+		t.i32(2, encodingPlain)
+		t.i32(3, encodingRle)
+		t.i32(4, encodingRle)
+		t.end()
+		t.end()
+
+		ch := chunk{offset: w.offset, size: int64(t.Len() + len(data))}
+		if err := w.write(t.Bytes()); err != nil {
+			return err
+		}
+		if err := w.write(data); err != nil {
+			return err
+		}
+		g.chunks = append(g.chunks, ch)
+		g.size += ch.size
+
+		c.values.Reset()
+		c.bools = c.bools[:0]
+	}
+
+	w.groups = append(w.groups, g)
+	w.numRows += g.rows
+	w.rows = 0
+	return nil
+}
+
+// Close flushes any buffered records and writes the file metadata. It does
+// not close the underlying io.Writer.
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	t := &thriftWriter{}
+	t.begin()
+	t.i32(1, 1)
+
+	t.list(2, tStruct, len(w.columns)+1)
+	t.begin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(w.columns)))
+	t.end()
+	for _, c := range w.columns {
+		t.begin()
+		t.i32(1, c.physical)
+		t.i32(3, repetitionRequired)
+		t.binary(4, c.name)
+		if c.converted != convertedNone {
+			t.i32(6, c.converted)
+		}
+		t.end()
+	}
+
+	t.i64(3, w.numRows)
+
+	t.list(4, tStruct, len(w.groups))
+	for _, g := range w.groups {
+		t.begin()
+		t.list(1, tStruct, len(g.chunks))
+		for i, ch := range g.chunks {
+			c := w.columns[i]
+			t.begin()
+			t.i64(2, ch.offset)
+			t.structField(3)
+			t.i32(1, c.physical)
+			t.list(2, tI32, 1)
+			t.zigzag(encodingPlain)
+			t.list(3, tBinary, 1)
+			t.str(c.name)
+			t.i32(4, codecUncompressed)
+			t.i64(5, g.rows)
+			t.i64(6, ch.size)
+			t.i64(7, ch.size)
+			t.i64(9, ch.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, g.size)
+		t.i64(3, g.rows)
+		t.end()
+	}
+
+	t.binary(6, "ibconnect")
+	t.end()
+
+	var footer [4]byte
+	binary.LittleEndian.PutUint32(footer[:], uint32(t.Len()))
+	if err := w.write(t.Bytes()); err != nil {
+		return err
+	}
+	if err := w.write(footer[:]); err != nil {
+		return err
+	}
+	return w.write([]byte(magic))
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	return err
+}
@@ -0,0 +1,195 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// thriftReader decodes Thrift compact protocol structs into maps keyed by
+// field ID, which is sufficient to verify the metadata written.
+type thriftReader struct {
+	b *bytes.Reader
+}
+
+func (t *thriftReader) varint() uint64 {
+	v, err := binary.ReadUvarint(t.b)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (t *thriftReader) zigzag() int64 {
+	v := t.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (t *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case tI32, tI64:
+		return t.zigzag()
+	case tBinary:
+		b := make([]byte, t.varint())
+		t.b.Read(b)
+		return string(b)
+	case tList:
+		h, _ := t.b.ReadByte()
+		size := int(h >> 4)
+		if size == 15 {
+			size = int(t.varint())
+		}
+		list := []interface{}{}
+		for i := 0; i < size; i++ {
+			list = append(list, t.value(h&0x0f))
+		}
+		return list
+	case tStruct:
+		return t.structure()
+	}
+	panic("unsupported type")
+}
+
+func (t *thriftReader) structure() map[int16]interface{} {
+	s := make(map[int16]interface{})
+	var last int16
+	for {
+		h, _ := t.b.ReadByte()
+		if h == 0 {
+			return s
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(t.zigzag())
+		}
+		s[id] = t.value(h & 0x0f)
+		last = id
+	}
+}
+
+type record struct {
+	Id       int64
+	Name     string
+	Price    float64
+	Base     bool
+	Created  time.Time
+	Small    int16
+	internal string
+}
+
+func TestWriter(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewWriter(&b, &record{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.RowGroupSize = 2
+
+	created := time.Date(2015, 1, 2, 3, 4, 5, 6000, time.UTC)
+	records := []record{
+		{1, "BHP", 32.5, true, created, -3, "x"},
+		{2, "", -1, false, created, 4, "y"},
+		{3, "CBA", 0, true, created, 5, "z"},
+	}
+	for _, r := range records {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Write(struct{ Id int64 }{1}); err == nil {
+		t.Fatal("expected error for record of another type")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file := b.Bytes()
+	if string(file[:4]) != magic || string(file[len(file)-4:]) != magic {
+		t.Fatal("magic missing")
+	}
+	length := binary.LittleEndian.Uint32(file[len(file)-8:])
+	footer := file[len(file)-8-int(length) : len(file)-8]
+	meta := (&thriftReader{bytes.NewReader(footer)}).structure()
+
+	if meta[3] != int64(3) {
+		t.Fatalf("expected 3 rows but got %v", meta[3])
+	}
+
+	schema := meta[2].([]interface{})
+	var names []interface{}
+	for _, e := range schema[1:] {
+		names = append(names, e.(map[int16]interface{})[4])
+	}
+	expected := []interface{}{"Id", "Name", "Price", "Base", "Created", "Small"}
+	if !reflect.DeepEqual(names, expected) || schema[0].(map[int16]interface{})[5] != int64(6) {
+		t.Fatalf("unexpected schema %v", schema)
+	}
+	if converted := schema[5].(map[int16]interface{})[6]; converted != int64(convertedTimeMicros) {
+		t.Fatalf("Created converted type %v", converted)
+	}
+
+	groups := meta[4].([]interface{})
+	if len(groups) != 2 || groups[0].(map[int16]interface{})[3] != int64(2) || groups[1].(map[int16]interface{})[3] != int64(1) {
+		t.Fatalf("unexpected row groups %v", groups)
+	}
+
+	// read each column of the first row group
+	values := make(map[string][]byte)
+	for _, c := range groups[0].(map[int16]interface{})[1].([]interface{}) {
+		md := c.(map[int16]interface{})[3].(map[int16]interface{})
+		offset := md[9].(int64)
+		r := &thriftReader{bytes.NewReader(file[offset:])}
+		header := r.structure()
+		data := make([]byte, header[3].(int64))
+		r.b.Read(data)
+		if header[5].(map[int16]interface{})[1] != int64(2) {
+			t.Fatalf("unexpected page header %v", header)
+		}
+		values[md[3].([]interface{})[0].(string)] = data
+	}
+
+	if id := binary.LittleEndian.Uint64(values["Id"][8:]); id != 2 {
+		t.Fatalf("expected Id 2 but got %d", id)
+	}
+	if !bytes.Equal(values["Name"], []byte{3, 0, 0, 0, 'B', 'H', 'P', 0, 0, 0, 0}) {
+		t.Fatalf("unexpected Name %v", values["Name"])
+	}
+	if price := math.Float64frombits(binary.LittleEndian.Uint64(values["Price"])); price != 32.5 {
+		t.Fatalf("expected Price 32.5 but got %v", price)
+	}
+	if !bytes.Equal(values["Base"], []byte{1}) {
+		t.Fatalf("unexpected Base %v", values["Base"])
+	}
+	if micros := int64(binary.LittleEndian.Uint64(values["Created"])); micros != created.UnixNano()/1e3 {
+		t.Fatalf("unexpected Created %d", micros)
+	}
+	if small := int64(binary.LittleEndian.Uint64(values["Small"])); small != -3 {
+		t.Fatalf("expected Small -3 but got %d", small)
+	}
+}
+
+func TestWriterEmpty(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewWriter(&b, record{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() < 12 || string(b.Bytes()[b.Len()-4:]) != magic {
+		t.Fatal("empty file invalid")
+	}
+}
+
+func TestNewWriterUnsupported(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, struct{ Tags []string }{}); err == nil {
+		t.Fatal("expected error for slice field")
+	}
+	if _, err := NewWriter(&bytes.Buffer{}, 1); err == nil {
+		t.Fatal("expected error for non-struct")
+	}
+}
@@ -0,0 +1,11 @@
+/*
+Package partition maintains the monthly partitions of account_amount and
+account_position.
+
+Both tables are partitioned by the month their snapshot was created. The
+cluster leader creates the partitions for the current and next month hourly,
+so a partition is ready before the account feed stores its first snapshot of
+a month. Rows stored for a month without a partition are held in the table's
+default partition, and moved into the month's partition when it is created.
+*/
+package partition
@@ -0,0 +1,44 @@
+package partition
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+const lockManagerKey int64 = 6120937465019283746
+
+// createInterval is how often the leader creates partitions.
+const createInterval = time.Hour
+
+// Maintainer creates upcoming partitions, if this node is the cluster leader
+// for partitioning. Creating a partition may wait on locks held by the account
+// feed, which the Leader's separate worker goroutine accommodates.
+type Maintainer struct {
+	leader *core.Leader
+	db     *sql.DB
+}
+
+func NewMaintainer(db *sql.DB, distLock core.DistLock) (*Maintainer, error) {
+	m := &Maintainer{db: db}
+	m.leader = core.NewLeader(distLock, lockManagerKey, createInterval, nil, m.process)
+	return m, nil // never returns error, but declared for consistency
+}
+
+// Close terminates the Maintainer. Close can be called multiple times safely,
+// and it will block until the Maintainer has been closed.
+func (m *Maintainer) Close() {
+	m.leader.Close()
+}
+
+func (m *Maintainer) process() {
+	created, err := Create(m.db, time.Now())
+	if err != nil {
+		log.Printf("partition: creation failed: %v", err)
+	}
+	if created > 0 {
+		log.Printf("partition: created %d partitions", created)
+	}
+}
@@ -0,0 +1,35 @@
+package partition
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ahead is how many months after the current one have partitions created.
+const ahead = 1
+
+// months returns the start of each UTC month needing partitions at now.
+func months(now time.Time) []time.Time {
+	now = now.UTC()
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var ms []time.Time
+	for i := 0; i <= ahead; i++ {
+		ms = append(ms, first.AddDate(0, i, 0))
+	}
+	return ms
+}
+
+// Create creates any missing partitions for the current and next month at now,
+// returning how many were created.
+func Create(db *sql.DB, now time.Time) (int, error) {
+	created := 0
+	for _, m := range months(now) {
+		var n int
+		err := db.QueryRow("SELECT create_snapshot_partitions($1)", m).Scan(&n)
+		if err != nil {
+			return created, err
+		}
+		created += n
+	}
+	return created, nil
+}
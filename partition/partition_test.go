@@ -0,0 +1,91 @@
+package partition
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+func TestMonths(t *testing.T) {
+	ms := months(time.Date(2015, 12, 31, 23, 0, 0, 0, time.FixedZone("AEDT", 11*60*60)))
+	expected := []time.Time{time.Date(2015, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if len(ms) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, ms)
+	}
+	for i := range ms {
+		if !ms[i].Equal(expected[i]) {
+			t.Fatalf("expected %v but got %v", expected, ms)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	c := core.NewTestConfig(t)
+	db, err := core.InitMeddler(c.DbUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	account, err := core.GetAccount(db, "PARTITIONTEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountType, err := core.GetAccountType(db, "INDIVIDUAL")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a month long before real snapshots, whose row lands in the default
+	// partition unless an earlier run of this test created the partition
+	created := time.Date(1999, 5, 10, 0, 0, 0, 0, time.UTC)
+	s := &core.AccountSnapshot{AccountId: account.Id, Created: created}
+	if err := meddler.Insert(db, "account_snapshot", s); err != nil {
+		t.Fatal(err)
+	}
+	amt := &core.AccountAmount{AccountSnapshotId: s.Id, SnapshotCreated: created, Iso4217Code: 36, AccountType: accountType.Id}
+	if err := meddler.Insert(db, "account_amount", amt); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Exec("DELETE FROM account_amount WHERE account_snapshot_id = $1", s.Id)
+		db.Exec("DELETE FROM account_snapshot WHERE id = $1", s.Id)
+	}()
+
+	if _, err := Create(db, created); err != nil {
+		t.Fatal(err)
+	}
+	n, err := Create(db, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected existing partitions to be left alone but %d were created", n)
+	}
+
+	for _, table := range []string{"account_amount_1999_05", "account_amount_1999_06", "account_position_1999_05", "account_position_1999_06"} {
+		var exists bool
+		if err := db.QueryRow("SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Fatalf("expected partition %s to exist", table)
+		}
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM account_amount_1999_05 WHERE id = $1", amt.Id).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatal("expected the amount to be held by its month's partition")
+	}
+	if err := db.QueryRow("SELECT count(*) FROM account_amount_default WHERE id = $1", amt.Id).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatal("expected the amount to be moved out of the default partition")
+	}
+}
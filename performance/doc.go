@@ -0,0 +1,20 @@
+/*
+Package performance calculates investment returns from account history.
+
+Returns are calculated from NetLiquidation snapshots, adjusted for the external
+deposits and withdrawals recorded in the cash_flow table. Two measures are
+offered:
+
+Time-weighted return (TWR) chain-links the return of each interval between
+snapshots, removing the effect of the timing and size of cash flows. It is the
+usual measure of how well the investments themselves performed.
+
+Money-weighted return (MWR) is the internal rate of return (IRR) of the cash
+flows, including the opening and closing values. It reflects the investor's
+actual experience, including the effect of when money was added or removed.
+
+The package separates the pure calculations (which operate on a Series) from
+loading a Series from the database, so the calculations can be used and tested
+without a database.
+*/
+package performance
@@ -0,0 +1,319 @@
+package performance
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// year is the duration used to annualise returns.
+const year = 365.25 * 24 * time.Hour
+
+// Valuation is the value of a portfolio at a point in time.
+type Valuation struct {
+	Time  time.Time
+	Value float64
+}
+
+// Flow is an external cash flow into (positive) or out of (negative) a portfolio.
+type Flow struct {
+	Time   time.Time
+	Amount float64
+}
+
+// Series holds the valuations and flows of a portfolio in a single currency.
+// Valuations and Flows must be sorted by time. A Flow is assumed to be
+// included in the first Valuation at or after the time it occurred.
+type Series struct {
+	Currency   string
+	Valuations []Valuation
+	Flows      []Flow
+}
+
+// Period reports the returns of a Series between two times.
+type Period struct {
+	Start      time.Time
+	End        time.Time
+	StartValue float64
+	EndValue   float64
+	NetFlows   float64
+	TWR        float64
+	MWR        float64
+}
+
+// Report presents the returns for a Series, including calendar month and year
+// breakdowns. TWR and MWR are cumulative over the period, whereas the
+// annualised variants are compound annual rates.
+type Report struct {
+	Currency      string
+	Total         Period
+	AnnualisedTWR float64
+	AnnualisedMWR float64
+	Monthly       []Period
+	Annual        []Period
+}
+
+// ErrInsufficientData indicates fewer than two valuations were available.
+var ErrInsufficientData = errors.New("performance: at least two valuations are required")
+
+// ErrUnknownCurrency indicates a requested currency is not an ISO 4217 code.
+var ErrUnknownCurrency = errors.New("performance: unknown currency")
+
+// NewReport calculates the returns of the Series over its full history, along
+// with monthly and annual breakdowns.
+func NewReport(s Series) (Report, error) {
+	r := Report{Currency: s.Currency}
+
+	var err error
+	r.Total, err = s.Period()
+	if err != nil {
+		return r, err
+	}
+
+	years := r.Total.End.Sub(r.Total.Start).Hours() / year.Hours()
+	if years > 0 {
+		r.AnnualisedTWR = math.Pow(1+r.Total.TWR, 1/years) - 1
+		r.AnnualisedMWR = math.Pow(1+r.Total.MWR, 1/years) - 1
+	}
+
+	r.Monthly = s.Breakdown(func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+		func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC) })
+	r.Annual = s.Breakdown(func(t time.Time) time.Time { return t.AddDate(1, 0, 0) },
+		func(t time.Time) time.Time { return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC) })
+	return r, nil
+}
+
+// Period calculates the returns over the full Series.
+func (s Series) Period() (Period, error) {
+	p := Period{}
+	if len(s.Valuations) < 2 {
+		return p, ErrInsufficientData
+	}
+
+	first := s.Valuations[0]
+	last := s.Valuations[len(s.Valuations)-1]
+	p.Start = first.Time
+	p.End = last.Time
+	p.StartValue = first.Value
+	p.EndValue = last.Value
+
+	for _, f := range s.flowsBetween(first.Time, last.Time) {
+		p.NetFlows += f.Amount
+	}
+
+	p.TWR = s.TWR()
+
+	irr, err := s.IRR()
+	if err != nil {
+		return p, err
+	}
+	years := p.End.Sub(p.Start).Hours() / year.Hours()
+	p.MWR = math.Pow(1+irr, years) - 1
+	return p, nil
+}
+
+// TWR returns the cumulative time-weighted return. Intervals that start with a
+// zero value (eg before an account was funded) are ignored.
+func (s Series) TWR() float64 {
+	growth := 1.0
+	for i := 1; i < len(s.Valuations); i++ {
+		prev := s.Valuations[i-1]
+		cur := s.Valuations[i]
+		if prev.Value == 0 {
+			continue
+		}
+
+		flows := 0.0
+		for _, f := range s.flowsBetween(prev.Time, cur.Time) {
+			flows += f.Amount
+		}
+		growth *= (cur.Value - flows) / prev.Value
+	}
+	return growth - 1
+}
+
+// IRR returns the annualised internal rate of return, being the money-weighted
+// return. The opening value is treated as an investment and the closing value
+// as a withdrawal.
+func (s Series) IRR() (float64, error) {
+	if len(s.Valuations) < 2 {
+		return 0, ErrInsufficientData
+	}
+
+	first := s.Valuations[0]
+	last := s.Valuations[len(s.Valuations)-1]
+
+	type cashFlow struct {
+		years  float64
+		amount float64
+	}
+	cfs := []cashFlow{{0, -first.Value}}
+	for _, f := range s.flowsBetween(first.Time, last.Time) {
+		cfs = append(cfs, cashFlow{f.Time.Sub(first.Time).Hours() / year.Hours(), -f.Amount})
+	}
+	cfs = append(cfs, cashFlow{last.Time.Sub(first.Time).Hours() / year.Hours(), last.Value})
+
+	npv := func(rate float64) float64 {
+		total := 0.0
+		for _, cf := range cfs {
+			total += cf.amount / math.Pow(1+rate, cf.years)
+		}
+		return total
+	}
+
+	// bisection is slower than Newton's method but cannot diverge
+	low, high := -0.999999, 1.0
+	for npv(high) > 0 {
+		high *= 2
+		if high > 1e9 {
+			return 0, fmt.Errorf("performance: IRR did not converge")
+		}
+	}
+	if npv(low) < 0 {
+		return 0, fmt.Errorf("performance: IRR did not converge")
+	}
+
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		if npv(mid) > 0 {
+			low = mid
+		} else {
+			high = mid
+		}
+		if high-low < 1e-12 {
+			break
+		}
+	}
+	return (low + high) / 2, nil
+}
+
+// Breakdown splits the Series into calendar periods and calculates the returns
+// of each. The truncate function must return the start of the period containing
+// the passed time, and next the start of the following period. Each period
+// starts from the last valuation at or before its start (or its first
+// valuation, if none) and ends at the last valuation at or before its end, so
+// consecutive periods chain-link. Periods with fewer than two valuations are
+// omitted.
+func (s Series) Breakdown(next func(time.Time) time.Time, truncate func(time.Time) time.Time) []Period {
+	periods := []Period{}
+	if len(s.Valuations) < 2 {
+		return periods
+	}
+
+	last := s.Valuations[len(s.Valuations)-1].Time
+	for start := truncate(s.Valuations[0].Time.UTC()); !start.After(last); start = next(start) {
+		sub := s.Between(start, next(start))
+		p, err := sub.Period()
+		if err != nil {
+			continue
+		}
+		periods = append(periods, p)
+	}
+	return periods
+}
+
+// Between returns the Series from the last valuation at or before start (or
+// the first valuation after start, if none) to the last valuation at or before
+// end.
+func (s Series) Between(start time.Time, end time.Time) Series {
+	sub := Series{Currency: s.Currency}
+
+	opening := -1
+	for i, v := range s.Valuations {
+		if !v.Time.After(start) {
+			opening = i
+		}
+	}
+
+	for i, v := range s.Valuations {
+		if v.Time.After(end) {
+			break
+		}
+		if i == opening || v.Time.After(start) {
+			sub.Valuations = append(sub.Valuations, v)
+		}
+	}
+
+	if len(sub.Valuations) > 0 {
+		from := sub.Valuations[0].Time
+		to := sub.Valuations[len(sub.Valuations)-1].Time
+		sub.Flows = s.flowsBetween(from, to)
+	}
+	return sub
+}
+
+// flowsBetween returns the flows after from, up to and including to.
+func (s Series) flowsBetween(from time.Time, to time.Time) []Flow {
+	flows := []Flow{}
+	for _, f := range s.Flows {
+		if f.Time.After(from) && !f.Time.After(to) {
+			flows = append(flows, f)
+		}
+	}
+	return flows
+}
+
+// Aggregate combines several Series into one, as if they were a single
+// portfolio. Valuations are summed at every time any Series has a valuation,
+// carrying forward each Series' latest value. The first valuation of a Series
+// that starts after the others is treated as a flow into the aggregate, so an
+// account being added does not appear as a return. All Series must share the
+// same currency.
+func Aggregate(series []Series) (Series, error) {
+	agg := Series{}
+	if len(series) == 0 {
+		return agg, nil
+	}
+	agg.Currency = series[0].Currency
+
+	times := make(map[time.Time]bool)
+	for _, s := range series {
+		if s.Currency != agg.Currency {
+			return agg, fmt.Errorf("performance: cannot aggregate %s and %s series", agg.Currency, s.Currency)
+		}
+		for _, v := range s.Valuations {
+			times[v.Time] = true
+		}
+		agg.Flows = append(agg.Flows, s.Flows...)
+	}
+
+	sorted := []time.Time{}
+	for t := range times {
+		sorted = append(sorted, t)
+	}
+	sort.Sort(byTime(sorted))
+
+	positions := make([]int, len(series))
+	for i, t := range sorted {
+		total := 0.0
+		for j, s := range series {
+			for positions[j] < len(s.Valuations) && !s.Valuations[positions[j]].Time.After(t) {
+				if positions[j] == 0 && i > 0 {
+					agg.Flows = append(agg.Flows, Flow{s.Valuations[0].Time, s.Valuations[0].Value})
+				}
+				positions[j]++
+			}
+			if positions[j] > 0 {
+				total += s.Valuations[positions[j]-1].Value
+			}
+		}
+		agg.Valuations = append(agg.Valuations, Valuation{t, total})
+	}
+
+	sort.Sort(byFlowTime(agg.Flows))
+	return agg, nil
+}
+
+type byTime []time.Time
+
+func (t byTime) Len() int           { return len(t) }
+func (t byTime) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t byTime) Less(i, j int) bool { return t[i].Before(t[j]) }
+
+type byFlowTime []Flow
+
+func (f byFlowTime) Len() int           { return len(f) }
+func (f byFlowTime) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f byFlowTime) Less(i, j int) bool { return f[i].Time.Before(f[j].Time) }
@@ -0,0 +1,135 @@
+package performance
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func expectClose(t *testing.T, what string, actual float64, expected float64) {
+	if math.Abs(actual-expected) > 1e-6 {
+		t.Fatalf("%s was %f (expected %f)", what, actual, expected)
+	}
+}
+
+func TestTWRWithoutFlows(t *testing.T) {
+	s := Series{Valuations: []Valuation{
+		{day(2014, 1, 1), 100},
+		{day(2014, 2, 1), 110},
+		{day(2014, 3, 1), 121},
+	}}
+	expectClose(t, "TWR", s.TWR(), 0.21)
+}
+
+func TestTWRIgnoresDeposits(t *testing.T) {
+	s := Series{
+		Valuations: []Valuation{
+			{day(2014, 1, 1), 100},
+			{day(2014, 2, 1), 210},
+			{day(2014, 3, 1), 231},
+		},
+		Flows: []Flow{{day(2014, 1, 15), 100}},
+	}
+	expectClose(t, "TWR", s.TWR(), 1.1*1.1-1)
+}
+
+func TestIRROneYear(t *testing.T) {
+	s := Series{Valuations: []Valuation{
+		{day(2013, 1, 1), 100},
+		{day(2013, 1, 1).Add(year), 110},
+	}}
+	irr, err := s.IRR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectClose(t, "IRR", irr, 0.10)
+}
+
+func TestMWRWeightsLateDeposit(t *testing.T) {
+	// the market falls 50% then doubles, with a large deposit at the bottom
+	s := Series{
+		Valuations: []Valuation{
+			{day(2014, 1, 1), 100},
+			{day(2014, 4, 1), 1050},
+			{day(2014, 7, 1), 2100},
+		},
+		Flows: []Flow{{day(2014, 4, 1), 1000}},
+	}
+	p, err := s.Period()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectClose(t, "TWR", p.TWR, 0)
+	if p.MWR <= 0.5 {
+		t.Fatalf("MWR %f should reward the well-timed deposit", p.MWR)
+	}
+	expectClose(t, "NetFlows", p.NetFlows, 1000)
+}
+
+func TestInsufficientData(t *testing.T) {
+	s := Series{Valuations: []Valuation{{day(2014, 1, 1), 100}}}
+	if _, err := NewReport(s); err != ErrInsufficientData {
+		t.Fatalf("expected ErrInsufficientData (was %v)", err)
+	}
+}
+
+func TestReportBreakdowns(t *testing.T) {
+	s := Series{}
+	value := 100.0
+	for d := day(2013, 11, 1); d.Before(day(2014, 3, 1)); d = d.AddDate(0, 0, 7) {
+		s.Valuations = append(s.Valuations, Valuation{d, value})
+		value *= 1.01
+	}
+
+	r, err := NewReport(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Monthly) != 4 {
+		t.Fatalf("expected 4 months (was %d)", len(r.Monthly))
+	}
+	if len(r.Annual) != 2 {
+		t.Fatalf("expected 2 years (was %d)", len(r.Annual))
+	}
+
+	// chain-linked periods must reproduce the total
+	growth := 1.0
+	for _, p := range r.Monthly {
+		growth *= 1 + p.TWR
+	}
+	expectClose(t, "monthly linked TWR", growth-1, r.Total.TWR)
+
+	if !r.Monthly[1].Start.Equal(r.Monthly[0].End) {
+		t.Fatal("months should start at the prior month's last valuation")
+	}
+}
+
+func TestAggregateTreatsNewAccountAsFlow(t *testing.T) {
+	a := Series{Currency: "USD", Valuations: []Valuation{
+		{day(2014, 1, 1), 100},
+		{day(2014, 2, 1), 110},
+		{day(2014, 3, 1), 121},
+	}}
+	b := Series{Currency: "USD", Valuations: []Valuation{
+		{day(2014, 2, 1), 50},
+		{day(2014, 3, 1), 55},
+	}}
+
+	agg, err := Aggregate([]Series{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(agg.Valuations) != 3 || agg.Valuations[2].Value != 176 {
+		t.Fatalf("unexpected valuations %v", agg.Valuations)
+	}
+	expectClose(t, "aggregate TWR", agg.TWR(), 0.21)
+
+	b.Currency = "EUR"
+	if _, err := Aggregate([]Series{a, b}); err == nil {
+		t.Fatal("series in different currencies should not aggregate")
+	}
+}
@@ -0,0 +1,185 @@
+package performance
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Frequency is the interval at which a Series is sampled for risk statistics.
+type Frequency string
+
+const (
+	Daily   Frequency = "daily"
+	Weekly  Frequency = "weekly"
+	Monthly Frequency = "monthly"
+)
+
+// NewFrequency returns the Frequency with the passed name.
+func NewFrequency(name string) (Frequency, error) {
+	switch f := Frequency(name); f {
+	case Daily, Weekly, Monthly:
+		return f, nil
+	}
+	return "", fmt.Errorf("performance: unknown frequency '%s'", name)
+}
+
+// PerYear returns the number of periods in a year. Snapshots are taken on
+// every calendar day, so daily sampling uses calendar rather than trading days.
+func (f Frequency) PerYear() float64 {
+	switch f {
+	case Daily:
+		return year.Hours() / 24
+	case Weekly:
+		return year.Hours() / (24 * 7)
+	}
+	return 12
+}
+
+// truncate returns the start of the period containing t. Weeks start on Monday.
+func (f Frequency) truncate(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch f {
+	case Daily:
+		return day
+	case Weekly:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// next returns the start of the period after the one starting at t.
+func (f Frequency) next(t time.Time) time.Time {
+	switch f {
+	case Daily:
+		return t.AddDate(0, 0, 1)
+	case Weekly:
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 1, 0)
+}
+
+// Drawdown is a decline in the time-weighted value of a Series from a peak.
+// Recovery is nil if the value has not since regained the peak.
+type Drawdown struct {
+	Depth    float64
+	Peak     time.Time
+	Trough   time.Time
+	Recovery *time.Time
+}
+
+// Risk presents risk statistics for a Series. Volatility, Sharpe and Sortino
+// are annualised from the period returns at the sampled Frequency. RiskFreeRate
+// is an annual rate.
+type Risk struct {
+	Currency     string
+	Frequency    Frequency
+	RiskFreeRate float64
+	Periods      int
+	MaxDrawdown  Drawdown
+	Volatility   float64
+	Sharpe       float64
+	Sortino      float64
+	Best         Period
+	Worst        Period
+}
+
+// NewRisk calculates the risk statistics of the Series. At least two sampled
+// periods are required.
+func NewRisk(s Series, f Frequency, riskFreeRate float64) (Risk, error) {
+	r := Risk{Currency: s.Currency, Frequency: f, RiskFreeRate: riskFreeRate}
+
+	periods := s.Breakdown(f.next, f.truncate)
+	r.Periods = len(periods)
+	if len(periods) < 2 {
+		return r, ErrInsufficientData
+	}
+
+	r.MaxDrawdown = s.MaxDrawdown()
+
+	r.Best = periods[0]
+	r.Worst = periods[0]
+	mean := 0.0
+	for _, p := range periods {
+		mean += p.TWR
+		if p.TWR > r.Best.TWR {
+			r.Best = p
+		}
+		if p.TWR < r.Worst.TWR {
+			r.Worst = p
+		}
+	}
+	mean /= float64(len(periods))
+
+	// the risk-free rate is compounded down to the sampling frequency
+	rf := math.Pow(1+riskFreeRate, 1/f.PerYear()) - 1
+
+	variance := 0.0
+	downside := 0.0
+	for _, p := range periods {
+		variance += (p.TWR - mean) * (p.TWR - mean)
+		if p.TWR < rf {
+			downside += (p.TWR - rf) * (p.TWR - rf)
+		}
+	}
+	stdev := math.Sqrt(variance / float64(len(periods)-1))
+	downsideDev := math.Sqrt(downside / float64(len(periods)))
+
+	annualise := math.Sqrt(f.PerYear())
+	r.Volatility = stdev * annualise
+	if stdev > 0 {
+		r.Sharpe = (mean - rf) / stdev * annualise
+	}
+	if downsideDev > 0 {
+		r.Sortino = (mean - rf) / downsideDev * annualise
+	}
+	return r, nil
+}
+
+// MaxDrawdown returns the largest peak to trough decline of the Series. It is
+// measured on the time-weighted value, so withdrawals are not drawdowns.
+func (s Series) MaxDrawdown() Drawdown {
+	max := Drawdown{}
+	if len(s.Valuations) == 0 {
+		return max
+	}
+
+	index := 1.0
+	peak := index
+	peakTime := s.Valuations[0].Time
+	current := Drawdown{}
+	for i := 1; i < len(s.Valuations); i++ {
+		prev := s.Valuations[i-1]
+		cur := s.Valuations[i]
+		if prev.Value != 0 {
+			flows := 0.0
+			for _, f := range s.flowsBetween(prev.Time, cur.Time) {
+				flows += f.Amount
+			}
+			index *= (cur.Value - flows) / prev.Value
+		}
+
+		if index >= peak {
+			if current.Depth > 0 && current.Recovery == nil {
+				recovery := cur.Time
+				current.Recovery = &recovery
+				if current.Depth == max.Depth {
+					max = current
+				}
+			}
+			peak = index
+			peakTime = cur.Time
+			current = Drawdown{}
+			continue
+		}
+
+		depth := 1 - index/peak
+		if depth > current.Depth {
+			current = Drawdown{Depth: depth, Peak: peakTime, Trough: cur.Time}
+		}
+		if depth > max.Depth {
+			max = current
+		}
+	}
+	return max
+}
@@ -0,0 +1,90 @@
+package performance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxDrawdown(t *testing.T) {
+	s := Series{Valuations: []Valuation{
+		{day(2014, 1, 1), 100},
+		{day(2014, 1, 2), 120},
+		{day(2014, 1, 3), 90},
+		{day(2014, 1, 4), 60},
+		{day(2014, 1, 5), 130},
+		{day(2014, 1, 6), 110},
+	}}
+	d := s.MaxDrawdown()
+	expectClose(t, "depth", d.Depth, 0.5)
+	if !d.Peak.Equal(day(2014, 1, 2)) || !d.Trough.Equal(day(2014, 1, 4)) {
+		t.Fatalf("unexpected peak %v or trough %v", d.Peak, d.Trough)
+	}
+	if d.Recovery == nil || !d.Recovery.Equal(day(2014, 1, 5)) {
+		t.Fatalf("unexpected recovery %v", d.Recovery)
+	}
+}
+
+func TestMaxDrawdownIgnoresWithdrawals(t *testing.T) {
+	s := Series{
+		Valuations: []Valuation{
+			{day(2014, 1, 1), 100},
+			{day(2014, 1, 2), 50},
+		},
+		Flows: []Flow{{day(2014, 1, 2), -50}},
+	}
+	if d := s.MaxDrawdown(); d.Depth != 0 {
+		t.Fatalf("withdrawal reported as drawdown of %f", d.Depth)
+	}
+}
+
+func TestWeeklyTruncate(t *testing.T) {
+	// 2014-01-01 was a Wednesday
+	if w := Weekly.truncate(day(2014, 1, 1).Add(time.Hour)); !w.Equal(day(2013, 12, 30)) {
+		t.Fatalf("unexpected week start %v", w)
+	}
+	if w := Weekly.truncate(day(2013, 12, 30)); !w.Equal(day(2013, 12, 30)) {
+		t.Fatalf("Monday should start its own week (was %v)", w)
+	}
+}
+
+func TestNewRisk(t *testing.T) {
+	s := Series{Currency: "USD"}
+	value := 100.0
+	returns := []float64{0.02, -0.01, 0.03, -0.02}
+	for i := 0; i <= len(returns); i++ {
+		s.Valuations = append(s.Valuations, Valuation{day(2014, time.Month(i+1), 1), value})
+		if i < len(returns) {
+			value *= 1 + returns[i]
+		}
+	}
+
+	r, err := NewRisk(s, Monthly, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Periods != 4 {
+		t.Fatalf("expected 4 periods (was %d)", r.Periods)
+	}
+	expectClose(t, "best", r.Best.TWR, 0.03)
+	expectClose(t, "worst", r.Worst.TWR, -0.02)
+
+	// sample standard deviation of the returns is 0.0238048
+	expectClose(t, "volatility", r.Volatility, 0.0238047614*3.4641016151)
+	expectClose(t, "sharpe", r.Sharpe, 0.005/0.0238047614*3.4641016151)
+	if r.Sortino <= r.Sharpe {
+		t.Fatalf("sortino %f should exceed sharpe %f", r.Sortino, r.Sharpe)
+	}
+
+	if _, err := NewRisk(Series{Valuations: s.Valuations[:2]}, Monthly, 0); err != ErrInsufficientData {
+		t.Fatalf("expected ErrInsufficientData (was %v)", err)
+	}
+}
+
+func TestNewFrequency(t *testing.T) {
+	if f, err := NewFrequency("weekly"); err != nil || f != Weekly {
+		t.Fatalf("unexpected %v %v", f, err)
+	}
+	if _, err := NewFrequency("hourly"); err == nil {
+		t.Fatal("hourly should be rejected")
+	}
+}
@@ -0,0 +1,138 @@
+package performance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+// netLiquidation is the NetLiquidation reported with an account snapshot,
+// which remained current until ValidUntil.
+type netLiquidation struct {
+	AccountSnapshotId int64         `meddler:"account_snapshot_id,pk"`
+	Created           time.Time     `meddler:"created,utctime"`
+	ValidUntil        time.Time     `meddler:"valid_until,utctime"`
+	NetLiquidation    core.Monetary `meddler:"net_liquidation,monetary"`
+}
+
+// snapshotCurrent restricts account_snapshot to snapshots current at some time
+// between $2 and $3.
+const snapshotCurrent = "created <= $3 AND COALESCE(valid_until, created) >= $2"
+
+// LoadSeries loads the NetLiquidation history and cash flows of an account
+// between the passed times (inclusive). Values are expressed in the passed
+// currency, or in the currency of the first NetLiquidation if currency is
+// empty. Conversions use the exchange rates captured with each snapshot.
+func LoadSeries(db meddler.DB, accountId int64, from time.Time, to time.Time, currency string) (Series, error) {
+	s := Series{}
+
+	currencies, err := core.NewCurrencies(db)
+	if err != nil {
+		return s, err
+	}
+
+	var all []*netLiquidation
+	err = meddler.QueryAll(db, &all, "SELECT account_snapshot_id, created, "+
+		"COALESCE(valid_until, created) AS valid_until, net_liquidation "+
+		"FROM account_amount, account_snapshot WHERE account_snapshot.id = account_snapshot_id AND "+
+		"account_id = $1 AND "+snapshotCurrent+" AND NOT base ORDER BY created", accountId, from, to)
+	if err != nil {
+		return s, err
+	}
+
+	// amounts IB did not report NetLiquidation in are NIL, which is filtered
+	// here as monetary fields can only be read in SQL on Postgres
+	var nls []*netLiquidation
+	for _, nl := range all {
+		if nl.NetLiquidation.Iso4217Code != 0 {
+			nls = append(nls, nl)
+		}
+	}
+	if len(nls) == 0 {
+		return s, ErrInsufficientData
+	}
+
+	target := nls[0].NetLiquidation.Iso4217Code
+	if currency != "" {
+		iso, ok := currencies.Find(currency)
+		if !ok {
+			return s, ErrUnknownCurrency
+		}
+		target = iso.Iso4217Code
+	}
+	s.Currency = currencies[target].AlphabeticCode
+
+	var fxRates []*core.FxRate
+	err = meddler.QueryAll(db, &fxRates, "SELECT fx_rate.* FROM fx_rate, account_snapshot "+
+		"WHERE account_snapshot.id = account_snapshot_id AND account_id = $1 AND "+
+		snapshotCurrent, accountId, from, to)
+	if err != nil {
+		return s, err
+	}
+	rates := make(map[int64]core.FxRates)
+	for _, r := range fxRates {
+		if rates[r.AccountSnapshotId] == nil {
+			rates[r.AccountSnapshotId] = make(core.FxRates)
+		}
+		rates[r.AccountSnapshotId][r.Iso4217Code] = r.Rate
+	}
+
+	for _, nl := range nls {
+		converted, err := nl.NetLiquidation.Convert(currencies, rates[nl.AccountSnapshotId], target)
+		if err != nil {
+			return s, fmt.Errorf("performance: snapshot %d: %v", nl.AccountSnapshotId, err)
+		}
+		value, err := converted.Float(currencies)
+		if err != nil {
+			return s, err
+		}
+
+		// a snapshot found unchanged is also valued when last observed
+		start, end := nl.Created, nl.ValidUntil
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		s.Valuations = append(s.Valuations, Valuation{start, value})
+		if end.After(start) {
+			s.Valuations = append(s.Valuations, Valuation{end, value})
+		}
+	}
+
+	var flows []*core.CashFlow
+	err = meddler.QueryAll(db, &flows, "SELECT * FROM cash_flow WHERE account_id = $1 AND "+
+		"occurred > $2 AND occurred <= $3 ORDER BY occurred", accountId, s.Valuations[0].Time, to)
+	if err != nil {
+		return s, err
+	}
+
+	for _, f := range flows {
+		// convert using the rates of the snapshot that first includes the flow
+		var snapshotRates core.FxRates
+		for _, nl := range nls {
+			if !nl.Created.Before(f.Occurred) {
+				snapshotRates = rates[nl.AccountSnapshotId]
+				break
+			}
+		}
+		if snapshotRates == nil && f.Amount.Iso4217Code != target {
+			continue // after the last snapshot, so irrelevant
+		}
+
+		converted, err := f.Amount.Convert(currencies, snapshotRates, target)
+		if err != nil {
+			return s, fmt.Errorf("performance: cash flow %d: %v", f.Id, err)
+		}
+		amount, err := converted.Float(currencies)
+		if err != nil {
+			return s, err
+		}
+		s.Flows = append(s.Flows, Flow{f.Occurred, amount})
+	}
+
+	return s, nil
+}
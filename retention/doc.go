@@ -0,0 +1,19 @@
+/*
+Package retention thins old account snapshots according to the configured
+core.RetentionPolicy.
+
+Each tier of the policy keeps the latest snapshot of every account in each
+interval (aligned to the Unix epoch, so daily intervals are UTC days), deleting
+the others along with their amounts, rates, values and positions. The last
+snapshot of every account on each UTC day is always kept, so end-of-day
+history (and hence performance reporting) is unaffected by thinning, and tiers
+coarser than a day only matter once snapshots expire. Snapshots older than every
+tier expire and are deleted outright. A snapshot's age is from when it was last
+seen current, so an unchanged account's extended snapshot does not expire, and
+the latest snapshot of an account is never deleted. Snapshots referenced by
+alerts, or by the state of an alert rule, are never deleted either.
+
+Thinning runs hourly on the cluster leader, deleting snapshots in small
+transactions so the account feed is not blocked.
+*/
+package retention
@@ -0,0 +1,52 @@
+package retention
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+const lockManagerKey int64 = 4418304713859226951
+
+// thinInterval is how often the leader thins snapshots.
+const thinInterval = time.Hour
+
+// Pruner thins snapshots according to the retention policy, if this node is
+// the cluster leader for retention.
+type Pruner struct {
+	leader *core.Leader
+	db     *sql.DB
+	policy core.RetentionPolicy
+}
+
+func NewPruner(db *sql.DB, distLock core.DistLock, policy core.RetentionPolicy) (*Pruner, error) {
+	p := &Pruner{
+		db:     db,
+		policy: policy,
+	}
+	// without a policy there is nothing to thin, so the lock is never requested
+	if len(policy) > 0 {
+		p.leader = core.NewLeader(distLock, lockManagerKey, thinInterval, nil, p.process)
+	}
+	return p, nil // never returns error, but declared for consistency
+}
+
+// Close terminates the Pruner. Close can be called multiple times safely, and
+// it will block until the Pruner has been closed.
+func (p *Pruner) Close() {
+	if p.leader != nil {
+		p.leader.Close()
+	}
+}
+
+func (p *Pruner) process() {
+	deleted, err := Thin(p.db, p.policy, time.Now().UTC())
+	if err != nil {
+		log.Printf("retention: thinning failed: %v", err)
+	}
+	if deleted > 0 {
+		log.Printf("retention: deleted %d snapshots", deleted)
+	}
+}
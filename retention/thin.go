@@ -0,0 +1,137 @@
+package retention
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+// batchSize is the most snapshots deleted in each transaction.
+const batchSize = 1000
+
+// window is a range of times snapshots were last seen, to thin to one snapshot
+// per interval, or to delete entirely if expired. A zero from is unbounded.
+type window struct {
+	from     time.Time
+	to       time.Time
+	interval time.Duration
+	expired  bool
+}
+
+// windows returns the ranges the policy thins or expires as of now, omitting
+// those keeping every snapshot.
+func windows(p core.RetentionPolicy, now time.Time) []window {
+	var ws []window
+	if len(p) == 0 {
+		return ws
+	}
+
+	newer := now
+	for _, t := range p {
+		w := window{to: newer, interval: t.Interval}
+		if t.For != 0 {
+			w.from = now.Add(-t.For)
+		}
+		if t.Interval > 0 {
+			ws = append(ws, w)
+		}
+		if t.For == 0 {
+			return ws
+		}
+		newer = w.from
+	}
+	return append(ws, window{to: newer, expired: true})
+}
+
+// seen is when a snapshot was last found current, which is later than its
+// creation if the account feed has extended it.
+const seen = "COALESCE(valid_until, created)"
+
+// Snapshots referenced by an alert or an alert rule's state are kept, as
+// deleting them would cascade to the alert history and hysteresis. Thinning
+// keeps the latest snapshot of an account, being the last seen in its interval,
+// so expiry also keeps it.
+const (
+	thinned = "INSERT INTO retention_doomed SELECT id FROM (" +
+		"SELECT id, " +
+		"row_number() OVER (PARTITION BY account_id, floor(epoch(" + seen + ") / $3) ORDER BY " + seen + " DESC) AS interval_rank, " +
+		"row_number() OVER (PARTITION BY account_id, date_trunc('day', " + seen + ") ORDER BY " + seen + " DESC) AS day_rank " +
+		"FROM account_snapshot WHERE " + seen + " >= $1 AND " + seen + " < $2) ranked " +
+		"WHERE interval_rank > 1 AND day_rank > 1 AND " +
+		"NOT EXISTS (SELECT 1 FROM alert WHERE alert.account_snapshot_id = ranked.id) AND " +
+		"NOT EXISTS (SELECT 1 FROM alert_state WHERE alert_state.account_snapshot_id = ranked.id) LIMIT $4"
+	expired = "INSERT INTO retention_doomed SELECT id FROM account_snapshot " +
+		"WHERE " + seen + " >= $1 AND " + seen + " < $2 AND " +
+		"EXISTS (SELECT 1 FROM account_snapshot newer WHERE newer.account_id = account_snapshot.account_id " +
+		"AND newer.created > account_snapshot.created) AND " +
+		"NOT EXISTS (SELECT 1 FROM alert WHERE alert.account_snapshot_id = account_snapshot.id) AND " +
+		"NOT EXISTS (SELECT 1 FROM alert_state WHERE alert_state.account_snapshot_id = account_snapshot.id) LIMIT $3"
+)
+
+// Thin deletes the snapshots the policy no longer retains as of now,
+// returning the number deleted.
+func Thin(db *sql.DB, p core.RetentionPolicy, now time.Time) (int64, error) {
+	total := int64(0)
+	for _, w := range windows(p, now) {
+		query := thinned
+		args := []interface{}{w.from.UTC(), w.to.UTC(), w.interval.Seconds(), batchSize}
+		if w.expired {
+			query = expired
+			args = []interface{}{w.from.UTC(), w.to.UTC(), batchSize}
+		}
+
+		for {
+			deleted, err := deleteBatch(db, query, args...)
+			total += deleted
+			if err != nil {
+				return total, err
+			}
+			if deleted < batchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// deleteBatch deletes the snapshots the query selects into retention_doomed,
+// and their records, in a single transaction.
+func deleteBatch(db *sql.DB, query string, args ...interface{}) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// dropped before committing, as not every database supports ON COMMIT DROP
+	_, err = tx.Exec("CREATE TEMP TABLE retention_doomed (id BIGINT PRIMARY KEY)")
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	doomed, err := result.RowsAffected()
+	if err != nil || doomed == 0 {
+		return 0, err
+	}
+
+	for _, table := range []string{"account_position", "account_amount", "fx_rate", "account_value", "account_snapshot"} {
+		column := "account_snapshot_id"
+		if table == "account_snapshot" {
+			column = "id"
+		}
+		_, err = tx.Exec("DELETE FROM " + table + " WHERE " + column + " IN (SELECT id FROM retention_doomed)")
+		if err != nil {
+			return 0, err
+		}
+	}
+	_, err = tx.Exec("DROP TABLE retention_doomed")
+	if err != nil {
+		return 0, err
+	}
+	return doomed, tx.Commit()
+}
@@ -0,0 +1,230 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+func TestWindows(t *testing.T) {
+	now := time.Date(2015, 6, 30, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	if ws := windows(nil, now); len(ws) != 0 {
+		t.Fatalf("expected no windows but got %v", ws)
+	}
+
+	p := core.RetentionPolicy{{For: 30 * day}, {Interval: time.Hour, For: 365 * day}, {Interval: day}}
+	ws := windows(p, now)
+	if len(ws) != 2 {
+		t.Fatalf("expected 2 windows but got %v", ws)
+	}
+	if !ws[0].from.Equal(now.Add(-365*day)) || !ws[0].to.Equal(now.Add(-30*day)) || ws[0].interval != time.Hour || ws[0].expired {
+		t.Fatalf("unexpected hourly window %+v", ws[0])
+	}
+	if !ws[1].from.IsZero() || !ws[1].to.Equal(now.Add(-365*day)) || ws[1].interval != day || ws[1].expired {
+		t.Fatalf("unexpected daily window %+v", ws[1])
+	}
+
+	ws = windows(core.RetentionPolicy{{Interval: time.Hour, For: 7 * day}}, now)
+	if len(ws) != 2 || !ws[0].to.Equal(now) || !ws[1].expired || !ws[1].from.IsZero() || !ws[1].to.Equal(now.Add(-7*day)) {
+		t.Fatalf("unexpected windows %+v", ws)
+	}
+}
+
+func TestPrunerWithoutPolicy(t *testing.T) {
+	// without a policy the Pruner never requests the lock, so needs no database
+	p, err := NewPruner(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	p.Close()
+}
+
+func TestThin(t *testing.T) {
+	c := core.NewTestConfig(t)
+	db, err := core.InitMeddler(c.DbUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	account, err := core.GetAccount(db, "RETENTIONTEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a fixed "now" long before real snapshots confines thinning to these
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2001, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	created := []time.Time{
+		// expired
+		at(4, 12, 0),
+		// daily
+		at(6, 8, 0), at(6, 9, 0),
+		// hourly
+		at(8, 10, 5), at(8, 10, 20), at(8, 10, 40), at(8, 11, 10), at(8, 23, 0),
+		// all
+		at(9, 12, 0), at(9, 12, 10),
+	}
+	for _, when := range created {
+		s := &core.AccountSnapshot{AccountId: account.Id, Created: when}
+		if err := meddler.Insert(db, "account_snapshot", s); err != nil {
+			t.Fatal(err)
+		}
+		err = meddler.Insert(db, "fx_rate", &core.FxRate{AccountSnapshotId: s.Id, Iso4217Code: 36, Rate: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		db.Exec("DELETE FROM fx_rate WHERE account_snapshot_id IN (SELECT id FROM account_snapshot WHERE account_id = $1)", account.Id)
+		db.Exec("DELETE FROM account_snapshot WHERE account_id = $1", account.Id)
+	}()
+
+	p, err := core.NewRetentionPolicy("all:1d,1h:3d,1d:5d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := Thin(db, p, at(10, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 4 {
+		t.Fatalf("expected 4 snapshots deleted but %d were", deleted)
+	}
+
+	rows, err := db.Query("SELECT created FROM account_snapshot WHERE account_id = $1 ORDER BY created", account.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	expected := []time.Time{at(6, 9, 0), at(8, 10, 40), at(8, 11, 10), at(8, 23, 0), at(9, 12, 0), at(9, 12, 10)}
+	i := 0
+	for rows.Next() {
+		var when time.Time
+		if err := rows.Scan(&when); err != nil {
+			t.Fatal(err)
+		}
+		if i >= len(expected) || !when.Equal(expected[i]) {
+			t.Fatalf("snapshot %d created %v was not expected", i, when)
+		}
+		i++
+	}
+	if i != len(expected) {
+		t.Fatalf("expected %d snapshots to remain but %d did", len(expected), i)
+	}
+}
+
+func TestThinKeepsAlertStateSnapshots(t *testing.T) {
+	c := core.NewTestConfig(t)
+	db, err := core.InitMeddler(c.DbUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	account, err := core.GetAccount(db, "RETENTIONSTATE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := &core.AlertRule{RuleName: "retention", Metric: "Cushion", Operator: ">", Threshold: -1}
+	if err := meddler.Insert(db, "alert_rule", rule); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("DELETE FROM alert_rule WHERE id = $1", rule.Id)
+
+	// both earlier snapshots expire, but the rule was last evaluated against the
+	// first
+	var ids []int64
+	for _, day := range []int{4, 5, 9} {
+		s := &core.AccountSnapshot{AccountId: account.Id, Created: time.Date(2001, 1, day, 12, 0, 0, 0, time.UTC)}
+		if err := meddler.Insert(db, "account_snapshot", s); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.Id)
+	}
+	defer db.Exec("DELETE FROM account_snapshot WHERE account_id = $1", account.Id)
+	state := &core.AlertState{AlertRuleId: rule.Id, AccountId: account.Id, AccountSnapshotId: ids[0], Firing: true}
+	if err := meddler.Insert(db, "alert_state", state); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := core.NewRetentionPolicy("all:1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := Thin(db, p, time.Date(2001, 1, 10, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 snapshot deleted but %d were", deleted)
+	}
+
+	firing := false
+	err = db.QueryRow("SELECT firing FROM alert_state WHERE alert_rule_id = $1 AND account_snapshot_id = $2",
+		rule.Id, ids[0]).Scan(&firing)
+	if err != nil || !firing {
+		t.Fatalf("alert state lost (firing %v, error %v)", firing, err)
+	}
+}
+
+func TestThinKeepsExtendedSnapshots(t *testing.T) {
+	c := core.NewTestConfig(t)
+	db, err := core.InitMeddler(c.DbUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	at := func(day int) time.Time {
+		return time.Date(2001, 1, day, 12, 0, 0, 0, time.UTC)
+	}
+	insert := func(code string, created time.Time, validUntil time.Time) int64 {
+		account, err := core.GetAccount(db, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &core.AccountSnapshot{AccountId: account.Id, Created: created, ValidUntil: validUntil}
+		if err := meddler.Insert(db, "account_snapshot", s); err != nil {
+			t.Fatal(err)
+		}
+		return s.Id
+	}
+	defer db.Exec("DELETE FROM account_snapshot WHERE account_id IN " +
+		"(SELECT id FROM account WHERE account_code IN ('RETENTIONEXTENDED', 'RETENTIONIDLE'))")
+
+	// an unchanged account's old snapshot extended until yesterday, which
+	// replaced an older one
+	superseded := insert("RETENTIONEXTENDED", at(1), time.Time{})
+	extended := insert("RETENTIONEXTENDED", at(2), at(9))
+	// an account last seen long ago still has a latest snapshot
+	idle := insert("RETENTIONIDLE", at(2), at(3))
+
+	p, err := core.NewRetentionPolicy("all:1d,1h:3d,1d:5d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := Thin(db, p, at(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 snapshot deleted but %d were", deleted)
+	}
+
+	for id, kept := range map[int64]bool{superseded: false, extended: true, idle: true} {
+		count := 0
+		if err := db.QueryRow("SELECT count(*) FROM account_snapshot WHERE id = $1", id).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if (count == 1) != kept {
+			t.Fatalf("snapshot %d kept %v (expected %v)", id, count == 1, kept)
+		}
+	}
+}
@@ -2,29 +2,43 @@ package server
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/table"
 	"github.com/russross/meddler"
 )
 
 type AccountHandler struct {
 	db *sql.DB
-	n  *core.Notifier
+	n  core.Notifier
 	u  *Util
 }
 
+// AccountReport presents a single account snapshot. Balances are keyed by the
+// currency IB reported them in, with IB's consolidated values under "BASE".
+// Values holds every account value IB reported, without any conversion.
 type AccountReport struct {
-	AccountCode string
-	Timestamp   string
-	Balance     core.AccountAmountView
-	Positions   []*core.AccountPositionView
+	AccountCode  string
+	Timestamp    string
+	Currency     string `json:",omitempty"`
+	BaseCurrency string
+	Balances     map[string]core.AccountAmountView
+	Positions    []*core.AccountPositionView
+	Values       []*core.AccountValueView
 }
 
 func (a *AccountHandler) GetAll(w rest.ResponseWriter, r *rest.Request) {
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+
 	err := RefreshIfNeeded(a.n, r, core.NtAccountRefresh, core.NtAccountFeedDone, 15*time.Second)
 	if err != nil {
 		a.u.HandleError(err, w, r)
@@ -37,8 +51,21 @@ func (a *AccountHandler) GetAll(w rest.ResponseWriter, r *rest.Request) {
 		a.u.HandleError(err, w, r)
 		return
 	}
+
+	p := principal(r)
+	visible := []*core.Account{}
+	for _, account := range accounts {
+		if p.Permits(account.AccountCode) {
+			visible = append(visible, account)
+			auditAccounts(r, account.AccountCode)
+		}
+	}
 	w.Header().Add("Cache-Control", "private, max-age=60")
-	w.WriteJson(&accounts)
+	if format != formatJson {
+		writeTables(w, r, a.u, format, "accounts", accountsTable(visible))
+		return
+	}
+	w.WriteJson(&visible)
 }
 
 func (a *AccountHandler) GetLatest(w rest.ResponseWriter, r *rest.Request) {
@@ -51,15 +78,129 @@ func (a *AccountHandler) GetLatest(w rest.ResponseWriter, r *rest.Request) {
 	}
 
 	path := fmt.Sprintf("/v1/accounts/%s/%s", code, latest.Latest.Format(time.RFC3339Nano))
-	url := r.UrlFor(path, make(map[string][]string))
+	url := r.UrlFor(path, r.URL.Query())
 	w.Header().Add("Location", url.String())
 	w.WriteHeader(http.StatusSeeOther)
 }
 
+// SnapshotSummary lists an account snapshot and the URL of its report.
+// ValidUntil is the latest time the snapshot was found unchanged, if later than
+// its Timestamp.
+type SnapshotSummary struct {
+	Timestamp    string
+	ValidUntil   string `json:",omitempty"`
+	BaseCurrency string
+	Url          string
+}
+
+// during restricts account_snapshot s to snapshots current at some time
+// between $2 and $3.
+const during = "s.created <= $3 AND COALESCE(s.valid_until, s.created) >= $2"
+
+// GetSnapshots lists the account's snapshots current at any time between the
+// optional from and to RFC 3339 times. An XLSX workbook also has sheets of the balances and
+// positions of every listed snapshot.
+func (a *AccountHandler) GetSnapshots(w rest.ResponseWriter, r *rest.Request) {
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	from, to, err := timeRange(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	code := r.PathParam("accountCode")
+	existing := new(core.Account)
+	err = meddler.QueryRow(a.db, existing, "SELECT * FROM account WHERE account_code = $1", code)
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+
+	var snaps []*core.AccountSnapshot
+	err = meddler.QueryAll(a.db, &snaps, "SELECT * FROM account_snapshot s WHERE account_id = $1 AND "+during+" ORDER BY created", existing.Id, from, to)
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+
+	currencies, err := core.NewCurrencies(a.db)
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+
+	summaries := []*SnapshotSummary{}
+	timestamps := make(map[int64]string)
+	for _, snap := range snaps {
+		timestamp := snap.Created.Format(time.RFC3339Nano)
+		timestamps[snap.Id] = timestamp
+		url := r.UrlFor(fmt.Sprintf("/v1/accounts/%s/%s", code, timestamp), nil)
+		summary := &SnapshotSummary{Timestamp: timestamp, BaseCurrency: currencies[snap.BaseIso4217Code].AlphabeticCode, Url: url.String()}
+		if !snap.ValidUntil.IsZero() {
+			summary.ValidUntil = snap.ValidUntil.Format(time.RFC3339Nano)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	w.Header().Add("Cache-Control", "private, max-age=60")
+	name := filename(code, "snapshots")
+	switch format {
+	case formatJson:
+		w.WriteJson(&summaries)
+	case formatCsv:
+		writeTables(w, r, a.u, format, name, snapshotsTable(summaries))
+	case formatXlsx:
+		var positions []*core.AccountPositionView
+		err = meddler.QueryAll(a.db, &positions, "SELECT v.* FROM v_account_position v, account_snapshot s WHERE v.account_snapshot_id = s.id AND s.account_id = $1 AND "+during+" ORDER BY s.created, v.symbol", existing.Id, from, to)
+		if err != nil {
+			a.u.HandleError(err, w, r)
+			return
+		}
+		var positionRows []positionRow
+		for _, p := range positions {
+			positionRows = append(positionRows, positionRow{timestamps[p.AccountSnapshotId], p})
+		}
+
+		var balances []*core.AccountAmountView
+		err = meddler.QueryAll(a.db, &balances, "SELECT v.* FROM v_account_amount v, account_snapshot s WHERE v.account_snapshot_id = s.id AND s.account_id = $1 AND "+during+" ORDER BY s.created, v.base DESC, v.currency", existing.Id, from, to)
+		if err != nil {
+			a.u.HandleError(err, w, r)
+			return
+		}
+		var balanceRows []balanceRow
+		for _, b := range balances {
+			balanceRows = append(balanceRows, balanceRow{timestamps[b.AccountSnapshotId], b})
+		}
+
+		writeTables(w, r, a.u, format, name, snapshotsTable(summaries), balancesTable(balanceRows), positionsTable(positionRows))
+	}
+}
+
+// GetReport returns the snapshot as JSON, or as an XLSX workbook with sheets
+// of balances, positions and values. CSV contains the table named by the table
+// query parameter (positions, balances or values; default positions).
 func (a *AccountHandler) GetReport(w rest.ResponseWriter, r *rest.Request) {
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+	tableName := r.URL.Query().Get("table")
+	if tableName == "" {
+		tableName = "positions"
+	}
+	if tableName != "positions" && tableName != "balances" && tableName != "values" {
+		rest.Error(w, fmt.Sprintf("table '%s' is not positions, balances or values", tableName), http.StatusBadRequest)
+		return
+	}
+
 	var report AccountReport
 	report.AccountCode = r.PathParam("accountCode")
 	report.Timestamp = r.PathParam("timestamp")
+	report.Currency = r.URL.Query().Get("currency")
 	existing := new(core.Account)
 	err := meddler.QueryRow(a.db, existing, "SELECT * FROM account WHERE account_code = $1", report.AccountCode)
 	if err != nil {
@@ -74,7 +215,8 @@ func (a *AccountHandler) GetReport(w rest.ResponseWriter, r *rest.Request) {
 	}
 
 	var snap core.AccountSnapshot
-	err = meddler.QueryRow(a.db, &snap, "SELECT * FROM account_snapshot WHERE account_id = $1 AND created = $2", existing.Id, created)
+	err = meddler.QueryRow(a.db, &snap, "SELECT * FROM account_snapshot WHERE account_id = $1 AND created <= $2 AND "+
+		"COALESCE(valid_until, created) >= $2 ORDER BY created DESC LIMIT 1", existing.Id, created)
 	if err != nil {
 		a.u.HandleError(err, w, r)
 		return
@@ -86,12 +228,129 @@ func (a *AccountHandler) GetReport(w rest.ResponseWriter, r *rest.Request) {
 		return
 	}
 
-	err = meddler.QueryRow(a.db, &report.Balance, "SELECT * FROM v_account_amount WHERE account_snapshot_id = $1", snap.Id)
+	err = meddler.QueryAll(a.db, &report.Values, "SELECT * FROM v_account_value WHERE account_snapshot_id = $1", snap.Id)
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+
+	var balances []*core.AccountAmountView
+	err = meddler.QueryAll(a.db, &balances, "SELECT * FROM v_account_amount WHERE account_snapshot_id = $1", snap.Id)
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+
+	report.Balances = make(map[string]core.AccountAmountView)
+	for _, b := range balances {
+		report.Balances[balanceKey(*b)] = *b
+	}
+
+	base := new(core.Iso4217)
+	err = meddler.QueryRow(a.db, base, "SELECT * FROM iso_4217 WHERE iso_4217_code = $1", snap.BaseIso4217Code)
 	if err != nil {
 		a.u.HandleError(err, w, r)
 		return
 	}
+	report.BaseCurrency = base.AlphabeticCode
+
+	if report.Currency != "" {
+		err = a.convert(&report, snap.Id)
+		if err == errUnknownCurrency {
+			rest.Error(w, fmt.Sprintf("currency '%s' unavailable for this report", report.Currency), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			a.u.HandleError(err, w, r)
+			return
+		}
+	}
 
 	w.Header().Add("Cache-Control", "private, max-age=31556926")
+	if format != formatJson {
+		var positions []positionRow
+		for _, p := range report.Positions {
+			positions = append(positions, positionRow{report.Timestamp, p})
+		}
+		var keys []string
+		for key := range report.Balances {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var balances []balanceRow
+		for _, key := range keys {
+			b := report.Balances[key]
+			balances = append(balances, balanceRow{report.Timestamp, &b})
+		}
+		tables := map[string]table.Table{
+			"balances":  balancesTable(balances),
+			"positions": positionsTable(positions),
+			"values":    valuesTable(report.Timestamp, report.Values),
+		}
+		name := filename(report.AccountCode, report.Timestamp)
+		if format == formatCsv {
+			writeTables(w, r, a.u, format, name+"-"+tableName, tables[tableName])
+		} else {
+			writeTables(w, r, a.u, format, name, tables["balances"], tables["positions"], tables["values"])
+		}
+		return
+	}
 	w.WriteJson(&report)
 }
+
+var errUnknownCurrency = errors.New("unknown currency")
+
+// convert expresses all monetary fields of the report in the report currency,
+// using the exchange rates captured with the snapshot.
+func (a *AccountHandler) convert(report *AccountReport, snapshotId int64) error {
+	currencies, err := core.NewCurrencies(a.db)
+	if err != nil {
+		return err
+	}
+
+	target, ok := currencies.Find(report.Currency)
+	if !ok {
+		return errUnknownCurrency
+	}
+
+	rates, err := core.NewFxRates(a.db, snapshotId)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := rates[target.Iso4217Code]; !ok {
+		return errUnknownCurrency
+	}
+
+	var amts []*core.AccountAmount
+	err = meddler.QueryAll(a.db, &amts, "SELECT * FROM account_amount WHERE account_snapshot_id = $1", snapshotId)
+	if err != nil {
+		return err
+	}
+
+	for _, amt := range amts {
+		converted, err := amt.Convert(currencies, rates, target.Iso4217Code)
+		if err != nil {
+			return err
+		}
+		key := balanceKey(amt.View(currencies, ""))
+		report.Balances[key] = converted.View(currencies, report.Balances[key].AccountType)
+	}
+
+	for _, p := range report.Positions {
+		err = p.Convert(rates, target)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// balanceKey returns the AccountReport.Balances key for the passed balance.
+func balanceKey(b core.AccountAmountView) string {
+	if b.Base {
+		return "BASE"
+	}
+	return b.Currency
+}
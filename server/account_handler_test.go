@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -50,6 +51,133 @@ func TestAccountHandlerGetLatest(t *testing.T) {
 	recorded.HeaderIs("Cache-Control", "private, max-age=31556926")
 }
 
+func TestAccountHandlerGetReportWhileUnchanged(t *testing.T) {
+	ctx, handler := NewTestHandler(t)
+	defer ctx.Close()
+
+	c := core.NewTestConfig(t)
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	accountCode := ""
+	snap := core.AccountSnapshot{}
+	row := ctx.DB.QueryRow("SELECT account_code, account_snapshot.id, created FROM account, account_snapshot " +
+		"WHERE account.id = account_id ORDER BY account_snapshot.id DESC LIMIT 1")
+	if err := row.Scan(&accountCode, &snap.Id, &snap.Created); err != nil {
+		t.Fatal(err)
+	}
+
+	// pretend the feed found the snapshot unchanged a minute later
+	validUntil := snap.Created.Add(time.Minute)
+	if _, err := ctx.DB.Exec("UPDATE account_snapshot SET valid_until = $1 WHERE id = $2", validUntil, snap.Id); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.DB.Exec("UPDATE account_snapshot SET valid_until = NULL WHERE id = $1", snap.Id)
+
+	url := fmt.Sprintf("http://1.2.3.4/v1/accounts/%s", accountCode)
+	recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusSeeOther)
+	if target := recorded.Recorder.Header().Get("Location"); !strings.HasSuffix(target, validUntil.UTC().Format(time.RFC3339Nano)) {
+		t.Fatalf("latest report %s is not when the snapshot was last found unchanged", target)
+	}
+
+	during := fmt.Sprintf("%s/%s", url, snap.Created.Add(30*time.Second).UTC().Format(time.RFC3339Nano))
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", during, nil))
+	recorded.CodeIs(http.StatusOK)
+
+	after := fmt.Sprintf("%s/%s", url, validUntil.Add(time.Second).UTC().Format(time.RFC3339Nano))
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", after, nil))
+	recorded.CodeIs(http.StatusNotFound)
+}
+
+func TestAccountHandlerGetReportBalancesByCurrency(t *testing.T) {
+	ctx, handler := NewTestHandler(t)
+	defer ctx.Close()
+
+	c := core.NewTestConfig(t)
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	accountCode := ""
+	row := ctx.DB.QueryRow("SELECT account_code FROM account LIMIT 1")
+	if err := row.Scan(&accountCode); err != nil {
+		t.Fatal(err)
+	}
+
+	url := fmt.Sprintf("http://1.2.3.4/v1/accounts/%s", accountCode)
+	recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	target := recorded.Recorder.Header().Get("Location")
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", target, nil))
+	recorded.CodeIs(http.StatusOK)
+
+	report := AccountReport{}
+	if err := recorded.DecodeJsonPayload(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.BaseCurrency == "" {
+		t.Fatal("base currency not reported")
+	}
+	base, ok := report.Balances["BASE"]
+	if !ok {
+		t.Fatal("BASE balance not reported")
+	}
+	if !strings.HasPrefix(base.TotalCashBalance, report.BaseCurrency) {
+		t.Fatalf("BASE total cash balance %s not in %s", base.TotalCashBalance, report.BaseCurrency)
+	}
+	if _, ok := report.Balances[report.BaseCurrency]; !ok {
+		t.Fatalf("%s balance not reported", report.BaseCurrency)
+	}
+	if len(report.Values) == 0 {
+		t.Fatal("raw account values not reported")
+	}
+}
+
+func TestAccountHandlerGetReportCurrency(t *testing.T) {
+	ctx, handler := NewTestHandler(t)
+	defer ctx.Close()
+
+	c := core.NewTestConfig(t)
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	accountCode := ""
+	currency := ""
+	row := ctx.DB.QueryRow("SELECT account_code, alphabetic_code FROM v_fx_rate, account_snapshot, account " +
+		"WHERE account_snapshot.id = account_snapshot_id AND account.id = account_id LIMIT 1")
+	if err := row.Scan(&accountCode, &currency); err != nil {
+		t.Fatal(err)
+	}
+
+	url := fmt.Sprintf("http://1.2.3.4/v1/accounts/%s?currency=%s", accountCode, currency)
+	recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusSeeOther)
+	target := recorded.Recorder.Header().Get("Location")
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", target, nil))
+	recorded.CodeIs(http.StatusOK)
+	recorded.ContentTypeIsJson()
+
+	report := AccountReport{}
+	if err := recorded.DecodeJsonPayload(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Currency != currency {
+		t.Fatalf("expected report in %s (was %s)", currency, report.Currency)
+	}
+	for key, balance := range report.Balances {
+		if !strings.HasPrefix(balance.TotalCashBalance, currency) && balance.TotalCashBalance != "NIL 0" {
+			t.Fatalf("%s total cash balance %s not in %s", key, balance.TotalCashBalance, currency)
+		}
+	}
+
+	url = fmt.Sprintf("http://1.2.3.4/v1/accounts/%s?currency=XYZ", accountCode)
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	target = recorded.Recorder.Header().Get("Location")
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", target, nil))
+	recorded.CodeIs(http.StatusBadRequest)
+}
+
 func TestAccountHandlerGetAllRefresh(t *testing.T) {
 	ctx, handler := NewTestHandler(t)
 	defer ctx.Close()
@@ -84,3 +212,61 @@ outer:
 		}
 	}
 }
+
+func TestAccountHandlerExport(t *testing.T) {
+	ctx, handler := NewTestHandler(t)
+	defer ctx.Close()
+
+	c := core.NewTestConfig(t)
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	accountCode := ""
+	row := ctx.DB.QueryRow("SELECT account_code FROM account LIMIT 1")
+	if err := row.Scan(&accountCode); err != nil {
+		t.Fatal(err)
+	}
+
+	url := fmt.Sprintf("http://1.2.3.4/v1/accounts/%s/snapshots", accountCode)
+	recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusOK)
+	recorded.ContentTypeIsJson()
+	snapshots := []SnapshotSummary{}
+	if err := recorded.DecodeJsonPayload(&snapshots); err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) == 0 || snapshots[0].BaseCurrency == "" {
+		t.Fatalf("unexpected snapshots %v", snapshots)
+	}
+
+	req := test.MakeSimpleRequest("GET", url, nil)
+	req.Header.Set("Accept", "text/csv")
+	recorded = test.RunRequest(t, handler, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.HeaderIs("Content-Type", "text/csv; charset=utf-8")
+	if !strings.HasPrefix(recorded.Recorder.Body.String(), "Timestamp,ValidUntil,BaseCurrency\n") {
+		t.Fatalf("unexpected CSV %s", recorded.Recorder.Body.String())
+	}
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url+"?format=xlsx", nil))
+	recorded.CodeIs(http.StatusOK)
+	recorded.HeaderIs("Content-Type", xlsxType)
+	if !strings.HasPrefix(recorded.Recorder.Body.String(), "PK") {
+		t.Fatal("XLSX is not a zip archive")
+	}
+
+	url = fmt.Sprintf("http://1.2.3.4/v1/accounts/%s/%s?format=csv&table=balances", accountCode, snapshots[0].Timestamp)
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusOK)
+	recorded.HeaderIs("Content-Type", "text/csv; charset=utf-8")
+	if !strings.Contains(recorded.Recorder.Body.String(), "\n"+snapshots[0].Timestamp+",BASE,") {
+		t.Fatalf("BASE balance not exported %s", recorded.Recorder.Body.String())
+	}
+
+	url = fmt.Sprintf("http://1.2.3.4/v1/accounts/%s/%s?format=csv&table=orders", accountCode, snapshots[0].Timestamp)
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusBadRequest)
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://1.2.3.4/v1/accounts?format=pdf", nil))
+	recorded.CodeIs(http.StatusBadRequest)
+}
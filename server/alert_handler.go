@@ -0,0 +1,204 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/alert"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+type AlertHandler struct {
+	db *sql.DB
+	u  *Util
+}
+
+// AlertRuleRequest creates or replaces an alert rule. An empty AccountCode
+// applies the rule to every account. Enabled defaults to true.
+type AlertRuleRequest struct {
+	AccountCode string
+	RuleName    string
+	Metric      string
+	Operator    string
+	Threshold   float64
+	Hysteresis  float64
+	Enabled     *bool
+}
+
+func (a *AlertHandler) GetAlerts(w rest.ResponseWriter, r *rest.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			rest.Error(w, fmt.Sprintf("limit '%s' is not a positive integer", v), http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	alerts := []*core.AlertView{}
+	var err error
+	p := principal(r)
+	if code := r.URL.Query().Get("account"); code != "" {
+		if !permitted(w, r, []string{code}) {
+			return
+		}
+		err = meddler.QueryAll(a.db, &alerts, "SELECT * FROM v_alert WHERE account_code = $1 ORDER BY created DESC LIMIT $2", code, limit)
+	} else if p != nil && !p.Admin {
+		err = meddler.QueryAll(a.db, &alerts, "SELECT * FROM v_alert WHERE account_code IN (SELECT account_code "+
+			"FROM v_api_key_account WHERE api_key_id = $1) ORDER BY created DESC LIMIT $2", p.KeyId, limit)
+	} else {
+		err = meddler.QueryAll(a.db, &alerts, "SELECT * FROM v_alert ORDER BY created DESC LIMIT $1", limit)
+	}
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+	for _, view := range alerts {
+		auditAccounts(r, view.AccountCode)
+	}
+	w.Header().Add("Cache-Control", "private, max-age=0")
+	w.WriteJson(&alerts)
+}
+
+func (a *AlertHandler) GetRules(w rest.ResponseWriter, r *rest.Request) {
+	rules := []*core.AlertRuleView{}
+	err := meddler.QueryAll(a.db, &rules, "SELECT * FROM v_alert_rule")
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteJson(&rules)
+}
+
+func (a *AlertHandler) GetRule(w rest.ResponseWriter, r *rest.Request) {
+	id, err := strconv.ParseInt(r.PathParam("ruleId"), 10, 64)
+	if err != nil {
+		rest.NotFound(w, r)
+		return
+	}
+
+	rule := new(core.AlertRuleView)
+	err = meddler.QueryRow(a.db, rule, "SELECT * FROM v_alert_rule WHERE id = $1", id)
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteJson(rule)
+}
+
+func (a *AlertHandler) PostRule(w rest.ResponseWriter, r *rest.Request) {
+	rule := new(core.AlertRule)
+	if !a.decodeRule(w, r, rule) {
+		return
+	}
+
+	err := meddler.Insert(a.db, "alert_rule", rule)
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	a.writeRule(w, r, rule.Id)
+}
+
+func (a *AlertHandler) PutRule(w rest.ResponseWriter, r *rest.Request) {
+	id, err := strconv.ParseInt(r.PathParam("ruleId"), 10, 64)
+	if err != nil {
+		rest.NotFound(w, r)
+		return
+	}
+
+	rule := new(core.AlertRule)
+	err = meddler.Load(a.db, "alert_rule", rule, id)
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+	if !a.decodeRule(w, r, rule) {
+		return
+	}
+
+	// changed rules are re-evaluated from scratch
+	_, err = a.db.Exec("DELETE FROM alert_state WHERE alert_rule_id = $1", id)
+	if err == nil {
+		err = meddler.Update(a.db, "alert_rule", rule)
+	}
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+	a.writeRule(w, r, rule.Id)
+}
+
+func (a *AlertHandler) DeleteRule(w rest.ResponseWriter, r *rest.Request) {
+	id, err := strconv.ParseInt(r.PathParam("ruleId"), 10, 64)
+	if err != nil {
+		rest.NotFound(w, r)
+		return
+	}
+
+	res, err := a.db.Exec("DELETE FROM alert_rule WHERE id = $1", id)
+	if err == nil {
+		err = noRowsIfUnaffected(res)
+	}
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeRule applies the request to the rule. If false is returned an error
+// has been written.
+func (a *AlertHandler) decodeRule(w rest.ResponseWriter, r *rest.Request, rule *core.AlertRule) bool {
+	req := AlertRuleRequest{}
+	err := r.DecodeJsonPayload(&req)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	rule.AccountId = 0
+	if req.AccountCode != "" {
+		account := new(core.Account)
+		err = meddler.QueryRow(a.db, account, "SELECT * FROM account WHERE account_code = $1", req.AccountCode)
+		if err == sql.ErrNoRows {
+			rest.Error(w, fmt.Sprintf("account '%s' is unknown", req.AccountCode), http.StatusBadRequest)
+			return false
+		}
+		if err != nil {
+			a.u.HandleError(err, w, r)
+			return false
+		}
+		rule.AccountId = account.Id
+	}
+
+	rule.RuleName = req.RuleName
+	rule.Metric = req.Metric
+	rule.Operator = req.Operator
+	rule.Threshold = req.Threshold
+	rule.Hysteresis = req.Hysteresis
+	rule.Enabled = req.Enabled == nil || *req.Enabled
+
+	err = alert.Validate(*rule)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (a *AlertHandler) writeRule(w rest.ResponseWriter, r *rest.Request, id int64) {
+	view := new(core.AlertRuleView)
+	err := meddler.QueryRow(a.db, view, "SELECT * FROM v_alert_rule WHERE id = $1", id)
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteJson(view)
+}
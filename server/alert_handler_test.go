@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/benalexau/ibconnect/core"
+)
+
+func TestAlertHandlerRuleLifecycle(t *testing.T) {
+	ctx, handler := NewTestHandler(t)
+	defer ctx.Close()
+
+	req := AlertRuleRequest{RuleName: "low cushion", Metric: "Cushion", Operator: "<", Threshold: 0.1, Hysteresis: 0.05}
+	recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://1.2.3.4/v1/alerts/rules", &req))
+	recorded.CodeIs(http.StatusCreated)
+
+	rule := core.AlertRuleView{}
+	if err := recorded.DecodeJsonPayload(&rule); err != nil {
+		t.Fatal(err)
+	}
+	if !rule.Enabled || rule.AccountCode != "" || rule.Threshold != 0.1 {
+		t.Fatalf("unexpected rule %v", rule)
+	}
+	url := fmt.Sprintf("http://1.2.3.4/v1/alerts/rules/%d", rule.Id)
+	defer test.RunRequest(t, handler, test.MakeSimpleRequest("DELETE", url, nil))
+
+	disabled := false
+	req.Enabled = &disabled
+	req.Threshold = 0.2
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", url, &req))
+	recorded.CodeIs(http.StatusOK)
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusOK)
+	if err := recorded.DecodeJsonPayload(&rule); err != nil {
+		t.Fatal(err)
+	}
+	if rule.Enabled || rule.Threshold != 0.2 {
+		t.Fatalf("rule not updated %v", rule)
+	}
+
+	req.Metric = "Cash"
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", url, &req))
+	recorded.CodeIs(http.StatusBadRequest)
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://1.2.3.4/v1/alerts/rules", nil))
+	recorded.CodeIs(http.StatusOK)
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://1.2.3.4/v1/alerts?limit=10", nil))
+	recorded.CodeIs(http.StatusOK)
+	recorded.ContentTypeIsJson()
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("DELETE", url, nil))
+	recorded.CodeIs(http.StatusNoContent)
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusNotFound)
+}
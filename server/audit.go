@@ -0,0 +1,176 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+// auditAccountsEnv is the rest.Request Env key of the account codes returned.
+const auditAccountsEnv = "AUDIT_ACCOUNTS"
+
+// audited indicates whether requests to the route return account data and
+// must therefore be recorded in the audit log.
+func audited(pathExp string) bool {
+	switch pathExp {
+	case "/v1/alerts", "/v1/events", "/v1/exposure", "/v1/groups/:groupName/concentration", "/v1/performance":
+		return true
+	}
+	return strings.HasPrefix(pathExp, "/v1/accounts")
+}
+
+// auditAccounts records account codes returned by the request. Codes in the
+// accountCode path parameter and accounts query parameter are recorded
+// automatically for successful requests.
+func auditAccounts(r *rest.Request, accountCodes ...string) {
+	codes, _ := r.Env[auditAccountsEnv].(map[string]bool)
+	if codes == nil {
+		codes = make(map[string]bool)
+		r.Env[auditAccountsEnv] = codes
+	}
+	for _, code := range accountCodes {
+		if code != "" {
+			codes[code] = true
+		}
+	}
+}
+
+// auditWriter captures the status code written by a handler.
+type auditWriter struct {
+	streamWriter
+	status int
+}
+
+func (w *auditWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.streamWriter.WriteHeader(code)
+}
+
+// Auditor records requests in the audit_log table.
+type Auditor struct {
+	db *sql.DB
+	u  *Util
+}
+
+// Wrap returns the route's handler, recording each request once it completes.
+// The request is assigned a correlation ID, which is returned in the
+// X-Correlation-Id header and reported by Util.HandleError.
+func (a *Auditor) Wrap(route *rest.Route) rest.HandlerFunc {
+	handler := route.Func
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		id := a.u.uuid()
+		r.Env[correlationEnv] = id
+		w.Header().Set("X-Correlation-Id", id)
+
+		aw := &auditWriter{streamWriter: streamWriter{w}}
+		handler(aw, r)
+
+		entry := &core.AuditLog{
+			Created:       time.Now().UTC(),
+			CorrelationId: id,
+			RemoteAddr:    r.RemoteAddr,
+			Method:        r.Method,
+			Route:         route.PathExp,
+			Path:          redactedURI(r.URL),
+			Status:        aw.status,
+		}
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		if p := principal(r); p != nil {
+			entry.Principal = p.Name
+			entry.ApiKeyId = p.KeyId
+		}
+		if entry.Status < http.StatusBadRequest {
+			auditAccounts(r, r.PathParam("accountCode"))
+			if v := r.URL.Query().Get("accounts"); v != "" {
+				auditAccounts(r, strings.Split(v, ",")...)
+			}
+		}
+		returned, _ := r.Env[auditAccountsEnv].(map[string]bool)
+		codes := []string{}
+		for code := range returned {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		entry.AccountCodes = strings.Join(codes, ",")
+
+		if err := meddler.Insert(a.db, "audit_log", entry); err != nil {
+			log.Printf("audit log not recorded: %v [%s] [%s]", err, id, redactedURI(r.URL))
+		}
+	}
+}
+
+type AuditHandler struct {
+	db *sql.DB
+	u  *Util
+}
+
+// AuditEntry presents an audit_log record.
+type AuditEntry struct {
+	core.AuditLog
+	AccountCodes []string
+}
+
+// GetAll returns the most recent audit records, optionally filtered by the
+// account and principal query parameters and the from and to RFC 3339 times,
+// and limited by the limit query parameter (default 100).
+func (a *AuditHandler) GetAll(w rest.ResponseWriter, r *rest.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			rest.Error(w, fmt.Sprintf("limit '%s' is not a positive integer", v), http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	from, to, err := timeRange(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := "SELECT * FROM audit_log WHERE created >= $1 AND created <= $2"
+	args := []interface{}{from, to}
+	if v := r.URL.Query().Get("account"); v != "" {
+		args = append(args, v)
+		query += fmt.Sprintf(" AND ',' || account_codes || ',' LIKE '%%,' || $%d || ',%%'", len(args))
+	}
+	if v := r.URL.Query().Get("principal"); v != "" {
+		args = append(args, v)
+		query += fmt.Sprintf(" AND principal = $%d", len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	var logs []*core.AuditLog
+	err = meddler.QueryAll(a.db, &logs, query, args...)
+	if err != nil {
+		a.u.HandleError(err, w, r)
+		return
+	}
+
+	entries := []*AuditEntry{}
+	for _, l := range logs {
+		entry := &AuditEntry{AuditLog: *l, AccountCodes: []string{}}
+		if l.AccountCodes != "" {
+			entry.AccountCodes = strings.Split(l.AccountCodes, ",")
+		}
+		entries = append(entries, entry)
+	}
+	w.Header().Add("Cache-Control", "private, max-age=0")
+	w.WriteJson(&entries)
+}
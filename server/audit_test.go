@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/gateway"
+	"github.com/benalexau/ibconnect/migrate"
+)
+
+func TestAudited(t *testing.T) {
+	for _, path := range []string{"/v1/accounts", "/v1/accounts/:accountCode/*timestamp", "/v1/events", "/v1/exposure"} {
+		if !audited(path) {
+			t.Fatalf("%s should be audited", path)
+		}
+	}
+	for _, path := range []string{"/v1/audit", "/v1/keys", "/v1/webhooks", "/v1/groups/:groupName"} {
+		if audited(path) {
+			t.Fatalf("%s should not be audited", path)
+		}
+	}
+}
+
+// recorderWriter adapts a ResponseRecorder to rest.ResponseWriter.
+type recorderWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w recorderWriter) WriteJson(v interface{}) error            { return nil }
+func (w recorderWriter) EncodeJson(v interface{}) ([]byte, error) { return nil, nil }
+func (w recorderWriter) CloseNotify() <-chan bool                 { return nil }
+
+func TestAuditWriterPassesThrough(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	aw := &auditWriter{streamWriter: streamWriter{recorderWriter{recorder}}}
+
+	var w rest.ResponseWriter = aw
+	if _, ok := w.(http.Flusher); !ok {
+		t.Fatal("auditWriter should be a http.Flusher")
+	}
+	if _, ok := w.(http.CloseNotifier); !ok {
+		t.Fatal("auditWriter should be a http.CloseNotifier")
+	}
+
+	w.WriteHeader(http.StatusForbidden)
+	w.(http.ResponseWriter).Write([]byte("denied"))
+	if aw.status != http.StatusForbidden || recorder.Code != http.StatusForbidden || recorder.Body.String() != "denied" {
+		t.Fatalf("unexpected status %d (recorded %d %s)", aw.status, recorder.Code, recorder.Body)
+	}
+}
+
+func TestAuditLogRecordsAccountRequests(t *testing.T) {
+	c := core.NewTestConfig(t)
+	c.AdminKey = "test-admin-key"
+
+	ctx, err := core.NewContext(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+	handler := Handler(c, ctx.DB, ctx.N)
+
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	accountCode := ""
+	if err := ctx.DB.QueryRow("SELECT account_code FROM account LIMIT 1").Scan(&accountCode); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(url, key string, payload interface{}) *test.Recorded {
+		method := "GET"
+		if payload != nil {
+			method = "POST"
+		}
+		req := test.MakeSimpleRequest(method, "http://1.2.3.4"+url, payload)
+		req.Header.Set("Authorization", "Bearer "+key)
+		return test.RunRequest(t, handler, req)
+	}
+
+	name := fmt.Sprintf("audit-%d", time.Now().UnixNano())
+	recorded := request("/v1/keys", c.AdminKey, &KeyRequest{Name: name, AccountCodes: []string{accountCode}})
+	recorded.CodeIs(http.StatusCreated)
+	key := KeyReport{}
+	if err := recorded.DecodeJsonPayload(&key); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.DB.Exec("DELETE FROM api_key WHERE id = $1", key.Id)
+
+	recorded = request("/v1/accounts", key.Key, nil)
+	recorded.CodeIs(http.StatusOK)
+	id := recorded.Recorder.Header().Get("X-Correlation-Id")
+	if len(id) != 36 {
+		t.Fatalf("unexpected correlation ID '%s'", id)
+	}
+
+	recorded = request("/v1/audit?principal="+name, c.AdminKey, nil)
+	recorded.CodeIs(http.StatusOK)
+	var entries []*AuditEntry
+	if err := recorded.DecodeJsonPayload(&entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one audit entry (was %d)", len(entries))
+	}
+	e := entries[0]
+	if e.CorrelationId != id || e.Route != "/v1/accounts" || e.Status != http.StatusOK || e.ApiKeyId != key.Id ||
+		len(e.AccountCodes) != 1 || e.AccountCodes[0] != accountCode {
+		t.Fatalf("unexpected audit entry %v", e)
+	}
+
+	request("/v1/audit", key.Key, nil).CodeIs(http.StatusForbidden)
+
+	if _, err := ctx.DB.Exec("DELETE FROM audit_log WHERE id = $1", e.Id); err == nil {
+		t.Fatal("audit log should be append-only")
+	}
+}
+
+func TestAuditLogRedactsAccessToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ibconnect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the Auditor only needs an audit_log table, which SQLite provides
+	s := &core.SqliteStorage{Path: filepath.Join(dir, "ibc.db")}
+	db, err := s.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ms, err := migrate.Embedded(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrate.Up(db, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Auditor{db: db, u: &Util{}}
+	route := &rest.Route{HttpMethod: "GET", PathExp: "/v1/events", Func: func(w rest.ResponseWriter, r *rest.Request) {}}
+	req, err := http.NewRequest("GET", "http://1.2.3.4/v1/events?accounts=DU123&access_token=secret-key", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Wrap(route)(recorderWriter{httptest.NewRecorder()}, &rest.Request{Request: req, Env: make(map[string]interface{})})
+
+	path := ""
+	if err := db.QueryRow("SELECT path FROM audit_log").Scan(&path); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1/events?accounts=DU123" {
+		t.Fatalf("unexpected path %s", path)
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+// principalEnv is the rest.Request Env key of the authenticated Principal.
+const principalEnv = "PRINCIPAL"
+
+// accessTokenParam is the query parameter that may present an API key.
+const accessTokenParam = "access_token"
+
+// Principal is the client an API key was issued to. An admin may access every
+// account and manage the server, whereas other principals may only access the
+// accounts their key is scoped to. If Pseudonymise is true the principal only
+// sees tokens in place of account codes.
+type Principal struct {
+	KeyId        int64
+	Name         string
+	Admin        bool
+	Pseudonymise bool
+	accounts     map[string]bool
+}
+
+// Permits indicates whether the principal may access the account. A nil
+// principal (ie authentication is disabled) may access every account.
+func (p *Principal) Permits(accountCode string) bool {
+	return p == nil || p.Admin || p.accounts[accountCode]
+}
+
+// principal returns the authenticated Principal of the request, or nil if
+// authentication is disabled.
+func principal(r *rest.Request) *Principal {
+	p, _ := r.Env[principalEnv].(*Principal)
+	return p
+}
+
+// hashKey returns the SHA-256 hex digest stored in place of an API key. Keys
+// are long random values, so a slow password hash is unnecessary.
+func hashKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// redactedURI returns the path and query of the URL without any API key
+// presented as the access_token parameter, so it can be logged and audited.
+func redactedURI(u *url.URL) string {
+	q := u.Query()
+	if _, ok := q[accessTokenParam]; !ok {
+		return u.RequestURI()
+	}
+	q.Del(accessTokenParam)
+	return (&url.URL{Path: u.Path, RawQuery: q.Encode()}).RequestURI()
+}
+
+// AuthMiddleware authenticates each request by a verified client certificate
+// whose subject common name is the cert_subject of an API key, or otherwise by
+// the API key presented as a bearer token, either in the Authorization header
+// or the access_token query parameter (as EventSource cannot set headers). The
+// AdminKey is accepted in addition to the keys stored in the api_key table.
+type AuthMiddleware struct {
+	db       *sql.DB
+	u        *Util
+	AdminKey string
+}
+
+func (a *AuthMiddleware) MiddlewareFunc(handler rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			p, err := a.authenticateCert(r.TLS.VerifiedChains[0][0])
+			if err == nil {
+				r.Env[principalEnv] = p
+				handler(w, r)
+				return
+			}
+			if err != sql.ErrNoRows {
+				a.u.HandleError(err, w, r)
+				return
+			}
+			// an unmapped certificate may still present an API key
+		}
+
+		key := r.URL.Query().Get(accessTokenParam)
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			key = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+		if key == "" {
+			a.unauthorized(w, "API key required")
+			return
+		}
+
+		p, err := a.authenticate(key)
+		if err == sql.ErrNoRows {
+			a.unauthorized(w, "API key invalid")
+			return
+		}
+		if err != nil {
+			a.u.HandleError(err, w, r)
+			return
+		}
+
+		r.Env[principalEnv] = p
+		handler(w, r)
+	}
+}
+
+func (a *AuthMiddleware) unauthorized(w rest.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="ibconnect"`)
+	rest.Error(w, msg, http.StatusUnauthorized)
+}
+
+// authenticate returns the Principal of the key, or sql.ErrNoRows if unknown.
+func (a *AuthMiddleware) authenticate(key string) (*Principal, error) {
+	if subtle.ConstantTimeCompare([]byte(key), []byte(a.AdminKey)) == 1 {
+		return &Principal{Name: "ADMIN_KEY", Admin: true}, nil
+	}
+
+	apiKey := new(core.ApiKey)
+	err := meddler.QueryRow(a.db, apiKey, "SELECT * FROM api_key WHERE key_hash = $1", hashKey(key))
+	if err != nil {
+		return nil, err
+	}
+	return a.principal(apiKey)
+}
+
+// authenticateCert returns the Principal of the key mapped to the verified
+// client certificate's subject common name, or sql.ErrNoRows if unmapped.
+func (a *AuthMiddleware) authenticateCert(cert *x509.Certificate) (*Principal, error) {
+	apiKey := new(core.ApiKey)
+	err := meddler.QueryRow(a.db, apiKey, "SELECT * FROM api_key WHERE cert_subject = $1", cert.Subject.CommonName)
+	if err != nil {
+		return nil, err
+	}
+	return a.principal(apiKey)
+}
+
+// principal returns the Principal of the key, including the accounts it may
+// access.
+func (a *AuthMiddleware) principal(apiKey *core.ApiKey) (*Principal, error) {
+	p := &Principal{KeyId: apiKey.Id, Name: apiKey.KeyName, Admin: apiKey.Admin, Pseudonymise: apiKey.Pseudonymise,
+		accounts: make(map[string]bool)}
+	rows, err := a.db.Query("SELECT account_code FROM v_api_key_account WHERE api_key_id = $1", apiKey.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		code := ""
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		p.accounts[code] = true
+	}
+	return p, rows.Err()
+}
+
+// requireAccount wraps a handler so it is only invoked if the principal may
+// access the account in the accountCode path parameter.
+func requireAccount(handler rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		if permitted(w, r, []string{r.PathParam("accountCode")}) {
+			handler(w, r)
+		}
+	}
+}
+
+// requireAdmin wraps a handler so it is only invoked for an admin principal.
+func requireAdmin(handler rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		if p := principal(r); p != nil && !p.Admin {
+			rest.Error(w, fmt.Sprintf("API key '%s' is not an admin key", p.Name), http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// permitted indicates whether the principal may access every account. If
+// false is returned an error has been written.
+func permitted(w rest.ResponseWriter, r *rest.Request, accountCodes []string) bool {
+	p := principal(r)
+	for _, code := range accountCodes {
+		if !p.Permits(code) {
+			rest.Error(w, fmt.Sprintf("API key '%s' may not access account '%s'", p.Name, code), http.StatusForbidden)
+			return false
+		}
+	}
+	return true
+}
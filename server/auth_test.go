@@ -0,0 +1,206 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/gateway"
+	"github.com/benalexau/ibconnect/migrate"
+	"github.com/russross/meddler"
+)
+
+func TestPrincipalPermits(t *testing.T) {
+	var disabled *Principal
+	if !disabled.Permits("U1") {
+		t.Fatal("nil principal should permit every account")
+	}
+
+	admin := &Principal{Name: "admin", Admin: true}
+	if !admin.Permits("U1") {
+		t.Fatal("admin should permit every account")
+	}
+
+	scoped := &Principal{Name: "client", accounts: map[string]bool{"U1": true}}
+	if !scoped.Permits("U1") || scoped.Permits("U2") {
+		t.Fatal("scoped principal should only permit U1")
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	// echo -n abc | sha256sum
+	expected := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if h := hashKey("abc"); h != expected {
+		t.Fatalf("expected %s (was %s)", expected, h)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	c := core.NewTestConfig(t)
+	c.AdminKey = "test-admin-key"
+
+	ctx, err := core.NewContext(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+	handler := Handler(c, ctx.DB, ctx.N)
+
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	request := func(method, url, key string, payload interface{}) *test.Recorded {
+		req := test.MakeSimpleRequest(method, "http://1.2.3.4"+url, payload)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		return test.RunRequest(t, handler, req)
+	}
+
+	request("GET", "/v1/accounts", "", nil).CodeIs(http.StatusUnauthorized)
+	request("GET", "/v1/accounts", "wrong", nil).CodeIs(http.StatusUnauthorized)
+	request("GET", "/v1/accounts", c.AdminKey, nil).CodeIs(http.StatusOK)
+
+	var codes []string
+	rows, err := ctx.DB.Query("SELECT account_code FROM account ORDER BY account_code LIMIT 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		code := ""
+		if err := rows.Scan(&code); err != nil {
+			t.Fatal(err)
+		}
+		codes = append(codes, code)
+	}
+	rows.Close()
+	if len(codes) < 2 {
+		t.Skip("test requires a financial advisor with at least two accounts")
+	}
+
+	recorded := request("POST", "/v1/keys", c.AdminKey, &KeyRequest{Name: "client", AccountCodes: codes[:1]})
+	recorded.CodeIs(http.StatusCreated)
+	key := KeyReport{}
+	if err := recorded.DecodeJsonPayload(&key); err != nil {
+		t.Fatal(err)
+	}
+	if key.Key == "" || len(key.AccountCodes) != 1 {
+		t.Fatalf("unexpected key %v", key)
+	}
+	defer request("DELETE", fmt.Sprintf("/v1/keys/%d", key.Id), c.AdminKey, nil)
+
+	recorded = request("GET", "/v1/accounts", key.Key, nil)
+	recorded.CodeIs(http.StatusOK)
+	var accounts []*core.Account
+	if err := recorded.DecodeJsonPayload(&accounts); err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].AccountCode != codes[0] {
+		t.Fatalf("expected only %s (was %v)", codes[0], accounts)
+	}
+
+	request("GET", "/v1/accounts/"+codes[0], key.Key, nil).CodeIs(http.StatusSeeOther)
+	request("GET", "/v1/accounts/"+codes[1], key.Key, nil).CodeIs(http.StatusForbidden)
+	request("GET", "/v1/exposure?accounts="+codes[0]+","+codes[1], key.Key, nil).CodeIs(http.StatusForbidden)
+	request("GET", "/v1/keys", key.Key, nil).CodeIs(http.StatusForbidden)
+
+	request("DELETE", fmt.Sprintf("/v1/keys/%d", key.Id), c.AdminKey, nil).CodeIs(http.StatusNoContent)
+	request("GET", "/v1/accounts", key.Key, nil).CodeIs(http.StatusUnauthorized)
+}
+
+func TestAuthMiddlewareClientCertificate(t *testing.T) {
+	c := core.NewTestConfig(t)
+	c.TlsClientCa = "ca.pem"
+
+	ctx, err := core.NewContext(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+	handler := Handler(c, ctx.DB, ctx.N)
+
+	subject := fmt.Sprintf("client-%d", time.Now().UnixNano())
+	key := &core.ApiKey{Created: time.Now().UTC(), KeyName: "client", KeyHash: hashKey(subject), CertSubject: subject}
+	if err := meddler.Insert(ctx.DB, "api_key", key); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.DB.Exec("DELETE FROM api_key WHERE id = $1", key.Id)
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: subject}}
+	request := func(url string, cert *x509.Certificate) *test.Recorded {
+		req := test.MakeSimpleRequest("GET", "http://1.2.3.4"+url, nil)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		if cert != nil {
+			req.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+		}
+		return test.RunRequest(t, handler, req)
+	}
+
+	request("/v1/accounts", cert).CodeIs(http.StatusOK)
+	request("/v1/keys", cert).CodeIs(http.StatusForbidden)
+	request("/v1/accounts", &x509.Certificate{Subject: pkix.Name{CommonName: "unmapped"}}).CodeIs(http.StatusUnauthorized)
+	request("/v1/accounts", nil).CodeIs(http.StatusUnauthorized)
+}
+
+func TestRequireAdminForAccountWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ibconnect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a SQLite file and in-memory notifier suffice, as no feed runs
+	s := &core.SqliteStorage{Path: filepath.Join(dir, "ibc.db")}
+	db, err := s.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ms, err := migrate.Embedded(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrate.Up(db, ms); err != nil {
+		t.Fatal(err)
+	}
+	n := core.NewLocalNotifier()
+	defer n.Close()
+
+	c := core.Config{AdminKey: "test-admin-key"}
+	handler := Handler(c, db, n)
+	if _, err := core.GetAccount(db, "DU123"); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, url, key string, payload interface{}) *test.Recorded {
+		req := test.MakeSimpleRequest(method, "http://1.2.3.4"+url, payload)
+		req.Header.Set("Authorization", "Bearer "+key)
+		return test.RunRequest(t, handler, req)
+	}
+
+	recorded := request("POST", "/v1/keys", c.AdminKey, &KeyRequest{Name: "client", AccountCodes: []string{"DU123"}})
+	recorded.CodeIs(http.StatusCreated)
+	key := KeyReport{}
+	if err := recorded.DecodeJsonPayload(&key); err != nil {
+		t.Fatal(err)
+	}
+
+	flow := &CashFlowRequest{Occurred: time.Now(), Currency: "USD", Amount: "-1500.25", Description: "test"}
+	request("GET", "/v1/accounts/DU123/flows", key.Key, nil).CodeIs(http.StatusOK)
+	request("POST", "/v1/accounts/DU123/flows", key.Key, flow).CodeIs(http.StatusForbidden)
+	request("PUT", "/v1/accounts/DU123/concentration/threshold", key.Key, &ThresholdRequest{25}).CodeIs(http.StatusForbidden)
+	request("DELETE", "/v1/accounts/DU123/concentration/threshold", key.Key, nil).CodeIs(http.StatusForbidden)
+
+	request("POST", "/v1/accounts/DU123/flows", c.AdminKey, flow).CodeIs(http.StatusCreated)
+	request("PUT", "/v1/accounts/DU123/concentration/threshold", c.AdminKey, &ThresholdRequest{25}).CodeIs(http.StatusNoContent)
+	request("DELETE", "/v1/accounts/DU123/concentration/threshold", c.AdminKey, nil).CodeIs(http.StatusNoContent)
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+type CashFlowHandler struct {
+	db *sql.DB
+	u  *Util
+}
+
+// CashFlowRequest records a deposit (positive Amount) or withdrawal (negative
+// Amount) for an account. Amount is a decimal string, such as "-1500.25".
+type CashFlowRequest struct {
+	Occurred    time.Time
+	Currency    string
+	Amount      string
+	Description string
+}
+
+func (c *CashFlowHandler) GetAll(w rest.ResponseWriter, r *rest.Request) {
+	code := r.PathParam("accountCode")
+	account := new(core.Account)
+	err := meddler.QueryRow(c.db, account, "SELECT * FROM account WHERE account_code = $1", code)
+	if err != nil {
+		c.u.HandleError(err, w, r)
+		return
+	}
+
+	flows := []*core.CashFlowView{}
+	err = meddler.QueryAll(c.db, &flows, "SELECT * FROM v_cash_flow WHERE account_code = $1", code)
+	if err != nil {
+		c.u.HandleError(err, w, r)
+		return
+	}
+	w.Header().Add("Cache-Control", "private, max-age=60")
+	w.WriteJson(&flows)
+}
+
+func (c *CashFlowHandler) Post(w rest.ResponseWriter, r *rest.Request) {
+	code := r.PathParam("accountCode")
+	account := new(core.Account)
+	err := meddler.QueryRow(c.db, account, "SELECT * FROM account WHERE account_code = $1", code)
+	if err != nil {
+		c.u.HandleError(err, w, r)
+		return
+	}
+
+	req := CashFlowRequest{}
+	err = r.DecodeJsonPayload(&req)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Occurred.IsZero() || req.Amount == "" {
+		rest.Error(w, "Occurred and Amount are required", http.StatusBadRequest)
+		return
+	}
+
+	currencies, err := core.NewCurrencies(c.db)
+	if err != nil {
+		c.u.HandleError(err, w, r)
+		return
+	}
+	if _, ok := currencies.Find(req.Currency); !ok {
+		rest.Error(w, fmt.Sprintf("currency '%s' is unknown", req.Currency), http.StatusBadRequest)
+		return
+	}
+
+	amount, err := core.NewMonetary(c.db, req.Currency, req.Amount)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flow := &core.CashFlow{
+		AccountId:   account.Id,
+		Occurred:    req.Occurred.UTC(),
+		Amount:      amount,
+		Description: req.Description,
+	}
+	err = meddler.Insert(c.db, "cash_flow", flow)
+	if err != nil {
+		c.u.HandleError(err, w, r)
+		return
+	}
+
+	view := core.CashFlowView{
+		Id:          flow.Id,
+		AccountCode: code,
+		Occurred:    flow.Occurred,
+		Amount:      amount.Human(currencies),
+		Description: flow.Description,
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.WriteJson(&view)
+}
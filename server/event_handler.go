@@ -0,0 +1,239 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+// keepAlive is how often a comment is sent to idle event streams, so proxies
+// do not close them.
+const keepAlive = 30 * time.Second
+
+// eventQueue is the most snapshot notifications queued for a stream. Further
+// notifications are dropped rather than delaying the notifier (and so every
+// other subscriber), and the stream resyncs from the database once the client
+// catches up.
+const eventQueue = 64
+
+// snapshotSelect selects snapshotRows, followed by a WHERE condition.
+const snapshotSelect = "SELECT account_snapshot.id, account_code, created FROM " +
+	"account_snapshot, account WHERE account.id = account_id AND "
+
+type EventHandler struct {
+	db *sql.DB
+	n  core.Notifier
+	u  *Util
+}
+
+// SnapshotEvent is the data of a Server-Sent Event advising a new snapshot.
+// The event ID is the snapshot ID.
+type SnapshotEvent struct {
+	AccountCode string
+	Timestamp   string
+	Url         string
+}
+
+// snapshotRow is an account snapshot joined to its account code.
+type snapshotRow struct {
+	Id          int64     `meddler:"id,pk"`
+	AccountCode string    `meddler:"account_code"`
+	Created     time.Time `meddler:"created,utctime"`
+}
+
+// GetEvents streams a "snapshot" Server-Sent Event for every new snapshot of
+// the accounts in the accounts query parameter (or all accounts if absent) the
+// principal may access.
+// Snapshots committed after the snapshot ID in the Last-Event-ID header (or
+// lastEventId query parameter) are replayed before streaming begins.
+func (e *EventHandler) GetEvents(w rest.ResponseWriter, r *rest.Request) {
+	writer, ok := w.(http.ResponseWriter)
+	flusher, flushable := w.(http.Flusher)
+	closer, closable := w.(http.CloseNotifier)
+	if !ok || !flushable || !closable {
+		e.u.HandleError(fmt.Errorf("response writer does not support streaming"), w, r)
+		return
+	}
+
+	p := principal(r)
+	accounts := make(map[string]bool)
+	if v := r.URL.Query().Get("accounts"); v != "" {
+		for _, code := range strings.Split(v, ",") {
+			accounts[code] = true
+		}
+	}
+
+	lastId := int64(0)
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.URL.Query().Get("lastEventId")
+	}
+	if last != "" {
+		var err error
+		lastId, err = strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			rest.Error(w, fmt.Sprintf("Last-Event-ID '%s' is not a snapshot ID", last), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// subscribe before replaying, so no snapshot committed meanwhile is missed
+	notifications := make(chan *core.Notification)
+	e.n.Subscribe(notifications)
+	defer e.n.Unsubscribe(notifications)
+	done := make(chan struct{})
+	defer close(done)
+	queued, overflowed, stopped := queueSnapshots(notifications, done)
+
+	// lastSent is the highest snapshot ID considered for the stream, from
+	// which the stream resyncs if notifications are dropped
+	lastSent := lastId
+	var replay []*snapshotRow
+	if last != "" {
+		err := meddler.QueryAll(e.db, &replay, snapshotSelect+"account_snapshot.id > $1 "+
+			"ORDER BY account_snapshot.id", lastId)
+		if err != nil {
+			e.u.HandleError(err, w, r)
+			return
+		}
+	} else {
+		err := e.db.QueryRow("SELECT COALESCE(max(id), 0) FROM account_snapshot").Scan(&lastSent)
+		if err != nil {
+			e.u.HandleError(err, w, r)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(s *snapshotRow) error {
+		if s.Id > lastSent {
+			lastSent = s.Id
+		}
+		if (len(accounts) > 0 && !accounts[s.AccountCode]) || !p.Permits(s.AccountCode) {
+			return nil
+		}
+		auditAccounts(r, s.AccountCode)
+
+		ts := s.Created.Format(time.RFC3339Nano)
+		event := SnapshotEvent{
+			AccountCode: s.AccountCode,
+			Timestamp:   ts,
+			Url:         fmt.Sprintf("/v1/accounts/%s/%s", s.AccountCode, ts),
+		}
+		if ps := pseudonymiser(r); ps != nil {
+			event.AccountCode = ps.Token(event.AccountCode)
+			event.Url = ps.Url(event.Url)
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(writer, "id: %d\nevent: snapshot\ndata: %s\n\n", s.Id, data)
+		flusher.Flush()
+		return err
+	}
+
+	// replayed holds the snapshots sent from queries, which may also be
+	// queued
+	replayed := make(map[int64]bool)
+	for _, s := range replay {
+		replayed[s.Id] = true
+		if err := send(s); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	closed := closer.CloseNotify()
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-stopped:
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-overflowed:
+			var missed []*snapshotRow
+			err := meddler.QueryAll(e.db, &missed, snapshotSelect+"account_snapshot.id > $1 "+
+				"ORDER BY account_snapshot.id", lastSent)
+			if err != nil {
+				return
+			}
+			for _, s := range missed {
+				replayed[s.Id] = true
+				if err := send(s); err != nil {
+					return
+				}
+			}
+		case id := <-queued:
+			if replayed[id] {
+				continue
+			}
+
+			s := new(snapshotRow)
+			err := meddler.QueryRow(e.db, s, snapshotSelect+"account_snapshot.id = $1", id)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err == nil {
+				err = send(s)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// queueSnapshots drains the subscription until done, so the notifier never
+// waits on a slow client. The ID of each snapshot notification is sent on
+// queued, or if eventQueue IDs are already waiting, dropped with a signal on
+// overflowed. stopped is closed when the notifier closes the subscription.
+func queueSnapshots(notifications <-chan *core.Notification, done <-chan struct{}) (queued <-chan int64,
+	overflowed <-chan struct{}, stopped <-chan struct{}) {
+	q := make(chan int64, eventQueue)
+	o := make(chan struct{}, 1)
+	st := make(chan struct{})
+	go func() {
+		defer close(st)
+		for {
+			select {
+			case <-done:
+				return
+			case n, ok := <-notifications:
+				if !ok {
+					return
+				}
+				if n.Type != core.NtAccountSnapshot {
+					continue
+				}
+				select {
+				case q <- n.Id:
+				default:
+					select {
+					case o <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}
+	}()
+	return q, o, st
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/gateway"
+	"github.com/benalexau/ibconnect/migrate"
+	"github.com/russross/meddler"
+)
+
+// eventIds sends the ID of each event in the stream, closing the channel when
+// the stream ends.
+func eventIds(r *bufio.Reader) <-chan int64 {
+	ids := make(chan int64)
+	go func() {
+		defer close(ids)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(line, "id: ") {
+				id, err := strconv.ParseInt(strings.TrimSpace(line[4:]), 10, 64)
+				if err != nil {
+					return
+				}
+				ids <- id
+			}
+		}
+	}()
+	return ids
+}
+
+// nextEventId returns the next event ID, failing on timeout or stream end.
+func nextEventId(t *testing.T, ids <-chan int64) int64 {
+	select {
+	case id, ok := <-ids:
+		if !ok {
+			t.Fatal("event stream ended")
+		}
+		return id
+	case <-time.After(15 * time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	return 0
+}
+
+func TestEventHandlerReplaysAndStreams(t *testing.T) {
+	ctx, handler := NewTestHandler(t)
+	defer ctx.Close()
+
+	c := core.NewTestConfig(t)
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	accountCode := ""
+	latest := int64(0)
+	row := ctx.DB.QueryRow("SELECT account_code, account_snapshot.id FROM account, account_snapshot " +
+		"WHERE account.id = account_id ORDER BY account_snapshot.id DESC LIMIT 1")
+	if err := row.Scan(&accountCode, &latest); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v1/events?accounts=%s", server.URL, accountCode), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(latest-1, 10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %s", ct)
+	}
+
+	ids := eventIds(bufio.NewReader(resp.Body))
+	if id := nextEventId(t, ids); id != latest {
+		t.Fatalf("replayed snapshot %d (expected %d)", id, latest)
+	}
+
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+	if id := nextEventId(t, ids); id <= latest {
+		t.Fatalf("streamed snapshot %d should follow %d", id, latest)
+	}
+}
+
+// stalledWriter is the response of a client that never reads, so every write
+// blocks until the test releases it.
+type stalledWriter struct {
+	header  http.Header
+	release chan struct{}
+	closed  chan bool
+}
+
+func (w *stalledWriter) Header() http.Header { return w.header }
+func (w *stalledWriter) WriteHeader(int)     {}
+func (w *stalledWriter) Flush()              {}
+func (w *stalledWriter) CloseNotify() <-chan bool {
+	return w.closed
+}
+
+func (w *stalledWriter) Write(b []byte) (int, error) {
+	<-w.release
+	return len(b), nil
+}
+
+func (w *stalledWriter) WriteJson(v interface{}) error {
+	b, err := w.EncodeJson(v)
+	if err == nil {
+		_, err = w.Write(b)
+	}
+	return err
+}
+
+func (w *stalledWriter) EncodeJson(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func TestEventHandlerStalledClientDoesNotBlockNotifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ibconnect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a SQLite file and in-memory notifier suffice, as no feed runs
+	s := &core.SqliteStorage{Path: filepath.Join(dir, "ibc.db")}
+	db, err := s.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ms, err := migrate.Embedded(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrate.Up(db, ms); err != nil {
+		t.Fatal(err)
+	}
+	account, err := core.GetAccount(db, "DU123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := &core.AccountSnapshot{AccountId: account.Id, Created: time.Now().UTC()}
+	if err := meddler.Insert(db, "account_snapshot", snap); err != nil {
+		t.Fatal(err)
+	}
+
+	n := core.NewLocalNotifier()
+	defer n.Close()
+	if err := n.RegisterAll(core.NtTypes()); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &stalledWriter{header: make(http.Header), release: make(chan struct{}), closed: make(chan bool)}
+	req, err := http.NewRequest("GET", "http://1.2.3.4/v1/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &EventHandler{db: db, n: n, u: &Util{}}
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		e.GetEvents(w, &rest.Request{Request: req, Env: make(map[string]interface{})})
+	}()
+
+	// closing the notifier closes both subscriptions, ending the stream once
+	// released
+	nc := make(chan *core.Notification)
+	n.Subscribe(nc)
+	defer func() {
+		close(w.release)
+		n.Close()
+		<-finished
+	}()
+
+	// the stalled stream is sent the first snapshot, and must drop rather
+	// than hold up the rest
+	published := 10 * eventQueue
+	go func() {
+		for i := 0; i < published; i++ {
+			n.Publish(core.NtAccountSnapshot, snap.Id)
+		}
+	}()
+	for received := 0; received < published; received++ {
+		select {
+		case <-nc:
+		case <-time.After(15 * time.Second):
+			t.Fatalf("second subscriber blocked after %d of %d notifications", received, published)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/performance"
+	"github.com/benalexau/ibconnect/table"
+)
+
+const (
+	formatJson = "json"
+	formatCsv  = "csv"
+	formatXlsx = "xlsx"
+	xlsxType   = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// exportFormat returns the response format requested by the format query
+// parameter (json, csv or xlsx), otherwise by the Accept header, defaulting to
+// json. If false is returned an error has been written.
+func exportFormat(w rest.ResponseWriter, r *rest.Request) (string, bool) {
+	if v := r.URL.Query().Get("format"); v != "" {
+		switch v {
+		case formatJson, formatCsv, formatXlsx:
+			return v, true
+		}
+		rest.Error(w, fmt.Sprintf("format '%s' is not json, csv or xlsx", v), http.StatusBadRequest)
+		return "", false
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "text/csv"):
+		return formatCsv, true
+	case strings.Contains(accept, xlsxType):
+		return formatXlsx, true
+	}
+	return formatJson, true
+}
+
+// writeTables writes the tables as an attachment named by the filename (without
+// extension). CSV contains only the first table, whereas XLSX has a sheet per
+// table. Any AccountCode column (and any account code in the filename) is
+// pseudonymised if required.
+func writeTables(w rest.ResponseWriter, r *rest.Request, u *Util, format string, filename string, tables ...table.Table) {
+	if p := pseudonymiser(r); p != nil {
+		codes := strings.Split(r.URL.Query().Get("accounts"), ",")
+		for _, code := range append(codes, r.PathParam("accountCode")) {
+			if code != "" {
+				filename = strings.Replace(filename, code, p.Token(code), -1)
+			}
+		}
+		for _, t := range tables {
+			if c := t.Column("AccountCode"); c >= 0 {
+				for _, row := range t.Rows {
+					row[c] = p.Token(row[c])
+				}
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	var err error
+	contentType := "text/csv; charset=utf-8"
+	if format == formatXlsx {
+		contentType = xlsxType
+		err = table.WriteXLSX(&b, tables)
+	} else {
+		err = table.WriteCSV(&b, tables[0])
+	}
+	if err != nil {
+		u.HandleError(err, w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+	w.WriteHeader(http.StatusOK)
+	w.(http.ResponseWriter).Write(b.Bytes())
+}
+
+// filename returns a filename for an export, replacing characters that are
+// awkward in filenames (such as the colons of RFC 3339 timestamps).
+func filename(parts ...string) string {
+	return strings.NewReplacer(":", "", "/", "-", " ", "_").Replace(strings.Join(parts, "-"))
+}
+
+func accountsTable(accounts []*core.Account) table.Table {
+	t := table.Table{Name: "Accounts", Header: []string{"AccountCode"}}
+	for _, a := range accounts {
+		t.Append(a.AccountCode)
+	}
+	return t
+}
+
+func snapshotsTable(snapshots []*SnapshotSummary) table.Table {
+	t := table.Table{Name: "Snapshots", Header: []string{"Timestamp", "ValidUntil", "BaseCurrency"}}
+	for _, s := range snapshots {
+		t.Append(s.Timestamp, s.ValidUntil, s.BaseCurrency)
+	}
+	return t
+}
+
+// positionRow is a position and the timestamp of its snapshot.
+type positionRow struct {
+	Timestamp string
+	*core.AccountPositionView
+}
+
+func positionsTable(positions []positionRow) table.Table {
+	t := table.Table{Name: "Positions", Header: []string{"Timestamp", "IbContractId", "Symbol", "LocalSymbol",
+		"SecurityType", "Exchange", "Currency", "Position", "MarketPrice", "MarketValue", "AverageCost",
+		"UnrealizedPNL", "RealizedPNL"}}
+	for _, p := range positions {
+		t.Append(p.Timestamp, p.IbContractId, p.Symbol, p.LocalSymbol, p.SecurityType, p.Exchange, p.Currency,
+			p.Position, p.MarketPrice, p.MarketValue, p.AverageCost, p.UnrealizedPNL, p.RealizedPNL)
+	}
+	return t
+}
+
+// balanceRow is a balance and the timestamp of its snapshot.
+type balanceRow struct {
+	Timestamp string
+	*core.AccountAmountView
+}
+
+// balancesTable presents monetary amounts as numbers, with their currency in
+// the Currency column. Balance is the currency IB reported the balance in (or
+// BASE), which differs from Currency if the report was converted.
+func balancesTable(balances []balanceRow) table.Table {
+	t := table.Table{Name: "Balances", Header: []string{"Timestamp", "Balance", "Currency", "AccountType",
+		"Cushion", "LookAheadNextChange", "AccruedCash", "AvailableFunds", "BuyingPower", "EquityWithLoanValue",
+		"ExcessLiquidity", "FullAvailableFunds", "FullExcessLiquidity", "FullInitMarginReq", "FullMaintMarginReq",
+		"GrossPositionValue", "InitMarginReq", "LookAheadAvailableFunds", "LookAheadExcessLiquidity",
+		"LookAheadInitMarginReq", "LookAheadMaintMarginReq", "MaintMarginReq", "NetLiquidation",
+		"TotalCashBalance", "TotalCashValue"}}
+	for _, b := range balances {
+		currency, _ := amount(b.NetLiquidation)
+		if currency == "" || currency == "NIL" {
+			currency = b.Currency
+		}
+		row := []interface{}{b.Timestamp, balanceKey(*b.AccountAmountView), currency, b.AccountType, b.Cushion,
+			b.LookAheadNextChange}
+		for _, m := range []string{b.AccruedCash, b.AvailableFunds, b.BuyingPower, b.EquityWithLoanValue,
+			b.ExcessLiquidity, b.FullAvailableFunds, b.FullExcessLiquidity, b.FullInitMarginReq,
+			b.FullMaintMarginReq, b.GrossPositionValue, b.InitMarginReq, b.LookAheadAvailableFunds,
+			b.LookAheadExcessLiquidity, b.LookAheadInitMarginReq, b.LookAheadMaintMarginReq, b.MaintMarginReq,
+			b.NetLiquidation, b.TotalCashBalance, b.TotalCashValue} {
+			_, value := amount(m)
+			row = append(row, value)
+		}
+		t.Append(row...)
+	}
+	return t
+}
+
+// amount splits a monetary string (eg "AUD 62.69") into its currency and value.
+func amount(m string) (string, string) {
+	i := strings.Index(m, " ")
+	if i < 0 {
+		return "", m
+	}
+	return m[:i], m[i+1:]
+}
+
+func valuesTable(timestamp string, values []*core.AccountValueView) table.Table {
+	t := table.Table{Name: "Values", Header: []string{"Timestamp", "Key", "Currency", "Segment", "Value"}}
+	for _, v := range values {
+		t.Append(timestamp, v.Key, v.Currency, v.Segment, v.Value)
+	}
+	return t
+}
+
+// seriesEntry is a valuation or cash flow of a Series.
+type seriesEntry struct {
+	time  time.Time
+	value interface{}
+	flow  interface{}
+}
+
+type bySeriesTime []seriesEntry
+
+func (s bySeriesTime) Len() int           { return len(s) }
+func (s bySeriesTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s bySeriesTime) Less(i, j int) bool { return s[i].time.Before(s[j].time) }
+
+// seriesTable lists each valuation and cash flow of the series in time order.
+func seriesTable(s performance.Series) table.Table {
+	var entries []seriesEntry
+	for _, v := range s.Valuations {
+		entries = append(entries, seriesEntry{time: v.Time, value: v.Value, flow: ""})
+	}
+	for _, f := range s.Flows {
+		entries = append(entries, seriesEntry{time: f.Time, value: "", flow: f.Amount})
+	}
+	sort.Stable(bySeriesTime(entries))
+
+	t := table.Table{Name: "Series", Header: []string{"Time", "Currency", "Valuation", "Flow"}}
+	for _, e := range entries {
+		t.Append(e.time.Format(time.RFC3339), s.Currency, e.value, e.flow)
+	}
+	return t
+}
+
+func periodsTable(name string, periods []performance.Period) table.Table {
+	t := table.Table{Name: name, Header: []string{"Start", "End", "StartValue", "EndValue", "NetFlows", "TWR", "MWR"}}
+	for _, p := range periods {
+		t.Append(p.Start.Format(time.RFC3339), p.End.Format(time.RFC3339), p.StartValue, p.EndValue, p.NetFlows,
+			p.TWR, p.MWR)
+	}
+	return t
+}
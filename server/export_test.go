@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/performance"
+)
+
+func TestExportFormat(t *testing.T) {
+	cases := []struct {
+		url    string
+		accept string
+		format string
+		ok     bool
+	}{
+		{"http://1.2.3.4/v1/accounts", "", formatJson, true},
+		{"http://1.2.3.4/v1/accounts", "text/csv", formatCsv, true},
+		{"http://1.2.3.4/v1/accounts", xlsxType, formatXlsx, true},
+		{"http://1.2.3.4/v1/accounts?format=csv", "application/json", formatCsv, true},
+		{"http://1.2.3.4/v1/accounts?format=pdf", "", "", false},
+	}
+	for _, c := range cases {
+		req := test.MakeSimpleRequest("GET", c.url, nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+		recorder := httptest.NewRecorder()
+		format, ok := exportFormat(recorderWriter{recorder}, &rest.Request{Request: req})
+		if format != c.format || ok != c.ok {
+			t.Errorf("%s with Accept '%s' expected %s %v but got %s %v", c.url, c.accept, c.format, c.ok, format, ok)
+		}
+		if !ok && recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s expected status %d but got %d", c.url, http.StatusBadRequest, recorder.Code)
+		}
+	}
+}
+
+func TestFilename(t *testing.T) {
+	name := filename("U1", "2015-01-02T03:04:05.123Z")
+	if name != "U1-2015-01-02T030405.123Z" {
+		t.Fatalf("unexpected filename %s", name)
+	}
+}
+
+func TestBalancesTable(t *testing.T) {
+	balances := []balanceRow{
+		{"2015-01-02T03:04:05Z", &core.AccountAmountView{Base: true, Currency: "AUD", AccountType: "INDIVIDUAL",
+			NetLiquidation: "AUD 62.69", TotalCashValue: "AUD -1.5"}},
+		{"2015-01-02T03:04:05Z", &core.AccountAmountView{Currency: "USD", NetLiquidation: "USD 10"}},
+	}
+	tbl := balancesTable(balances)
+	if len(tbl.Rows) != 2 {
+		t.Fatalf("expected 2 rows but got %d", len(tbl.Rows))
+	}
+
+	row := tbl.Rows[0]
+	if row[tbl.Column("Balance")] != "BASE" || row[tbl.Column("Currency")] != "AUD" {
+		t.Fatalf("unexpected base row %v", row)
+	}
+	if row[tbl.Column("NetLiquidation")] != "62.69" || row[tbl.Column("TotalCashValue")] != "-1.5" {
+		t.Fatalf("amounts not numeric %v", row)
+	}
+	if row := tbl.Rows[1]; row[tbl.Column("Balance")] != "USD" || row[tbl.Column("NetLiquidation")] != "10" {
+		t.Fatalf("unexpected USD row %v", row)
+	}
+}
+
+func TestSeriesTable(t *testing.T) {
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := performance.Series{
+		Currency: "AUD",
+		Valuations: []performance.Valuation{
+			{Time: start, Value: 100},
+			{Time: start.Add(48 * time.Hour), Value: 160.5},
+		},
+		Flows: []performance.Flow{{Time: start.Add(24 * time.Hour), Amount: 50}},
+	}
+
+	expected := [][]string{
+		{"2015-01-01T00:00:00Z", "AUD", "100", ""},
+		{"2015-01-02T00:00:00Z", "AUD", "", "50"},
+		{"2015-01-03T00:00:00Z", "AUD", "160.5", ""},
+	}
+	if rows := seriesTable(s).Rows; !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("expected %v but got %v", expected, rows)
+	}
+}
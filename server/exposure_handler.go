@@ -0,0 +1,128 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/exposure"
+)
+
+type ExposureHandler struct {
+	db *sql.DB
+	u  *Util
+}
+
+// ExposureReport presents the exposure of the latest snapshot of one or more
+// accounts. When several accounts are requested they are aggregated.
+type ExposureReport struct {
+	AccountCodes []string
+	exposure.Report
+}
+
+func (e *ExposureHandler) GetAccount(w rest.ResponseWriter, r *rest.Request) {
+	e.report(w, r, []string{r.PathParam("accountCode")})
+}
+
+func (e *ExposureHandler) GetAggregate(w rest.ResponseWriter, r *rest.Request) {
+	codes := strings.Split(r.URL.Query().Get("accounts"), ",")
+	if codes[0] == "" {
+		rest.Error(w, "accounts parameter is required", http.StatusBadRequest)
+		return
+	}
+	if !permitted(w, r, codes) {
+		return
+	}
+	e.report(w, r, codes)
+}
+
+// ConcentrationReport presents the concentration of the latest snapshot of an
+// account or the accounts of a group.
+type ConcentrationReport struct {
+	AccountCodes []string
+	GroupName    string `json:",omitempty"`
+	exposure.Concentration
+}
+
+func (e *ExposureHandler) GetAccountConcentration(w rest.ResponseWriter, r *rest.Request) {
+	code := r.PathParam("accountCode")
+	threshold, err := exposure.AccountThreshold(e.db, code)
+	if err != nil {
+		e.u.HandleError(err, w, r)
+		return
+	}
+	e.concentration(w, r, ConcentrationReport{AccountCodes: []string{code}}, threshold)
+}
+
+func (e *ExposureHandler) GetGroupConcentration(w rest.ResponseWriter, r *rest.Request) {
+	name := r.PathParam("groupName")
+	codes, err := exposure.GroupAccountCodes(e.db, name)
+	if err != nil {
+		e.u.HandleError(err, w, r)
+		return
+	}
+	if len(codes) == 0 {
+		rest.Error(w, fmt.Sprintf("group '%s' has no accounts", name), http.StatusNotFound)
+		return
+	}
+	if !permitted(w, r, codes) {
+		return
+	}
+	auditAccounts(r, codes...)
+
+	threshold, err := exposure.GroupThreshold(e.db, name)
+	if err != nil {
+		e.u.HandleError(err, w, r)
+		return
+	}
+	e.concentration(w, r, ConcentrationReport{AccountCodes: codes, GroupName: name}, threshold)
+}
+
+// concentration completes the report, with a threshold query parameter taking
+// precedence over the configured threshold.
+func (e *ExposureHandler) concentration(w rest.ResponseWriter, r *rest.Request, report ConcentrationReport, threshold float64) {
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t <= 0 {
+			rest.Error(w, fmt.Sprintf("threshold '%s' is not a positive number", v), http.StatusBadRequest)
+			return
+		}
+		threshold = t
+	}
+
+	currency := r.URL.Query().Get("currency")
+	var err error
+	report.Concentration, err = exposure.LoadConcentration(e.db, report.AccountCodes, currency, threshold)
+	if err == exposure.ErrUnknownCurrency {
+		rest.Error(w, fmt.Sprintf("currency '%s' unavailable for this report", currency), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		e.u.HandleError(err, w, r)
+		return
+	}
+
+	w.Header().Add("Cache-Control", "private, max-age=60")
+	w.WriteJson(&report)
+}
+
+func (e *ExposureHandler) report(w rest.ResponseWriter, r *rest.Request, codes []string) {
+	currency := r.URL.Query().Get("currency")
+	report := ExposureReport{AccountCodes: codes}
+	var err error
+	report.Report, err = exposure.LoadReport(e.db, codes, currency)
+	if err == exposure.ErrUnknownCurrency {
+		rest.Error(w, fmt.Sprintf("currency '%s' unavailable for this report", currency), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		e.u.HandleError(err, w, r)
+		return
+	}
+
+	w.Header().Add("Cache-Control", "private, max-age=60")
+	w.WriteJson(&report)
+}
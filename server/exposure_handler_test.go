@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/gateway"
+)
+
+func TestExposureHandlerGetAccount(t *testing.T) {
+	ctx, handler := NewTestHandler(t)
+	defer ctx.Close()
+
+	c := core.NewTestConfig(t)
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	accountCode := ""
+	row := ctx.DB.QueryRow("SELECT account_code FROM account LIMIT 1")
+	if err := row.Scan(&accountCode); err != nil {
+		t.Fatal(err)
+	}
+
+	url := fmt.Sprintf("http://1.2.3.4/v1/accounts/%s/exposure", accountCode)
+	recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusOK)
+	recorded.ContentTypeIsJson()
+	recorded.HeaderIs("Cache-Control", "private, max-age=60")
+
+	report := ExposureReport{}
+	if err := recorded.DecodeJsonPayload(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Currency == "" || report.NetLiquidation == 0 {
+		t.Fatalf("unexpected report %v", report)
+	}
+
+	url = fmt.Sprintf("http://1.2.3.4/v1/exposure?accounts=%s&currency=XYZ", accountCode)
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusBadRequest)
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://1.2.3.4/v1/exposure", nil))
+	recorded.CodeIs(http.StatusBadRequest)
+}
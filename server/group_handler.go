@@ -0,0 +1,209 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/exposure"
+	"github.com/russross/meddler"
+)
+
+type GroupHandler struct {
+	db *sql.DB
+	u  *Util
+}
+
+// GroupRequest sets the accounts of a group.
+type GroupRequest struct {
+	AccountCodes []string
+}
+
+// ThresholdRequest sets a concentration threshold, being a percentage of
+// NetLiquidation.
+type ThresholdRequest struct {
+	Threshold float64
+}
+
+func (g *GroupHandler) GetAll(w rest.ResponseWriter, r *rest.Request) {
+	var groups []*core.AccountGroup
+	err := meddler.QueryAll(g.db, &groups, "SELECT * FROM account_group ORDER BY group_name")
+	if err != nil {
+		g.u.HandleError(err, w, r)
+		return
+	}
+
+	names := []string{}
+	for _, group := range groups {
+		names = append(names, group.GroupName)
+	}
+	w.WriteJson(&names)
+}
+
+func (g *GroupHandler) Get(w rest.ResponseWriter, r *rest.Request) {
+	codes, err := exposure.GroupAccountCodes(g.db, r.PathParam("groupName"))
+	if err != nil {
+		g.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteJson(&GroupRequest{AccountCodes: codes})
+}
+
+// Put creates the group if required and replaces its accounts.
+func (g *GroupHandler) Put(w rest.ResponseWriter, r *rest.Request) {
+	req := GroupRequest{}
+	err := r.DecodeJsonPayload(&req)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := g.db.Begin()
+	if err != nil {
+		g.u.HandleError(err, w, r)
+		return
+	}
+	defer tx.Rollback()
+
+	group := new(core.AccountGroup)
+	err = meddler.QueryRow(tx, group, "SELECT * FROM account_group WHERE group_name = $1", r.PathParam("groupName"))
+	if err == sql.ErrNoRows {
+		group.GroupName = r.PathParam("groupName")
+		err = meddler.Insert(tx, "account_group", group)
+	}
+	if err != nil {
+		g.u.HandleError(err, w, r)
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM account_group_member WHERE account_group_id = $1", group.Id)
+	if err != nil {
+		g.u.HandleError(err, w, r)
+		return
+	}
+
+	added := make(map[string]bool)
+	for _, code := range req.AccountCodes {
+		if added[code] {
+			continue
+		}
+		added[code] = true
+
+		account := new(core.Account)
+		err = meddler.QueryRow(tx, account, "SELECT * FROM account WHERE account_code = $1", code)
+		if err == sql.ErrNoRows {
+			rest.Error(w, fmt.Sprintf("account '%s' is unknown", code), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			g.u.HandleError(err, w, r)
+			return
+		}
+
+		member := &core.AccountGroupMember{AccountGroupId: group.Id, AccountId: account.Id}
+		err = meddler.Insert(tx, "account_group_member", member)
+		if err != nil {
+			g.u.HandleError(err, w, r)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		g.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *GroupHandler) Delete(w rest.ResponseWriter, r *rest.Request) {
+	res, err := g.db.Exec("DELETE FROM account_group WHERE group_name = $1", r.PathParam("groupName"))
+	if err == nil {
+		err = noRowsIfUnaffected(res)
+	}
+	if err != nil {
+		g.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *GroupHandler) PutAccountThreshold(w rest.ResponseWriter, r *rest.Request) {
+	account := new(core.Account)
+	err := meddler.QueryRow(g.db, account, "SELECT * FROM account WHERE account_code = $1", r.PathParam("accountCode"))
+	if err != nil {
+		g.u.HandleError(err, w, r)
+		return
+	}
+	g.putThreshold(w, r, &core.ConcentrationThreshold{AccountId: account.Id})
+}
+
+func (g *GroupHandler) DeleteAccountThreshold(w rest.ResponseWriter, r *rest.Request) {
+	res, err := g.db.Exec("DELETE FROM concentration_threshold WHERE account_id = "+
+		"(SELECT id FROM account WHERE account_code = $1)", r.PathParam("accountCode"))
+	if err == nil {
+		err = noRowsIfUnaffected(res)
+	}
+	if err != nil {
+		g.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *GroupHandler) PutGroupThreshold(w rest.ResponseWriter, r *rest.Request) {
+	group := new(core.AccountGroup)
+	err := meddler.QueryRow(g.db, group, "SELECT * FROM account_group WHERE group_name = $1", r.PathParam("groupName"))
+	if err != nil {
+		g.u.HandleError(err, w, r)
+		return
+	}
+	g.putThreshold(w, r, &core.ConcentrationThreshold{AccountGroupId: group.Id})
+}
+
+// putThreshold saves the threshold in the request for the account or group of
+// the passed ConcentrationThreshold, replacing any existing threshold.
+func (g *GroupHandler) putThreshold(w rest.ResponseWriter, r *rest.Request, t *core.ConcentrationThreshold) {
+	req := ThresholdRequest{}
+	err := r.DecodeJsonPayload(&req)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Threshold <= 0 {
+		rest.Error(w, "Threshold must be positive", http.StatusBadRequest)
+		return
+	}
+
+	existing := new(core.ConcentrationThreshold)
+	err = meddler.QueryRow(g.db, existing, "SELECT * FROM concentration_threshold WHERE account_id = $1 OR "+
+		"account_group_id = $2", t.AccountId, t.AccountGroupId)
+	if err == nil {
+		t = existing
+	} else if err != sql.ErrNoRows {
+		g.u.HandleError(err, w, r)
+		return
+	}
+
+	t.Threshold = req.Threshold
+	err = meddler.Save(g.db, "concentration_threshold", t)
+	if err != nil {
+		g.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// noRowsIfUnaffected returns sql.ErrNoRows if the statement changed no rows.
+func noRowsIfUnaffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/exposure"
+	"github.com/benalexau/ibconnect/gateway"
+)
+
+func TestGroupHandlerConcentration(t *testing.T) {
+	ctx, handler := NewTestHandler(t)
+	defer ctx.Close()
+
+	c := core.NewTestConfig(t)
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	accountCode := ""
+	row := ctx.DB.QueryRow("SELECT account_code FROM account LIMIT 1")
+	if err := row.Scan(&accountCode); err != nil {
+		t.Fatal(err)
+	}
+
+	group := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	url := fmt.Sprintf("http://1.2.3.4/v1/groups/%s", group)
+	req := GroupRequest{AccountCodes: []string{accountCode, accountCode}}
+	recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", url, &req))
+	recorded.CodeIs(http.StatusNoContent)
+	defer test.RunRequest(t, handler, test.MakeSimpleRequest("DELETE", url, nil))
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusOK)
+	members := GroupRequest{}
+	if err := recorded.DecodeJsonPayload(&members); err != nil {
+		t.Fatal(err)
+	}
+	if len(members.AccountCodes) != 1 || members.AccountCodes[0] != accountCode {
+		t.Fatalf("unexpected members %v", members.AccountCodes)
+	}
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", url+"/concentration/threshold", &ThresholdRequest{25}))
+	recorded.CodeIs(http.StatusNoContent)
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url+"/concentration", nil))
+	recorded.CodeIs(http.StatusOK)
+	recorded.ContentTypeIsJson()
+	report := ConcentrationReport{}
+	if err := recorded.DecodeJsonPayload(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.GroupName != group || report.Threshold != 25 {
+		t.Fatalf("unexpected report %v", report)
+	}
+
+	url = fmt.Sprintf("http://1.2.3.4/v1/accounts/%s/concentration", accountCode)
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url+"?threshold=5", nil))
+	recorded.CodeIs(http.StatusOK)
+	if err := recorded.DecodeJsonPayload(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Threshold != 5 {
+		t.Fatalf("threshold parameter ignored (was %f)", report.Threshold)
+	}
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url+"?threshold=-1", nil))
+	recorded.CodeIs(http.StatusBadRequest)
+
+	threshold, err := exposure.AccountThreshold(ctx.DB, accountCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold > 25 {
+		t.Fatalf("account threshold %f should be no more than its group's", threshold)
+	}
+
+	req.AccountCodes = []string{"NOSUCHACCOUNT"}
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", fmt.Sprintf("http://1.2.3.4/v1/groups/%s", group), &req))
+	recorded.CodeIs(http.StatusBadRequest)
+}
@@ -10,13 +10,26 @@ import (
 	"github.com/benalexau/ibconnect/core"
 )
 
-// Handler returns an initialised Handler.
-func Handler(errInfo bool, db *sql.DB, n *core.Notifier) http.Handler {
+// Handler returns an initialised Handler. If an AdminKey or TlsClientCa is
+// configured every request must be authenticated by an API key or client
+// certificate.
+func Handler(c core.Config, db *sql.DB, n core.Notifier) http.Handler {
 	u := &Util{
-		ErrInfo: errInfo,
+		ErrInfo: c.ErrInfo,
 	}
 
 	accountHandler := AccountHandler{u: u, db: db, n: n}
+	cashFlowHandler := CashFlowHandler{u: u, db: db}
+	performanceHandler := PerformanceHandler{u: u, db: db}
+	exposureHandler := ExposureHandler{u: u, db: db}
+	groupHandler := GroupHandler{u: u, db: db}
+	alertHandler := AlertHandler{u: u, db: db}
+	eventHandler := EventHandler{u: u, db: db, n: n}
+	webhookHandler := WebhookHandler{u: u, db: db}
+	keyHandler := KeyHandler{u: u, db: db}
+	auditHandler := AuditHandler{u: u, db: db}
+	auditor := &Auditor{u: u, db: db}
+	pseudonymiser := NewPseudonymiser(db, u, c.PseudonymKey, c.PseudonymRoutes)
 	null, _ := os.Open(os.DevNull)
 
 	handler := rest.ResourceHandler{
@@ -29,11 +42,56 @@ func Handler(errInfo bool, db *sql.DB, n *core.Notifier) http.Handler {
 		Logger:                   log.New(null, "", 0),
 	}
 
+	if c.AdminKey != "" || c.TlsClientCa != "" {
+		handler.PreRoutingMiddlewares = []rest.Middleware{&AuthMiddleware{db: db, u: u, AdminKey: c.AdminKey}}
+	}
+
 	var routes []*rest.Route
 
 	routes = append(routes, &rest.Route{"GET", "/v1/accounts", accountHandler.GetAll})
-	routes = append(routes, &rest.Route{"GET", "/v1/accounts/:accountCode", accountHandler.GetLatest})
-	routes = append(routes, &rest.Route{"GET", "/v1/accounts/:accountCode/*timestamp", accountHandler.GetReport})
+	routes = append(routes, &rest.Route{"GET", "/v1/accounts/:accountCode", requireAccount(accountHandler.GetLatest)})
+	routes = append(routes, &rest.Route{"GET", "/v1/accounts/:accountCode/flows", requireAccount(cashFlowHandler.GetAll)})
+	routes = append(routes, &rest.Route{"POST", "/v1/accounts/:accountCode/flows", requireAdmin(cashFlowHandler.Post)})
+	routes = append(routes, &rest.Route{"GET", "/v1/accounts/:accountCode/concentration", requireAccount(exposureHandler.GetAccountConcentration)})
+	routes = append(routes, &rest.Route{"PUT", "/v1/accounts/:accountCode/concentration/threshold", requireAdmin(groupHandler.PutAccountThreshold)})
+	routes = append(routes, &rest.Route{"DELETE", "/v1/accounts/:accountCode/concentration/threshold", requireAdmin(groupHandler.DeleteAccountThreshold)})
+	routes = append(routes, &rest.Route{"GET", "/v1/accounts/:accountCode/exposure", requireAccount(exposureHandler.GetAccount)})
+	routes = append(routes, &rest.Route{"GET", "/v1/accounts/:accountCode/performance", requireAccount(performanceHandler.GetAccount)})
+	routes = append(routes, &rest.Route{"GET", "/v1/accounts/:accountCode/risk", requireAccount(performanceHandler.GetRisk)})
+	routes = append(routes, &rest.Route{"GET", "/v1/accounts/:accountCode/snapshots", requireAccount(accountHandler.GetSnapshots)})
+	routes = append(routes, &rest.Route{"GET", "/v1/accounts/:accountCode/*timestamp", requireAccount(accountHandler.GetReport)})
+	routes = append(routes, &rest.Route{"GET", "/v1/alerts", alertHandler.GetAlerts})
+	routes = append(routes, &rest.Route{"GET", "/v1/alerts/rules", requireAdmin(alertHandler.GetRules)})
+	routes = append(routes, &rest.Route{"POST", "/v1/alerts/rules", requireAdmin(alertHandler.PostRule)})
+	routes = append(routes, &rest.Route{"GET", "/v1/alerts/rules/:ruleId", requireAdmin(alertHandler.GetRule)})
+	routes = append(routes, &rest.Route{"PUT", "/v1/alerts/rules/:ruleId", requireAdmin(alertHandler.PutRule)})
+	routes = append(routes, &rest.Route{"DELETE", "/v1/alerts/rules/:ruleId", requireAdmin(alertHandler.DeleteRule)})
+	routes = append(routes, &rest.Route{"GET", "/v1/audit", requireAdmin(auditHandler.GetAll)})
+	routes = append(routes, &rest.Route{"GET", "/v1/events", eventHandler.GetEvents})
+	routes = append(routes, &rest.Route{"GET", "/v1/exposure", exposureHandler.GetAggregate})
+	routes = append(routes, &rest.Route{"GET", "/v1/groups", requireAdmin(groupHandler.GetAll)})
+	routes = append(routes, &rest.Route{"GET", "/v1/groups/:groupName", requireAdmin(groupHandler.Get)})
+	routes = append(routes, &rest.Route{"PUT", "/v1/groups/:groupName", requireAdmin(groupHandler.Put)})
+	routes = append(routes, &rest.Route{"DELETE", "/v1/groups/:groupName", requireAdmin(groupHandler.Delete)})
+	routes = append(routes, &rest.Route{"GET", "/v1/groups/:groupName/concentration", exposureHandler.GetGroupConcentration})
+	routes = append(routes, &rest.Route{"PUT", "/v1/groups/:groupName/concentration/threshold", requireAdmin(groupHandler.PutGroupThreshold)})
+	routes = append(routes, &rest.Route{"GET", "/v1/keys", requireAdmin(keyHandler.GetAll)})
+	routes = append(routes, &rest.Route{"POST", "/v1/keys", requireAdmin(keyHandler.Post)})
+	routes = append(routes, &rest.Route{"GET", "/v1/keys/:keyId", requireAdmin(keyHandler.Get)})
+	routes = append(routes, &rest.Route{"DELETE", "/v1/keys/:keyId", requireAdmin(keyHandler.Delete)})
+	routes = append(routes, &rest.Route{"GET", "/v1/performance", performanceHandler.GetAggregate})
+	routes = append(routes, &rest.Route{"GET", "/v1/webhooks", requireAdmin(webhookHandler.GetAll)})
+	routes = append(routes, &rest.Route{"POST", "/v1/webhooks", requireAdmin(webhookHandler.Post)})
+	routes = append(routes, &rest.Route{"GET", "/v1/webhooks/:webhookId", requireAdmin(webhookHandler.Get)})
+	routes = append(routes, &rest.Route{"DELETE", "/v1/webhooks/:webhookId", requireAdmin(webhookHandler.Delete)})
+	routes = append(routes, &rest.Route{"GET", "/v1/webhooks/:webhookId/deliveries", requireAdmin(webhookHandler.GetDeliveries)})
+
+	for _, route := range routes {
+		route.Func = pseudonymiser.Wrap(route.Func)
+		if audited(route.PathExp) {
+			route.Func = auditor.Wrap(route)
+		}
+	}
 
 	handler.SetRoutes(routes...)
 
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+type KeyHandler struct {
+	db *sql.DB
+	u  *Util
+}
+
+// KeyRequest creates an API key. A non-admin key may only access the accounts
+// listed in AccountCodes and the accounts of the groups in GroupNames. If
+// CertSubject is given, a client certificate with that subject common name
+// also authenticates as the key. If Pseudonymise is true, responses to the key
+// replace account codes with tokens.
+type KeyRequest struct {
+	Name         string
+	Admin        bool
+	AccountCodes []string
+	GroupNames   []string
+	CertSubject  string
+	Pseudonymise bool
+}
+
+// KeyReport presents an API key and its scopes. Key is only reported when the
+// key is created.
+type KeyReport struct {
+	core.ApiKey
+	Key          string `json:",omitempty"`
+	AccountCodes []string
+	GroupNames   []string
+}
+
+func (k *KeyHandler) GetAll(w rest.ResponseWriter, r *rest.Request) {
+	var keys []*core.ApiKey
+	err := meddler.QueryAll(k.db, &keys, "SELECT * FROM api_key ORDER BY id")
+	if err != nil {
+		k.u.HandleError(err, w, r)
+		return
+	}
+
+	reports := []*KeyReport{}
+	for _, key := range keys {
+		report, err := k.report(key)
+		if err != nil {
+			k.u.HandleError(err, w, r)
+			return
+		}
+		reports = append(reports, report)
+	}
+	w.WriteJson(&reports)
+}
+
+func (k *KeyHandler) Get(w rest.ResponseWriter, r *rest.Request) {
+	key, ok := k.load(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := k.report(key)
+	if err != nil {
+		k.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteJson(report)
+}
+
+// Post creates an API key, returning the key. The key itself is not stored and
+// cannot be retrieved again.
+func (k *KeyHandler) Post(w rest.ResponseWriter, r *rest.Request) {
+	req := KeyRequest{}
+	err := r.DecodeJsonPayload(&req)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		rest.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		k.u.HandleError(err, w, r)
+		return
+	}
+	plain := hex.EncodeToString(b)
+
+	tx, err := k.db.Begin()
+	if err != nil {
+		k.u.HandleError(err, w, r)
+		return
+	}
+	defer tx.Rollback()
+
+	if req.CertSubject != "" {
+		existing := new(core.ApiKey)
+		err = meddler.QueryRow(tx, existing, "SELECT * FROM api_key WHERE cert_subject = $1", req.CertSubject)
+		if err == nil {
+			rest.Error(w, fmt.Sprintf("CertSubject '%s' already used by key %d", req.CertSubject, existing.Id), http.StatusBadRequest)
+			return
+		}
+		if err != sql.ErrNoRows {
+			k.u.HandleError(err, w, r)
+			return
+		}
+	}
+
+	key := &core.ApiKey{Created: time.Now().UTC(), KeyName: req.Name, KeyHash: hashKey(plain), Admin: req.Admin,
+		CertSubject: req.CertSubject, Pseudonymise: req.Pseudonymise}
+	err = meddler.Insert(tx, "api_key", key)
+	if err != nil {
+		k.u.HandleError(err, w, r)
+		return
+	}
+
+	for _, code := range req.AccountCodes {
+		account := new(core.Account)
+		err = meddler.QueryRow(tx, account, "SELECT * FROM account WHERE account_code = $1", code)
+		if err == sql.ErrNoRows {
+			rest.Error(w, fmt.Sprintf("account '%s' not found", code), http.StatusBadRequest)
+			return
+		}
+		if err == nil {
+			err = meddler.Insert(tx, "api_key_scope", &core.ApiKeyScope{ApiKeyId: key.Id, AccountId: account.Id})
+		}
+		if err != nil {
+			k.u.HandleError(err, w, r)
+			return
+		}
+	}
+
+	for _, name := range req.GroupNames {
+		group := new(core.AccountGroup)
+		err = meddler.QueryRow(tx, group, "SELECT * FROM account_group WHERE group_name = $1", name)
+		if err == sql.ErrNoRows {
+			rest.Error(w, fmt.Sprintf("group '%s' not found", name), http.StatusBadRequest)
+			return
+		}
+		if err == nil {
+			err = meddler.Insert(tx, "api_key_scope", &core.ApiKeyScope{ApiKeyId: key.Id, AccountGroupId: group.Id})
+		}
+		if err != nil {
+			k.u.HandleError(err, w, r)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		k.u.HandleError(err, w, r)
+		return
+	}
+
+	report, err := k.report(key)
+	if err != nil {
+		k.u.HandleError(err, w, r)
+		return
+	}
+	report.Key = plain
+	w.WriteHeader(http.StatusCreated)
+	w.WriteJson(report)
+}
+
+func (k *KeyHandler) Delete(w rest.ResponseWriter, r *rest.Request) {
+	key, ok := k.load(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := k.db.Exec("DELETE FROM api_key WHERE id = $1", key.Id)
+	if err != nil {
+		k.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// report returns the key with the account codes and group names it is scoped to.
+func (k *KeyHandler) report(key *core.ApiKey) (*KeyReport, error) {
+	var scopes []*core.ApiKeyScopeView
+	err := meddler.QueryAll(k.db, &scopes, "SELECT * FROM v_api_key_scope WHERE api_key_id = $1", key.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &KeyReport{ApiKey: *key, AccountCodes: []string{}, GroupNames: []string{}}
+	for _, s := range scopes {
+		if s.AccountCode != "" {
+			report.AccountCodes = append(report.AccountCodes, s.AccountCode)
+		}
+		if s.GroupName != "" {
+			report.GroupNames = append(report.GroupNames, s.GroupName)
+		}
+	}
+	return report, nil
+}
+
+// load returns the key identified by the keyId path parameter. If false is
+// returned an error has been written.
+func (k *KeyHandler) load(w rest.ResponseWriter, r *rest.Request) (*core.ApiKey, bool) {
+	id, err := strconv.ParseInt(r.PathParam("keyId"), 10, 64)
+	if err != nil {
+		rest.NotFound(w, r)
+		return nil, false
+	}
+
+	key := new(core.ApiKey)
+	err = meddler.Load(k.db, "api_key", key, id)
+	if err != nil {
+		k.u.HandleError(err, w, r)
+		return nil, false
+	}
+	return key, true
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/performance"
+	"github.com/russross/meddler"
+)
+
+type PerformanceHandler struct {
+	db *sql.DB
+	u  *Util
+}
+
+// PerformanceReport presents the returns of one or more accounts. When several
+// accounts are requested they are aggregated as a single portfolio.
+type PerformanceReport struct {
+	AccountCodes []string
+	performance.Report
+}
+
+// RiskReport presents the risk statistics of an account.
+type RiskReport struct {
+	AccountCodes []string
+	performance.Risk
+}
+
+func (p *PerformanceHandler) GetAccount(w rest.ResponseWriter, r *rest.Request) {
+	p.report(w, r, []string{r.PathParam("accountCode")})
+}
+
+func (p *PerformanceHandler) GetAggregate(w rest.ResponseWriter, r *rest.Request) {
+	codes := strings.Split(r.URL.Query().Get("accounts"), ",")
+	if codes[0] == "" {
+		rest.Error(w, "accounts parameter is required", http.StatusBadRequest)
+		return
+	}
+	if !permitted(w, r, codes) {
+		return
+	}
+	p.report(w, r, codes)
+}
+
+func (p *PerformanceHandler) GetRisk(w rest.ResponseWriter, r *rest.Request) {
+	frequency := performance.Monthly
+	if v := r.URL.Query().Get("frequency"); v != "" {
+		f, err := performance.NewFrequency(v)
+		if err != nil {
+			rest.Error(w, fmt.Sprintf("frequency '%s' is not daily, weekly or monthly", v), http.StatusBadRequest)
+			return
+		}
+		frequency = f
+	}
+
+	riskFreeRate := 0.0
+	if v := r.URL.Query().Get("riskFreeRate"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			rest.Error(w, fmt.Sprintf("riskFreeRate '%s' is not a number", v), http.StatusBadRequest)
+			return
+		}
+		riskFreeRate = rate
+	}
+
+	codes := []string{r.PathParam("accountCode")}
+	s, ok := p.series(w, r, codes)
+	if !ok {
+		return
+	}
+
+	report := RiskReport{AccountCodes: codes}
+	var err error
+	report.Risk, err = performance.NewRisk(s, frequency, riskFreeRate)
+	if err == performance.ErrInsufficientData {
+		rest.Error(w, fmt.Sprintf("at least two %s periods are required", frequency), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		p.u.HandleError(err, w, r)
+		return
+	}
+
+	w.Header().Add("Cache-Control", "private, max-age=60")
+	w.WriteJson(&report)
+}
+
+// report writes the returns of the accounts as JSON, or their valuations and
+// flows as CSV. An XLSX workbook adds sheets of the monthly and annual returns.
+func (p *PerformanceHandler) report(w rest.ResponseWriter, r *rest.Request, codes []string) {
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	s, ok := p.series(w, r, codes)
+	if !ok {
+		return
+	}
+
+	report := PerformanceReport{AccountCodes: codes}
+	var err error
+	report.Report, err = performance.NewReport(s)
+	if err == performance.ErrInsufficientData {
+		rest.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		p.u.HandleError(err, w, r)
+		return
+	}
+
+	w.Header().Add("Cache-Control", "private, max-age=60")
+	switch format {
+	case formatJson:
+		w.WriteJson(&report)
+	case formatCsv:
+		writeTables(w, r, p.u, format, filename(strings.Join(codes, "_"), "performance"), seriesTable(s))
+	case formatXlsx:
+		writeTables(w, r, p.u, format, filename(strings.Join(codes, "_"), "performance"), seriesTable(s),
+			periodsTable("Monthly", report.Monthly), periodsTable("Annual", report.Annual))
+	}
+}
+
+// series loads the aggregated Series of the accounts for the time range and
+// currency in the request. If false is returned an error has been written.
+func (p *PerformanceHandler) series(w rest.ResponseWriter, r *rest.Request, codes []string) (performance.Series, bool) {
+	from, to, err := timeRange(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return performance.Series{}, false
+	}
+
+	// aggregated accounts must share a currency, so default to the first's
+	currency := r.URL.Query().Get("currency")
+	var series []performance.Series
+	for _, code := range codes {
+		account := new(core.Account)
+		err = meddler.QueryRow(p.db, account, "SELECT * FROM account WHERE account_code = $1", code)
+		if err != nil {
+			p.u.HandleError(err, w, r)
+			return performance.Series{}, false
+		}
+
+		s, err := performance.LoadSeries(p.db, account.Id, from, to, currency)
+		if err == performance.ErrUnknownCurrency {
+			rest.Error(w, fmt.Sprintf("currency '%s' is unknown", currency), http.StatusBadRequest)
+			return s, false
+		}
+		if err == performance.ErrInsufficientData {
+			rest.Error(w, err.Error(), http.StatusNotFound)
+			return s, false
+		}
+		if err != nil {
+			p.u.HandleError(err, w, r)
+			return s, false
+		}
+		currency = s.Currency
+		series = append(series, s)
+	}
+
+	agg, err := performance.Aggregate(series)
+	if err != nil {
+		p.u.HandleError(err, w, r)
+		return agg, false
+	}
+	return agg, true
+}
+
+// timeRange parses the optional RFC 3339 from and to query parameters. They
+// default to the beginning of time and now respectively.
+func timeRange(r *rest.Request) (time.Time, time.Time, error) {
+	from := time.Time{}
+	to := time.Now().UTC()
+
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		from, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return from, to, fmt.Errorf("from parameter '%s' is not RFC 3339", v)
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		to, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return from, to, fmt.Errorf("to parameter '%s' is not RFC 3339", v)
+		}
+	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("to parameter must not be before from parameter")
+	}
+	return from, to, nil
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/gateway"
+)
+
+func TestCashFlowHandlerPost(t *testing.T) {
+	ctx, handler := NewTestHandler(t)
+	defer ctx.Close()
+
+	c := core.NewTestConfig(t)
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	accountCode := ""
+	row := ctx.DB.QueryRow("SELECT account_code FROM account LIMIT 1")
+	if err := row.Scan(&accountCode); err != nil {
+		t.Fatal(err)
+	}
+
+	url := fmt.Sprintf("http://1.2.3.4/v1/accounts/%s/flows", accountCode)
+	req := CashFlowRequest{Occurred: time.Now(), Currency: "USD", Amount: "-1500.25", Description: "test"}
+	recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", url, &req))
+	recorded.CodeIs(http.StatusCreated)
+
+	flow := core.CashFlowView{}
+	if err := recorded.DecodeJsonPayload(&flow); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.DB.Exec("DELETE FROM cash_flow WHERE id = $1", flow.Id)
+	if flow.Amount != "USD -1500.25" {
+		t.Fatalf("unexpected amount %s", flow.Amount)
+	}
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusOK)
+	recorded.ContentTypeIsJson()
+
+	req.Currency = "XYZ"
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("POST", url, &req))
+	recorded.CodeIs(http.StatusBadRequest)
+}
+
+func TestPerformanceHandlerGetAccount(t *testing.T) {
+	ctx, handler := NewTestHandler(t)
+	defer ctx.Close()
+
+	// two feeds ensure at least two snapshots
+	c := core.NewTestConfig(t)
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	accountCode := ""
+	row := ctx.DB.QueryRow("SELECT account_code FROM account LIMIT 1")
+	if err := row.Scan(&accountCode); err != nil {
+		t.Fatal(err)
+	}
+
+	url := fmt.Sprintf("http://1.2.3.4/v1/accounts/%s/performance", accountCode)
+	recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusOK)
+	recorded.ContentTypeIsJson()
+	recorded.HeaderIs("Cache-Control", "private, max-age=60")
+
+	report := PerformanceReport{}
+	if err := recorded.DecodeJsonPayload(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Currency == "" || report.Total.StartValue == 0 {
+		t.Fatalf("unexpected report %v", report)
+	}
+
+	url = fmt.Sprintf("http://1.2.3.4/v1/performance?accounts=%s", accountCode)
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusOK)
+
+	url = fmt.Sprintf("http://1.2.3.4/v1/accounts/%s/performance?format=csv", accountCode)
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusOK)
+	recorded.HeaderIs("Content-Type", "text/csv; charset=utf-8")
+	if !strings.HasPrefix(recorded.Recorder.Body.String(), "Time,Currency,Valuation,Flow\n") {
+		t.Fatalf("unexpected CSV %s", recorded.Recorder.Body.String())
+	}
+
+	url = fmt.Sprintf("http://1.2.3.4/v1/accounts/%s/performance?from=yesterday", accountCode)
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusBadRequest)
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://1.2.3.4/v1/performance", nil))
+	recorded.CodeIs(http.StatusBadRequest)
+}
+
+func TestPerformanceHandlerGetRisk(t *testing.T) {
+	ctx, handler := NewTestHandler(t)
+	defer ctx.Close()
+
+	c := core.NewTestConfig(t)
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	accountCode := ""
+	row := ctx.DB.QueryRow("SELECT account_code FROM account LIMIT 1")
+	if err := row.Scan(&accountCode); err != nil {
+		t.Fatal(err)
+	}
+
+	url := fmt.Sprintf("http://1.2.3.4/v1/accounts/%s/risk?frequency=hourly", accountCode)
+	recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusBadRequest)
+
+	url = fmt.Sprintf("http://1.2.3.4/v1/accounts/%s/risk?riskFreeRate=high", accountCode)
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusBadRequest)
+}
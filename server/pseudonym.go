@@ -0,0 +1,232 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// pseudonymEnv is the rest.Request Env key of the Pseudonymiser applied to the
+// request, if any.
+const pseudonymEnv = "PSEUDONYMISER"
+
+// tokenPrefix begins every token, distinguishing them from IB account codes.
+const tokenPrefix = "P"
+
+// Pseudonymiser replaces account codes with tokens. A token is derived from
+// the HMAC-SHA256 of the account code, so it is stable for as long as the key
+// is unchanged but cannot be reversed without the key.
+type Pseudonymiser struct {
+	db     *sql.DB
+	u      *Util
+	key    []byte
+	routes []string
+}
+
+// NewPseudonymiser returns a Pseudonymiser for the key, which applies to every
+// request for a path beginning with one of the routes and every request by an
+// API key configured to be pseudonymised.
+func NewPseudonymiser(db *sql.DB, u *Util, key string, routes []string) *Pseudonymiser {
+	return &Pseudonymiser{db: db, u: u, key: []byte(key), routes: routes}
+}
+
+// Token returns the token of the account code.
+func (p *Pseudonymiser) Token(accountCode string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(accountCode))
+	return tokenPrefix + strings.ToUpper(hex.EncodeToString(mac.Sum(nil))[:20])
+}
+
+// resolve returns the account code of the token, or sql.ErrNoRows if the token
+// does not identify any account.
+func (p *Pseudonymiser) resolve(token string) (string, error) {
+	rows, err := p.db.Query("SELECT account_code FROM account")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		code := ""
+		if err := rows.Scan(&code); err != nil {
+			return "", err
+		}
+		if hmac.Equal([]byte(p.Token(code)), []byte(token)) {
+			return code, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return "", sql.ErrNoRows
+}
+
+// applies indicates whether responses to the request must be pseudonymised.
+func (p *Pseudonymiser) applies(r *rest.Request) bool {
+	if pr := principal(r); pr != nil && pr.Pseudonymise {
+		return true
+	}
+	for _, route := range p.routes {
+		if strings.HasPrefix(r.URL.Path, route) {
+			return true
+		}
+	}
+	return false
+}
+
+// pseudonymiser returns the Pseudonymiser applied to the request, or nil if
+// account codes are not pseudonymised.
+func pseudonymiser(r *rest.Request) *Pseudonymiser {
+	p, _ := r.Env[pseudonymEnv].(*Pseudonymiser)
+	return p
+}
+
+// Wrap returns the handler, pseudonymising requests it applies to. Tokens in
+// the accountCode path parameter and the account and accounts query
+// parameters are resolved to account codes before the handler is invoked, and
+// account codes are replaced by tokens in the JSON response.
+func (p *Pseudonymiser) Wrap(handler rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		if !p.applies(r) {
+			handler(w, r)
+			return
+		}
+		if len(p.key) == 0 {
+			rest.Error(w, "account codes cannot be pseudonymised without PSEUDONYM_KEY", http.StatusForbidden)
+			return
+		}
+
+		if token, ok := r.PathParams["accountCode"]; ok {
+			code, ok := p.resolveParam(w, r, token)
+			if !ok {
+				return
+			}
+			r.PathParams["accountCode"] = code
+		}
+
+		query := r.URL.Query()
+		for _, param := range []string{"account", "accounts"} {
+			v := query.Get(param)
+			if v == "" {
+				continue
+			}
+			var codes []string
+			for _, token := range strings.Split(v, ",") {
+				code, ok := p.resolveParam(w, r, token)
+				if !ok {
+					return
+				}
+				codes = append(codes, code)
+			}
+			query.Set(param, strings.Join(codes, ","))
+		}
+		r.URL.RawQuery = query.Encode()
+
+		r.Env[pseudonymEnv] = p
+		handler(&pseudonymWriter{streamWriter: streamWriter{w}, p: p}, r)
+	}
+}
+
+// resolveParam returns the account code of a token in a request parameter. If
+// false is returned an error has been written.
+func (p *Pseudonymiser) resolveParam(w rest.ResponseWriter, r *rest.Request, token string) (string, bool) {
+	code := ""
+	err := sql.ErrNoRows
+	if strings.HasPrefix(token, tokenPrefix) {
+		code, err = p.resolve(token)
+	}
+	if err == sql.ErrNoRows {
+		rest.Error(w, fmt.Sprintf("account '%s' not found", token), http.StatusNotFound)
+		return "", false
+	}
+	if err != nil {
+		p.u.HandleError(err, w, r)
+		return "", false
+	}
+	return code, true
+}
+
+// pseudonymise returns the value with every AccountCode and AccountCodes field
+// and account URL replaced by tokens. The value must be a decoded JSON value.
+func (p *Pseudonymiser) pseudonymise(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			switch s := value.(type) {
+			case string:
+				if key == "AccountCode" {
+					t[key] = p.Token(s)
+				} else if key == "Url" {
+					t[key] = p.Url(s)
+				}
+			case []interface{}:
+				if key == "AccountCodes" {
+					for i, code := range s {
+						if code, ok := code.(string); ok {
+							s[i] = p.Token(code)
+						}
+					}
+					continue
+				}
+				p.pseudonymise(s)
+			default:
+				p.pseudonymise(s)
+			}
+		}
+	case []interface{}:
+		for _, value := range t {
+			p.pseudonymise(value)
+		}
+	}
+	return v
+}
+
+// Url replaces the account code of a /v1/accounts URL with its token.
+func (p *Pseudonymiser) Url(url string) string {
+	const prefix = "/v1/accounts/"
+	i := strings.Index(url, prefix)
+	if i < 0 {
+		return url
+	}
+	tail := url[i+len(prefix):]
+	code := tail
+	if j := strings.IndexAny(tail, "/?"); j >= 0 {
+		code = tail[:j]
+	}
+	return url[:i+len(prefix)] + p.Token(code) + tail[len(code):]
+}
+
+// pseudonymWriter pseudonymises JSON responses and Location headers.
+type pseudonymWriter struct {
+	streamWriter
+	p *Pseudonymiser
+}
+
+func (w *pseudonymWriter) WriteHeader(code int) {
+	if location := w.Header().Get("Location"); location != "" {
+		w.Header().Set("Location", w.p.Url(location))
+	}
+	w.streamWriter.WriteHeader(code)
+}
+
+func (w *pseudonymWriter) WriteJson(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var decoded interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&decoded); err != nil {
+		return err
+	}
+	return w.streamWriter.WriteJson(w.p.pseudonymise(decoded))
+}
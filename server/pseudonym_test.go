@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/gateway"
+)
+
+func TestPseudonymiserToken(t *testing.T) {
+	p := NewPseudonymiser(nil, nil, "key", nil)
+	token := p.Token("U1234567")
+	if token != p.Token("U1234567") {
+		t.Fatal("token should be stable")
+	}
+	if !strings.HasPrefix(token, tokenPrefix) || len(token) != len(tokenPrefix)+20 {
+		t.Fatalf("unexpected token %s", token)
+	}
+	if token == p.Token("U7654321") {
+		t.Fatal("accounts should have different tokens")
+	}
+	if token == NewPseudonymiser(nil, nil, "other", nil).Token("U1234567") {
+		t.Fatal("keys should produce different tokens")
+	}
+}
+
+func TestPseudonymiserUrl(t *testing.T) {
+	p := NewPseudonymiser(nil, nil, "key", nil)
+	token := p.Token("U1")
+	cases := map[string]string{
+		"/v1/accounts/U1/2015-01-02T03:04:05Z":        "/v1/accounts/" + token + "/2015-01-02T03:04:05Z",
+		"http://host/v1/accounts/U1?currency=USD":     "http://host/v1/accounts/" + token + "?currency=USD",
+		"/v1/accounts/U1":                             "/v1/accounts/" + token,
+		"/v1/accounts":                                "/v1/accounts",
+		"http://host/v1/groups/clienta/concentration": "http://host/v1/groups/clienta/concentration",
+	}
+	for url, expected := range cases {
+		if actual := p.Url(url); actual != expected {
+			t.Fatalf("expected %s (was %s)", expected, actual)
+		}
+	}
+}
+
+func TestPseudonymiserPseudonymise(t *testing.T) {
+	p := NewPseudonymiser(nil, nil, "key", nil)
+	var v interface{}
+	err := json.Unmarshal([]byte(`[{"AccountCode": "U1", "Nested": {"AccountCodes": ["U1", "U2"]},
+		"Positions": [{"AccountCode": "U2", "Symbol": "U1"}], "Url": "/v1/accounts/U1/ts"}]`), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(p.pseudonymise(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	if strings.Contains(s, `"U2"`) || strings.Count(s, `"U1"`) != 1 {
+		t.Fatalf("account codes not pseudonymised: %s", s)
+	}
+	if strings.Count(s, p.Token("U1")) != 3 || strings.Count(s, p.Token("U2")) != 2 {
+		t.Fatalf("unexpected tokens: %s", s)
+	}
+}
+
+func TestPseudonymiserRoutes(t *testing.T) {
+	c := core.NewTestConfig(t)
+	c.PseudonymKey = "test-pseudonym-key"
+	c.PseudonymRoutes = []string{"/v1/accounts"}
+
+	ctx, err := core.NewContext(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+	handler := Handler(c, ctx.DB, ctx.N)
+
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	WaitForFeed(t, ctx, &ff, 15*time.Second)
+
+	recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://1.2.3.4/v1/accounts", nil))
+	recorded.CodeIs(http.StatusOK)
+	var accounts []*core.Account
+	if err := recorded.DecodeJsonPayload(&accounts); err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) == 0 {
+		t.Fatal("no accounts returned")
+	}
+	token := accounts[0].AccountCode
+	if !strings.HasPrefix(token, tokenPrefix) {
+		t.Fatalf("account code %s not pseudonymised", token)
+	}
+
+	accountCode := ""
+	if err := ctx.DB.QueryRow("SELECT account_code FROM account WHERE id = $1", accounts[0].Id).Scan(&accountCode); err != nil {
+		t.Fatal(err)
+	}
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://1.2.3.4/v1/accounts/"+accountCode, nil))
+	recorded.CodeIs(http.StatusNotFound)
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://1.2.3.4/v1/accounts/"+token, nil))
+	recorded.CodeIs(http.StatusSeeOther)
+	target := recorded.Recorder.Header().Get("Location")
+	if !strings.Contains(target, token) || strings.Contains(target, accountCode) {
+		t.Fatalf("Location %s not pseudonymised", target)
+	}
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", target, nil))
+	recorded.CodeIs(http.StatusOK)
+	report := AccountReport{}
+	if err := recorded.DecodeJsonPayload(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.AccountCode != token {
+		t.Fatalf("expected %s (was %s)", token, report.AccountCode)
+	}
+}
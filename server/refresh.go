@@ -13,7 +13,7 @@ import (
 // RefreshIfNeeded detects a HTTP request for an immediate refresh of the gateway backend.
 // If detected, it publishes a "request" notification and blocks awaiting the "completed"
 // acknowledgement reply. An error is returned if acknowledgement exceeds the timeout.
-func RefreshIfNeeded(n *core.Notifier, r *rest.Request, requestRefresh core.NtType, completedRefresh core.NtType, timeout time.Duration) error {
+func RefreshIfNeeded(n core.Notifier, r *rest.Request, requestRefresh core.NtType, completedRefresh core.NtType, timeout time.Duration) error {
 	if strings.Contains(r.Header.Get("Cache-Control"), "max-age=0") {
 		notifications := make(chan *core.Notification)
 		n.Subscribe(notifications)
@@ -28,7 +28,7 @@ func RefreshIfNeeded(n *core.Notifier, r *rest.Request, requestRefresh core.NtTy
 					return errors.New("Subscription channel unexpectedly closed; did another goroutine close the notifier?")
 				}
 				if msg.Type == completedRefresh {
-					break
+					return nil
 				}
 			case <-time.After(timeout):
 				return fmt.Errorf("Timeout %v waiting for '%v' response to '%v' request", timeout, completedRefresh, requestRefresh)
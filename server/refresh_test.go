@@ -0,0 +1,91 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/core"
+)
+
+func TestRefreshIfNeededWithoutMaxAge(t *testing.T) {
+	n := newRefreshNotifier(t)
+	defer n.Close()
+
+	// nothing answers, so any refresh would time out
+	err := RefreshIfNeeded(n, newRefreshRequest(t, "private"), core.NtAccountRefresh, core.NtAccountFeedDone,
+		time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshIfNeededAwaitsCompletion(t *testing.T) {
+	n := newRefreshNotifier(t)
+	defer n.Close()
+
+	// act as the gateway, completing each refresh requested until the
+	// notifier closes the subscription
+	nc := make(chan *core.Notification)
+	n.Subscribe(nc)
+	go func() {
+		for msg := range nc {
+			if msg.Type == core.NtAccountRefresh {
+				go n.Publish(core.NtAccountFeedDone, 0)
+			}
+		}
+	}()
+
+	started := time.Now()
+	err := RefreshIfNeeded(n, newRefreshRequest(t, "private; max-age=0"), core.NtAccountRefresh,
+		core.NtAccountFeedDone, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(started) > time.Second {
+		t.Fatalf("refresh took %v to complete", time.Since(started))
+	}
+}
+
+func TestRefreshIfNeededTimeout(t *testing.T) {
+	n := newRefreshNotifier(t)
+	defer n.Close()
+
+	err := RefreshIfNeeded(n, newRefreshRequest(t, "max-age=0"), core.NtAccountRefresh, core.NtAccountFeedDone,
+		100*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected an unanswered refresh to time out")
+	}
+}
+
+func TestRefreshIfNeededClosedNotifier(t *testing.T) {
+	n := newRefreshNotifier(t)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		n.Close()
+	}()
+	err := RefreshIfNeeded(n, newRefreshRequest(t, "max-age=0"), core.NtAccountRefresh, core.NtAccountFeedDone,
+		5*time.Second)
+	if err == nil {
+		t.Fatal("expected closing the notifier to end the refresh")
+	}
+}
+
+func newRefreshNotifier(t *testing.T) core.Notifier {
+	n := core.NewLocalNotifier()
+	if err := n.RegisterAll(core.NtTypes()); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func newRefreshRequest(t *testing.T, cacheControl string) *rest.Request {
+	req, err := http.NewRequest("GET", "http://1.2.3.4/v1/accounts", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Cache-Control", cacheControl)
+	return &rest.Request{Request: req}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"crypto/tls"
 	"net"
 	"net/http"
 	"time"
@@ -10,6 +11,12 @@ import (
 
 // Serve executes a HTTP server and blocks until the passed channel closes.
 func Serve(terminated <-chan struct{}, address string, handler http.Handler) error {
+	return ServeTLS(terminated, address, handler, nil)
+}
+
+// ServeTLS executes a HTTPS server and blocks until the passed channel closes.
+// If config is nil a HTTP server is executed instead.
+func ServeTLS(terminated <-chan struct{}, address string, handler http.Handler, config *tls.Config) error {
 	listener, err := net.Listen("tcp", address)
 	stoppable := stoppableListener.Handle(listener)
 
@@ -20,7 +27,12 @@ func Serve(terminated <-chan struct{}, address string, handler http.Handler) err
 		}
 	}()
 
-	err = http.Serve(stoppable, handler)
+	var l net.Listener = stoppable
+	if config != nil {
+		l = tls.NewListener(stoppable, config)
+	}
+
+	err = http.Serve(l, handler)
 	if !stoppable.Stopped && err != nil {
 		return err
 	}
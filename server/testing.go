@@ -21,7 +21,7 @@ func NewTestHandler(t *testing.T) (*core.Context, http.Handler) {
 		t.Fatal(err)
 	}
 
-	return ctx, Handler(c.ErrInfo, ctx.DB, ctx.N)
+	return ctx, Handler(c, ctx.DB, ctx.N)
 }
 
 // WaitForFeed blocks the goroutine until the FeedFactory has sent a Done event.
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// watchInterval is how often certificate files are checked for modification.
+const watchInterval = 10 * time.Second
+
+// CertLoader holds the server certificate, allowing it to be replaced (eg on
+// renewal) without restarting the server.
+type CertLoader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+// NewCertLoader returns a CertLoader holding the certificate and key loaded
+// from the PEM files.
+func NewCertLoader(certFile, keyFile string) (*CertLoader, error) {
+	l := &CertLoader{certFile: certFile, keyFile: keyFile}
+	return l, l.Reload()
+}
+
+// Reload loads the certificate and key files. The existing certificate is
+// retained if they cannot be loaded.
+func (l *CertLoader) Reload() error {
+	modTime, err := l.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cert = &cert
+	l.modTime = modTime
+	return nil
+}
+
+// GetCertificate returns the current certificate. It is suitable for use as
+// tls.Config.GetCertificate.
+func (l *CertLoader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.cert, nil
+}
+
+// Watch reloads the certificate whenever reload receives or the files are
+// modified, until terminated is closed. Failures are logged and the existing
+// certificate is retained.
+func (l *CertLoader) Watch(reload <-chan struct{}, terminated <-chan struct{}) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-terminated:
+			return
+		case <-reload:
+		case <-ticker.C:
+			modTime, err := l.lastModified()
+			l.mu.RLock()
+			unchanged := err == nil && !modTime.After(l.modTime)
+			l.mu.RUnlock()
+			if unchanged {
+				continue
+			}
+		}
+
+		if err := l.Reload(); err != nil {
+			log.Printf("server: certificate %s not reloaded: %v", l.certFile, err)
+			continue
+		}
+		log.Printf("server: certificate %s reloaded", l.certFile)
+	}
+}
+
+// lastModified returns the most recent modification time of the files.
+func (l *CertLoader) lastModified() (time.Time, error) {
+	latest := time.Time{}
+	for _, file := range []string{l.certFile, l.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// NewTLSConfig returns a TLS configuration serving the certificate of the
+// CertLoader. If clientCaFile is not empty, client certificates are requested
+// and must be issued by a CA in that PEM file if presented. Clients without a
+// certificate may still authenticate by API key.
+func NewTLSConfig(l *CertLoader, clientCaFile string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: l.GetCertificate,
+	}
+	if clientCaFile == "" {
+		return config, nil
+	}
+
+	pem, err := ioutil.ReadFile(clientCaFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", clientCaFile)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	return config, nil
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate (usable as its own CA) and key
+// with the common name to PEM files in dir, returning their paths.
+func writeCert(t *testing.T, dir, commonName string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, commonName+".crt")
+	keyFile := filepath.Join(dir, commonName+".key")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err == nil {
+		err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert
+}
+
+func commonName(t *testing.T, l *CertLoader) string {
+	cert, err := l.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertLoaderReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ibconnect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile, _ := writeCert(t, dir, "one")
+	l, err := NewCertLoader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn := commonName(t, l); cn != "one" {
+		t.Fatalf("expected one (was %s)", cn)
+	}
+
+	twoCert, twoKey, _ := writeCert(t, dir, "two")
+	os.Rename(twoCert, certFile)
+	os.Rename(twoKey, keyFile)
+
+	reload := make(chan struct{})
+	terminated := make(chan struct{})
+	defer close(terminated)
+	go l.Watch(reload, terminated)
+	reload <- struct{}{}
+
+	for i := 0; commonName(t, l) != "two"; i++ {
+		if i == 100 {
+			t.Fatal("certificate not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a broken file retains the existing certificate
+	ioutil.WriteFile(certFile, []byte("broken"), 0600)
+	if err := l.Reload(); err == nil {
+		t.Fatal("expected error reloading broken certificate")
+	}
+	if cn := commonName(t, l); cn != "two" {
+		t.Fatalf("expected two (was %s)", cn)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ibconnect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile, _ := writeCert(t, dir, "server")
+	l, err := NewCertLoader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewTLSConfig(l, "")
+	if err != nil || config.ClientAuth != tls.NoClientCert {
+		t.Fatalf("unexpected config %v (%v)", config, err)
+	}
+
+	config, err = NewTLSConfig(l, certFile)
+	if err != nil || config.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Fatalf("unexpected config %v (%v)", config, err)
+	}
+
+	if _, err = NewTLSConfig(l, keyFile); err == nil {
+		t.Fatal("expected error for CA file without certificates")
+	}
+}
+
+func TestServeTLSVerifiesClientCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ibconnect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	serverCert, serverKey, server := writeCert(t, dir, "server")
+	clientCert, clientKey, _ := writeCert(t, dir, "client")
+
+	l, err := NewCertLoader(serverCert, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := NewTLSConfig(l, clientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.VerifiedChains) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, r.TLS.VerifiedChains[0][0].Subject.CommonName)
+	})
+
+	address := "127.0.0.1:3443"
+	terminated := make(chan struct{})
+	defer close(terminated)
+	go ServeTLS(terminated, address, handler, config)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server)
+	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{cert},
+	}}}
+
+	var res *http.Response
+	for i := 0; ; i++ {
+		res, err = client.Get("https://" + address + "/")
+		if err == nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK || string(body) != "client" {
+		t.Fatalf("unexpected response %d %s", res.StatusCode, body)
+	}
+}
@@ -20,8 +20,8 @@ func (u *Util) HandleError(err error, w rest.ResponseWriter, r *rest.Request) {
 	if err == sql.ErrNoRows {
 		rest.NotFound(w, r)
 	}
-	id := u.uuid()
-	log.Printf("%v [%s] [%v]", err, id, r.URL)
+	id := u.correlationId(r)
+	log.Printf("%v [%s] [%s]", err, id, redactedURI(r.URL))
 
 	errInfo := make(map[string]string)
 	errInfo["error_id"] = id
@@ -34,6 +34,18 @@ func (u *Util) HandleError(err error, w rest.ResponseWriter, r *rest.Request) {
 	w.WriteJson(errInfo)
 }
 
+// correlationEnv is the rest.Request Env key of the request's correlation ID.
+const correlationEnv = "CORRELATION_ID"
+
+// correlationId returns the ID the audit log assigned to the request, so error
+// messages and audit records can be correlated, otherwise a new UUID.
+func (u *Util) correlationId(r *rest.Request) string {
+	if id, ok := r.Env[correlationEnv].(string); ok {
+		return id
+	}
+	return u.uuid()
+}
+
 // uuid makes a simple random UUID.
 func (u *Util) uuid() string {
 	b := make([]byte, 16)
@@ -42,3 +54,22 @@ func (u *Util) uuid() string {
 	b[8] = (b[8] & 0x3f) | 0x80
 	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
 }
+
+// streamWriter wraps a rest.ResponseWriter, passing through the
+// http.ResponseWriter, http.Flusher and http.CloseNotifier interfaces of the
+// underlying writer so streaming handlers work unchanged.
+type streamWriter struct {
+	rest.ResponseWriter
+}
+
+func (w streamWriter) Write(b []byte) (int, error) {
+	return w.ResponseWriter.(http.ResponseWriter).Write(b)
+}
+
+func (w streamWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w streamWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
@@ -27,7 +27,8 @@ func TestErrorHandlerInternalServerError(t *testing.T) {
 	}
 	defer ctx.Close()
 
-	handler := Handler(true, ctx.DB, ctx.N)
+	c.ErrInfo = true
+	handler := Handler(c, ctx.DB, ctx.N)
 
 	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
 	WaitForFeed(t, ctx, &ff, 5*time.Second)
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+type WebhookHandler struct {
+	db *sql.DB
+	u  *Util
+}
+
+// WebhookRequest creates a webhook. A random Secret is generated if empty.
+type WebhookRequest struct {
+	Url    string
+	Secret string
+}
+
+func (h *WebhookHandler) GetAll(w rest.ResponseWriter, r *rest.Request) {
+	hooks := []*core.Webhook{}
+	err := meddler.QueryAll(h.db, &hooks, "SELECT * FROM webhook ORDER BY id")
+	if err != nil {
+		h.u.HandleError(err, w, r)
+		return
+	}
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+	w.WriteJson(&hooks)
+}
+
+func (h *WebhookHandler) Get(w rest.ResponseWriter, r *rest.Request) {
+	hook, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+	hook.Secret = ""
+	w.WriteJson(hook)
+}
+
+// Post creates a webhook, returning its secret. The secret is not returned by
+// any other request.
+func (h *WebhookHandler) Post(w rest.ResponseWriter, r *rest.Request) {
+	req := WebhookRequest{}
+	err := r.DecodeJsonPayload(&req)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := url.Parse(req.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		rest.Error(w, fmt.Sprintf("Url '%s' is not an absolute HTTP or HTTPS URL", req.Url), http.StatusBadRequest)
+		return
+	}
+
+	if req.Secret == "" {
+		b := make([]byte, 32)
+		_, err = rand.Read(b)
+		if err != nil {
+			h.u.HandleError(err, w, r)
+			return
+		}
+		req.Secret = hex.EncodeToString(b)
+	}
+
+	hook := &core.Webhook{Created: time.Now().UTC(), Url: req.Url, Secret: req.Secret, Enabled: true}
+	row := h.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM account_snapshot")
+	err = row.Scan(&hook.LastSnapshotId)
+	if err == nil {
+		err = meddler.Insert(h.db, "webhook", hook)
+	}
+	if err != nil {
+		h.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.WriteJson(hook)
+}
+
+func (h *WebhookHandler) Delete(w rest.ResponseWriter, r *rest.Request) {
+	hook, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := h.db.Exec("DELETE FROM webhook WHERE id = $1", hook.Id)
+	if err != nil {
+		h.u.HandleError(err, w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries returns the most recent deliveries of the webhook, limited by
+// the limit query parameter (default 100).
+func (h *WebhookHandler) GetDeliveries(w rest.ResponseWriter, r *rest.Request) {
+	hook, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			rest.Error(w, fmt.Sprintf("limit '%s' is not a positive integer", v), http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	deliveries := []*core.WebhookDelivery{}
+	err := meddler.QueryAll(h.db, &deliveries, "SELECT * FROM webhook_delivery WHERE webhook_id = $1 "+
+		"ORDER BY id DESC LIMIT $2", hook.Id, limit)
+	if err != nil {
+		h.u.HandleError(err, w, r)
+		return
+	}
+	w.Header().Add("Cache-Control", "private, max-age=0")
+	w.WriteJson(&deliveries)
+}
+
+// load returns the webhook identified by the webhookId path parameter. If false
+// is returned an error has been written.
+func (h *WebhookHandler) load(w rest.ResponseWriter, r *rest.Request) (*core.Webhook, bool) {
+	id, err := strconv.ParseInt(r.PathParam("webhookId"), 10, 64)
+	if err != nil {
+		rest.NotFound(w, r)
+		return nil, false
+	}
+
+	hook := new(core.Webhook)
+	err = meddler.Load(h.db, "webhook", hook, id)
+	if err != nil {
+		h.u.HandleError(err, w, r)
+		return nil, false
+	}
+	return hook, true
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/benalexau/ibconnect/core"
+)
+
+func TestWebhookHandlerLifecycle(t *testing.T) {
+	ctx, handler := NewTestHandler(t)
+	defer ctx.Close()
+
+	req := WebhookRequest{Url: "ftp://example.com"}
+	recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://1.2.3.4/v1/webhooks", &req))
+	recorded.CodeIs(http.StatusBadRequest)
+
+	req.Url = "https://example.com/hook"
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://1.2.3.4/v1/webhooks", &req))
+	recorded.CodeIs(http.StatusCreated)
+
+	hook := core.Webhook{}
+	if err := recorded.DecodeJsonPayload(&hook); err != nil {
+		t.Fatal(err)
+	}
+	if len(hook.Secret) != 64 || !hook.Enabled {
+		t.Fatalf("unexpected webhook %v", hook)
+	}
+
+	url := fmt.Sprintf("http://1.2.3.4/v1/webhooks/%d", hook.Id)
+	defer test.RunRequest(t, handler, test.MakeSimpleRequest("DELETE", url, nil))
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusOK)
+	hook = core.Webhook{}
+	if err := recorded.DecodeJsonPayload(&hook); err != nil {
+		t.Fatal(err)
+	}
+	if hook.Secret != "" {
+		t.Fatal("secret should only be returned on creation")
+	}
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url+"/deliveries", nil))
+	recorded.CodeIs(http.StatusOK)
+	recorded.ContentTypeIsJson()
+
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("DELETE", url, nil))
+	recorded.CodeIs(http.StatusNoContent)
+	recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+	recorded.CodeIs(http.StatusNotFound)
+}
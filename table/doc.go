@@ -0,0 +1,12 @@
+/*
+Package table writes tabular data in spreadsheet-friendly formats.
+
+A Table is a named header and rows of string cells. It can be written as CSV
+(RFC 4180) or as one sheet of an Office Open XML (XLSX) workbook. Cells that
+parse as finite numbers are written to XLSX as numeric cells, so spreadsheet
+formulas work without conversion; all other cells are written as text.
+
+The XLSX writer produces the minimum parts required by the format (no styles
+or shared strings), which keeps the package free of external dependencies.
+*/
+package table
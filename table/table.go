@@ -0,0 +1,176 @@
+package table
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Table is a named set of rows, each with a cell per Header column.
+type Table struct {
+	Name   string
+	Header []string
+	Rows   [][]string
+}
+
+// Append adds a row, formatting floats in decimal notation and other values
+// with fmt's %v verb.
+func (t *Table) Append(values ...interface{}) {
+	row := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			row[i] = v
+		case float64:
+			row[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			row[i] = fmt.Sprintf("%v", v)
+		}
+	}
+	t.Rows = append(t.Rows, row)
+}
+
+// Column returns the index of the named column, or -1 if absent.
+func (t *Table) Column(name string) int {
+	for i, h := range t.Header {
+		if h == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// WriteCSV writes the header and rows as CSV.
+func WriteCSV(w io.Writer, t Table) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(t.Rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+const (
+	mainNs = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	relNs  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	pkgNs  = "http://schemas.openxmlformats.org/package/2006/relationships"
+	xmlDec = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+)
+
+// WriteXLSX writes a workbook with a sheet per table, in order.
+func WriteXLSX(w io.Writer, tables []Table) error {
+	z := zip.NewWriter(w)
+
+	var types, rels, sheets bytes.Buffer
+	types.WriteString(xmlDec + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	rels.WriteString(xmlDec + `<Relationships xmlns="` + pkgNs + `">`)
+
+	used := make(map[string]bool)
+	for i, t := range tables {
+		n := i + 1
+		types.WriteString(fmt.Sprintf(`<Override PartName="/xl/worksheets/sheet%d.xml" `+
+			`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n))
+		rels.WriteString(fmt.Sprintf(`<Relationship Id="rId%d" Type="%s/worksheet" Target="worksheets/sheet%d.xml"/>`,
+			n, relNs, n))
+		sheets.WriteString(fmt.Sprintf(`<sheet name="%s" sheetId="%d" r:id="rId%d"/>`,
+			escape(sheetName(t.Name, n, used)), n, n))
+
+		f, err := z.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", n))
+		if err != nil {
+			return err
+		}
+		if err := writeSheet(f, t); err != nil {
+			return err
+		}
+	}
+	types.WriteString(`</Types>`)
+	rels.WriteString(`</Relationships>`)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", types.String()},
+		{"_rels/.rels", xmlDec + `<Relationships xmlns="` + pkgNs + `"><Relationship Id="rId1" Type="` + relNs +
+			`/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", xmlDec + `<workbook xmlns="` + mainNs + `" xmlns:r="` + relNs + `"><sheets>` +
+			sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+	}
+	for _, p := range parts {
+		f, err := z.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.content); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+// writeSheet writes the worksheet part of the table.
+func writeSheet(w io.Writer, t Table) error {
+	var b bytes.Buffer
+	b.WriteString(xmlDec + `<worksheet xmlns="` + mainNs + `"><sheetData>`)
+	for r, row := range append([][]string{t.Header}, t.Rows...) {
+		b.WriteString(fmt.Sprintf(`<row r="%d">`, r+1))
+		for c, cell := range row {
+			ref := column(c) + strconv.Itoa(r+1)
+			if f, err := strconv.ParseFloat(cell, 64); err == nil && r > 0 && !math.IsNaN(f) && !math.IsInf(f, 0) {
+				b.WriteString(fmt.Sprintf(`<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(f, 'g', -1, 64)))
+			} else {
+				b.WriteString(fmt.Sprintf(`<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, escape(cell)))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// column returns the spreadsheet column letters of the zero-based index.
+func column(i int) string {
+	s := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		s = string(rune('A'+(i-1)%26)) + s
+	}
+	return s
+}
+
+// sheetName returns a unique, valid sheet name (at most 31 characters, with
+// none of the characters Excel reserves).
+func sheetName(name string, n int, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if len(name) > 31 {
+		name = name[:31]
+	}
+	if name == "" || used[strings.ToLower(name)] {
+		name = fmt.Sprintf("Sheet%d", n)
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
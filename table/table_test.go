@@ -0,0 +1,107 @@
+package table
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	tbl := Table{Header: []string{"Name", "Value", "Count"}}
+	tbl.Append("a", 0.1, 3)
+	if strings.Join(tbl.Rows[0], ",") != "a,0.1,3" {
+		t.Fatalf("unexpected row %v", tbl.Rows[0])
+	}
+	if tbl.Column("Count") != 2 || tbl.Column("Missing") != -1 {
+		t.Fatal("unexpected column index")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	tbl := Table{Header: []string{"Symbol", "Description"}}
+	tbl.Append("BHP", `Says "hello", world`)
+
+	var b bytes.Buffer
+	if err := WriteCSV(&b, tbl); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Symbol,Description\nBHP,\"Says \"\"hello\"\", world\"\n"
+	if b.String() != expected {
+		t.Fatalf("expected %q (was %q)", expected, b.String())
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	balances := Table{Name: "Balances", Header: []string{"Currency", "NetLiquidation"}}
+	balances.Append("AUD", 1234.5)
+	positions := Table{Name: "Positions/Open", Header: []string{"Symbol", "Position"}}
+	positions.Append("A&B <C>", 10)
+	positions.Append("NaN", "Inf")
+
+	var b bytes.Buffer
+	if err := WriteXLSX(&b, []Table{balances, positions}); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(content)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml",
+		"xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Fatalf("part %s missing", name)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Balances"`) ||
+		!strings.Contains(parts["xl/workbook.xml"], `name="Positions_Open"`) {
+		t.Fatalf("unexpected sheet names %s", parts["xl/workbook.xml"])
+	}
+	sheet1 := parts["xl/worksheets/sheet1.xml"]
+	if !strings.Contains(sheet1, `<c r="B2"><v>1234.5</v></c>`) ||
+		!strings.Contains(sheet1, `<c r="B1" t="inlineStr"><is><t>NetLiquidation</t></is></c>`) {
+		t.Fatalf("unexpected sheet %s", sheet1)
+	}
+	sheet2 := parts["xl/worksheets/sheet2.xml"]
+	if !strings.Contains(sheet2, `<t>A&amp;B &lt;C&gt;</t>`) || !strings.Contains(sheet2, `<c r="B3" t="inlineStr"><is><t>Inf</t>`) {
+		t.Fatalf("unexpected sheet %s", sheet2)
+	}
+}
+
+func TestColumn(t *testing.T) {
+	cases := map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 701: "ZZ", 702: "AAA"}
+	for i, expected := range cases {
+		if c := column(i); c != expected {
+			t.Fatalf("column %d expected %s (was %s)", i, expected, c)
+		}
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	used := make(map[string]bool)
+	if n := sheetName("Balances", 1, used); n != "Balances" {
+		t.Fatalf("unexpected name %s", n)
+	}
+	if n := sheetName("balances", 2, used); n != "Sheet2" {
+		t.Fatalf("duplicate name should be replaced (was %s)", n)
+	}
+	if n := sheetName(strings.Repeat("x", 40), 3, used); len(n) != 31 {
+		t.Fatalf("name should be truncated (was %s)", n)
+	}
+}
@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/benalexau/ibconnect/core"
+)
+
+const lockManagerKey int64 = 5527048173620958117
+
+// retryInterval is how often the leader retries outstanding deliveries.
+const retryInterval = 30 * time.Second
+
+// Dispatcher queues and delivers webhooks whenever an account feed completes,
+// if this node is the cluster leader for webhooks.
+type Dispatcher struct {
+	leader *core.Leader
+	db     *sql.DB
+	client *http.Client
+}
+
+func NewDispatcher(db *sql.DB, n core.Notifier, distLock core.DistLock) (*Dispatcher, error) {
+	d := &Dispatcher{
+		db:     db,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	d.leader = core.NewLeader(distLock, lockManagerKey, retryInterval, n, d.process)
+	return d, nil // never returns error, but declared for consistency
+}
+
+// Close terminates the Dispatcher. Close can be called multiple times safely,
+// and it will block until the Dispatcher has been closed.
+func (d *Dispatcher) Close() {
+	d.leader.Close()
+}
+
+// process queues deliveries of new snapshots and attempts any due deliveries.
+func (d *Dispatcher) process() {
+	_, err := Enqueue(d.db, time.Now().UTC())
+	if err != nil {
+		log.Printf("webhook: queueing failed: %v", err)
+	}
+
+	_, err = Deliver(d.db, d.client, time.Now().UTC())
+	if err != nil {
+		log.Printf("webhook: delivery failed: %v", err)
+	}
+}
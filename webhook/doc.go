@@ -0,0 +1,14 @@
+/*
+Package webhook advises subscribed HTTP endpoints of new account snapshots.
+
+Webhooks are stored in the webhook table. Whenever an account feed completes,
+the cluster leader queues a delivery for each enabled webhook listing the
+snapshots committed since its previous delivery. Deliveries are POSTed as JSON
+and signed in the same way as alert webhooks: the alert.SignatureHeader holds
+the hex HMAC-SHA256 of the body keyed by the webhook's secret, and the
+alert.DeliveryHeader holds the delivery ID so receivers can discard duplicates.
+
+Failed deliveries are retried with alert.Backoff until they succeed or reach
+maxAttempts. The webhook_delivery table doubles as the delivery log.
+*/
+package webhook
@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/benalexau/ibconnect/alert"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/russross/meddler"
+)
+
+// maxAttempts is the number of times a delivery is attempted before failing.
+const maxAttempts = 10
+
+// Payload is the JSON body POSTed to a webhook.
+type Payload struct {
+	Event     string
+	Snapshots []Snapshot
+}
+
+// Snapshot identifies a new account snapshot. Timestamp is the RFC 3339
+// timestamp used in the report URL.
+type Snapshot struct {
+	Id          int64     `meddler:"id,pk"`
+	AccountCode string    `meddler:"account_code"`
+	Created     time.Time `meddler:"created,utctime" json:"-"`
+	Timestamp   string    `meddler:"-"`
+}
+
+// Enqueue queues a delivery for each enabled webhook listing the snapshots
+// committed since its last delivery, returning the number queued.
+func Enqueue(db *sql.DB, now time.Time) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var hooks []*core.Webhook
+	err = meddler.QueryAll(tx, &hooks, "SELECT * FROM webhook WHERE enabled ORDER BY id FOR UPDATE")
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, hook := range hooks {
+		var snapshots []*Snapshot
+		err = meddler.QueryAll(tx, &snapshots, "SELECT account_snapshot.id, account_code, created FROM "+
+			"account_snapshot, account WHERE account.id = account_id AND account_snapshot.id > $1 "+
+			"ORDER BY account_snapshot.id", hook.LastSnapshotId)
+		if err != nil {
+			return queued, err
+		}
+		if len(snapshots) == 0 {
+			continue
+		}
+
+		p := Payload{Event: "snapshot"}
+		for _, s := range snapshots {
+			s.Timestamp = s.Created.Format(time.RFC3339Nano)
+			p.Snapshots = append(p.Snapshots, *s)
+		}
+		body, err := json.Marshal(p)
+		if err != nil {
+			return queued, err
+		}
+
+		d := &core.WebhookDelivery{WebhookId: hook.Id, Created: now, Payload: string(body), NextAttempt: now}
+		err = meddler.Insert(tx, "webhook_delivery", d)
+		if err != nil {
+			return queued, err
+		}
+
+		hook.LastSnapshotId = snapshots[len(snapshots)-1].Id
+		_, err = tx.Exec("UPDATE webhook SET last_snapshot_id = $1 WHERE id = $2", hook.LastSnapshotId, hook.Id)
+		if err != nil {
+			return queued, err
+		}
+		queued++
+	}
+
+	return queued, tx.Commit()
+}
+
+// Deliver attempts every due delivery, returning the number delivered.
+func Deliver(db *sql.DB, client *http.Client, now time.Time) (int, error) {
+	var pending []*core.WebhookDelivery
+	err := meddler.QueryAll(db, &pending, "SELECT * FROM webhook_delivery WHERE delivered IS NULL AND "+
+		"NOT failed AND next_attempt <= $1 ORDER BY id LIMIT 100", now)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, d := range pending {
+		hook := new(core.Webhook)
+		err = meddler.Load(db, "webhook", hook, d.WebhookId)
+		if err != nil {
+			return delivered, err
+		}
+		if !hook.Enabled {
+			continue
+		}
+
+		d.Attempts++
+		d.LastStatus, err = post(client, hook, d)
+		if err == nil {
+			d.Delivered = now
+			d.LastError = ""
+			delivered++
+		} else {
+			log.Printf("webhook: %s delivery %d attempt %d failed: %v", hook.Url, d.Id, d.Attempts, err)
+			d.LastError = err.Error()
+			if len(d.LastError) > 1000 {
+				d.LastError = d.LastError[:1000]
+			}
+			d.Failed = d.Attempts >= maxAttempts
+			d.NextAttempt = now.Add(alert.Backoff(d.Attempts))
+		}
+
+		err = meddler.Update(db, "webhook_delivery", d)
+		if err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// post sends the delivery, returning the HTTP status (or zero if no response
+// was received). Any status other than 2xx is considered a failure.
+func post(client *http.Client, hook *core.Webhook, d *core.WebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequest("POST", hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(alert.DeliveryHeader, fmt.Sprintf("%d", d.Id))
+	req.Header.Set(alert.SignatureHeader, alert.Sign([]byte(hook.Secret), body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benalexau/ibconnect/alert"
+	"github.com/benalexau/ibconnect/core"
+	"github.com/benalexau/ibconnect/gateway"
+	"github.com/russross/meddler"
+)
+
+func TestEnqueueAndDeliver(t *testing.T) {
+	c := core.NewTestConfig(t)
+	ctx, err := core.NewContext(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	var ff gateway.FeedFactory = &gateway.AccountFeedFactory{AccountRefresh: c.AccountRefresh}
+	gateway.TestSimpleFeedPublishesDoneMessage(t, &ff, 15*time.Second)
+
+	latest := int64(0)
+	if err := ctx.DB.QueryRow("SELECT MAX(id) FROM account_snapshot").Scan(&latest); err != nil {
+		t.Fatal(err)
+	}
+
+	status := http.StatusInternalServerError
+	payloads := make(chan Payload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(alert.SignatureHeader) != alert.Sign([]byte("secret"), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		p := Payload{}
+		json.Unmarshal(body, &p)
+		payloads <- p
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	hook := &core.Webhook{Created: time.Now().UTC(), Url: server.URL, Secret: "secret", Enabled: true, LastSnapshotId: latest - 1}
+	if err := meddler.Insert(ctx.DB, "webhook", hook); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.DB.Exec("DELETE FROM webhook WHERE id = $1", hook.Id)
+
+	now := time.Now().UTC()
+	queued, err := Enqueue(ctx.DB, now)
+	if err != nil || queued == 0 {
+		t.Fatalf("queued %d (%v)", queued, err)
+	}
+
+	// nothing new to queue
+	if err := meddler.Load(ctx.DB, "webhook", hook, hook.Id); err != nil {
+		t.Fatal(err)
+	}
+	if hook.LastSnapshotId < latest {
+		t.Fatalf("last snapshot %d not advanced to %d", hook.LastSnapshotId, latest)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	delivered, err := Deliver(ctx.DB, client, now)
+	if err != nil || delivered != 0 {
+		t.Fatalf("failing webhook delivered %d (%v)", delivered, err)
+	}
+	p := <-payloads
+	if len(p.Snapshots) == 0 || p.Snapshots[len(p.Snapshots)-1].Id < latest {
+		t.Fatalf("unexpected payload %v", p)
+	}
+
+	d := new(core.WebhookDelivery)
+	if err := meddler.QueryRow(ctx.DB, d, "SELECT * FROM webhook_delivery WHERE webhook_id = $1", hook.Id); err != nil {
+		t.Fatal(err)
+	}
+	if d.Attempts != 1 || d.LastStatus != http.StatusInternalServerError || !d.NextAttempt.After(now) {
+		t.Fatalf("unexpected delivery log %v", d)
+	}
+
+	status = http.StatusOK
+	delivered, err = Deliver(ctx.DB, client, now.Add(alert.Backoff(1)))
+	if err != nil || delivered != 1 {
+		t.Fatalf("retry delivered %d (%v)", delivered, err)
+	}
+}